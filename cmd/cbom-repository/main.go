@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/CZERTAINLY/CBOM-Repository/internal/env"
 	"github.com/CZERTAINLY/CBOM-Repository/internal/health"
@@ -18,6 +24,10 @@ import (
 
 var version = "dev"
 
+// shutdownTimeout bounds the time the http server waits for the requests in
+// flight once a shutdown is signalled.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// get configuration from environment variables
 	cfg, err := env.New()
@@ -28,23 +38,55 @@ func main() {
 	slog.Info("Starting service 'CBOM-Repository'.", slog.String("version", version))
 	slog.Debug("Service configuration read from environment variables.")
 
-	s3Client, s3Manager, err := store.ConnectS3(context.Background(), cfg.Store)
+	backend, err := newStoreBackend(context.Background(), cfg)
 	if err != nil {
 		slog.Error("Connecting to backend store failed.", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
-	slog.Debug("Connected to backend store.")
+	slog.Debug("Connected to backend store.", slog.String("backend", cfg.StoreBackend))
+
+	if cfg.Index.File != "" {
+		index, err := store.OpenIndex(cfg.Index.File)
+		if errors.Is(err, store.ErrIndexLocked) && len(os.Args) > 1 && os.Args[1] == "reindex" {
+			slog.Error("Metadata index is used by the running service, use `POST /v1/admin/reindex` instead.", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		if err != nil {
+			slog.Error("Opening metadata index failed.", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer func() {
+			_ = index.Close()
+		}()
+
+		indexed := store.NewIndexed(backend, index)
+		if index.Len() == 0 {
+			if err := indexed.Build(context.Background()); err != nil {
+				slog.Error("Building metadata index failed.", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+		}
+		backend = indexed
+		slog.Debug("Metadata index opened.", slog.String("file", cfg.Index.File), slog.Int("count", index.Len()))
+	}
 
-	store := store.New(cfg.Store, s3Client, s3Manager)
-	svc, err := service.New(store, cfg.Service)
+	svc, err := service.New(backend, cfg.Service)
 	if err != nil {
 		slog.Error("Initializing service layer failed.", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 	slog.Debug("Service layer initialized.")
 
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := reindex(context.Background(), svc, os.Args[2:]); err != nil {
+			slog.Error("Reconciling metadata index failed.", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize health service with storage checker
-	storageChecker := health.NewStorageChecker(store)
+	storageChecker := health.NewStorageChecker(backend)
 	healthSvc := health.NewService(storageChecker)
 	slog.Debug("Health service initialized.")
 
@@ -54,12 +96,72 @@ func main() {
 		Handler: srv.Handler(),
 	}
 
+	// cancelled once the service is asked to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		svc.RunRetention(ctx)
+	})
+	workers.Go(func() {
+		<-ctx.Done()
+		slog.Info("Shutting down http server.")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("`Shutdown()` failed.", slog.String("error", err.Error()))
+		}
+	})
+
 	slog.Info("Starting http server.", slog.Int("port", cfg.Http.Port))
 
 	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("`ListenAndServer()` failed.", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
+	workers.Wait()
+}
+
+// newStoreBackend connects to the storage backend selected by `APP_STORE_BACKEND`.
+func newStoreBackend(ctx context.Context, cfg env.Config) (store.Backend, error) {
+	switch cfg.StoreBackend {
+	case store.BackendS3:
+		s3Client, s3Manager, err := store.ConnectS3(ctx, cfg.Store)
+		if err != nil {
+			return nil, err
+		}
+		return store.New(cfg.Store, s3Client, s3Manager), nil
+
+	case store.BackendFilesystem:
+		return store.NewFilesystem(cfg.Filesystem)
+
+	case store.BackendMemory:
+		slog.Warn("Using in-memory store backend, all BOMs will be lost once the service stops.")
+		return store.NewMemory(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported store backend %q", cfg.StoreBackend)
+	}
+}
+
+// reindex implements the `reindex` subcommand, it reconciles the metadata index
+// with the store backend and prints the report to stdout.
+func reindex(ctx context.Context, svc service.Service, args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report differences, do not fix the metadata index")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := svc.Reindex(ctx, !*dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
 }
 
 func initializeLogging(level slog.Level) {
@@ -2,7 +2,10 @@ package env
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/CZERTAINLY/CBOM-Repository/internal/http"
@@ -15,10 +18,13 @@ import (
 const defaultPrefix = "APP"
 
 type Config struct {
-	Store    store.Config
-	Http     http.Config
-	LogLevel slog.Level `envconfig:"APP_LOG_LEVEL" default:"INFO"`
-	Service  service.Config
+	StoreBackend string `envconfig:"APP_STORE_BACKEND" default:"s3"`
+	Store        store.Config
+	Filesystem   store.FilesystemConfig
+	Index        store.IndexConfig
+	Http         http.Config
+	LogLevel     slog.Level `envconfig:"APP_LOG_LEVEL" default:"INFO"`
+	Service      service.Config
 }
 
 func New() (Config, error) {
@@ -28,25 +34,112 @@ func New() (Config, error) {
 		return Config{}, err
 	}
 
-	if strings.TrimSpace(config.Store.Region) == "" {
-		return Config{}, errors.New("environment variable `APP_S3_REGION` must not contain whitespace characters only")
-	}
+	switch config.StoreBackend {
+	case store.BackendS3:
+		if err := checkS3(config.Store); err != nil {
+			return Config{}, err
+		}
 
-	if strings.TrimSpace(config.Store.Bucket) == "" {
-		return Config{}, errors.New("environment variable `APP_S3_BUCKET` must not contain whitespace characters only")
-	}
+	case store.BackendFilesystem:
+		if strings.TrimSpace(config.Filesystem.Dir) == "" {
+			return Config{}, errors.New("environment variable `APP_FS_DIR` must be set and must not contain whitespace characters only")
+		}
+
+	case store.BackendMemory:
+		// nothing to configure
 
-	if strings.TrimSpace(config.Store.AccessKey) == "" {
-		return Config{}, errors.New("environment variable `APP_S3_ACCESS_KEY` must not contain whitespace characters only")
+	default:
+		return Config{}, fmt.Errorf("environment variable `APP_STORE_BACKEND` has unsupported value %q, supported values: %s",
+			config.StoreBackend, []string{store.BackendS3, store.BackendFilesystem, store.BackendMemory})
 	}
 
-	if strings.TrimSpace(config.Store.SecretKey) == "" {
-		return Config{}, errors.New("environment variable `APP_S3_SECRET_KEY` must not contain whitespace characters only")
+	// the index is local to the process, other replicas writing into the same
+	// bucket would go unnoticed
+	if config.StoreBackend == store.BackendS3 && config.Index.File != "" && !config.Index.SingleWriter {
+		return Config{}, errors.New("environment variable `APP_INDEX_FILE` requires a single service instance writing into the s3 bucket, confirm it by setting `APP_INDEX_SINGLE_WRITER` to true")
 	}
 
 	if config.Http.MaxBodySize <= 0 {
 		return Config{}, errors.New("environment variable `APP_HTTP_MAX_BODY_SIZE` must be an integer greater than zero")
 	}
 
+	if config.Service.SearchMaxPageSize < 0 {
+		return Config{}, errors.New("environment variable `APP_SEARCH_MAX_PAGE_SIZE` must not be a negative integer")
+	}
+
+	if config.Service.MaxValidationErrors < 0 {
+		return Config{}, errors.New("environment variable `APP_MAX_VALIDATION_ERRORS` must not be a negative integer")
+	}
+
+	if config.Service.Upload.SpoolThreshold < 0 {
+		return Config{}, errors.New("environment variable `APP_UPLOAD_SPOOL_THRESHOLD` must not be a negative integer")
+	}
+
+	if err := checkRetention(config.Service.Retention); err != nil {
+		return Config{}, err
+	}
+
+	if err := checkSemantic(config.Service.Semantic); err != nil {
+		return Config{}, err
+	}
+
 	return config, nil
 }
+
+// checkRetention returns error if any of the retention settings is negative.
+func checkRetention(cfg service.RetentionConfig) error {
+	if cfg.KeepLast < 0 {
+		return errors.New("environment variable `APP_RETENTION_KEEP_LAST` must not be a negative integer")
+	}
+
+	if cfg.KeepDays < 0 {
+		return errors.New("environment variable `APP_RETENTION_KEEP_DAYS` must not be a negative integer")
+	}
+
+	if cfg.Interval < 0 {
+		return errors.New("environment variable `APP_RETENTION_INTERVAL` must not be a negative duration")
+	}
+
+	return nil
+}
+
+// checkSemantic returns error if any of the semantic validation rules has an
+// unsupported mode.
+func checkSemantic(cfg service.SemanticConfig) error {
+	modes := []service.RuleMode{service.RuleReject, service.RuleWarn, service.RuleOff}
+	vars := map[string]service.RuleMode{
+		"APP_SEMANTIC_BOM_REF_UNIQUE":        cfg.BOMRefUnique,
+		"APP_SEMANTIC_DEPENDENCY_REFS":       cfg.DependencyRefs,
+		"APP_SEMANTIC_OID_SYNTAX":            cfg.OIDSyntax,
+		"APP_SEMANTIC_ASSET_TYPE_PROPERTIES": cfg.AssetTypeProperties,
+	}
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		if !slices.Contains(modes, vars[name]) {
+			return fmt.Errorf("environment variable `%s` has unsupported value %q, supported values: %s", name, vars[name], modes)
+		}
+	}
+
+	return nil
+}
+
+// checkS3 returns error if any of the settings required by the s3 store backend
+// is missing or contains whitespace characters only.
+func checkS3(cfg store.Config) error {
+	if strings.TrimSpace(cfg.Region) == "" {
+		return errors.New("environment variable `APP_S3_REGION` must be set and must not contain whitespace characters only")
+	}
+
+	if strings.TrimSpace(cfg.Bucket) == "" {
+		return errors.New("environment variable `APP_S3_BUCKET` must be set and must not contain whitespace characters only")
+	}
+
+	if strings.TrimSpace(cfg.AccessKey) == "" {
+		return errors.New("environment variable `APP_S3_ACCESS_KEY` must be set and must not contain whitespace characters only")
+	}
+
+	if strings.TrimSpace(cfg.SecretKey) == "" {
+		return errors.New("environment variable `APP_S3_SECRET_KEY` must be set and must not contain whitespace characters only")
+	}
+
+	return nil
+}
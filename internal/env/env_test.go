@@ -3,6 +3,7 @@ package env_test
 import (
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/CZERTAINLY/CBOM-Repository/internal/env"
 	"github.com/CZERTAINLY/CBOM-Repository/internal/http"
@@ -34,6 +35,7 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: false,
 			want: env.Config{
+				StoreBackend: store.BackendS3,
 				Store: store.Config{
 					Region:       "eu-west-1",
 					Endpoint:     "http://localhost:9000",
@@ -49,7 +51,21 @@ func TestNewFunc(t *testing.T) {
 				},
 				LogLevel: slog.LevelDebug,
 				Service: service.Config{
-					CheckOnFetch: true,
+					CheckOnFetch:        true,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
 				},
 			},
 		},
@@ -64,6 +80,7 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: false,
 			want: env.Config{
+				StoreBackend: store.BackendS3,
 				Store: store.Config{
 					Region:       "eu-west-1",
 					Endpoint:     "http://localhost:9000",
@@ -79,10 +96,223 @@ func TestNewFunc(t *testing.T) {
 				},
 				LogLevel: slog.LevelInfo,
 				Service: service.Config{
-					CheckOnFetch: false,
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
 				},
 			},
 		},
+		"search max page size": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":        "memory",
+				"APP_SEARCH_MAX_PAGE_SIZE": "50",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					SearchMaxPageSize:   50,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"retention": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":       "memory",
+				"APP_RETENTION_KEEP_LAST": "10",
+				"APP_RETENTION_KEEP_DAYS": "30",
+				"APP_RETENTION_INTERVAL":  "15m",
+				"APP_RETENTION_PURGE":     "true",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						KeepLast: 10,
+						KeepDays: 30,
+						Interval: 15 * time.Minute,
+						Purge:    true,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"retention keep last must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":       "memory",
+				"APP_RETENTION_KEEP_LAST": "-1",
+			},
+			wantErr: true,
+		},
+		"retention keep days must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":       "memory",
+				"APP_RETENTION_KEEP_DAYS": "-1",
+			},
+			wantErr: true,
+		},
+		"retention interval must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":      "memory",
+				"APP_RETENTION_INTERVAL": "-1h",
+			},
+			wantErr: true,
+		},
+		"upload spool": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":          "memory",
+				"APP_UPLOAD_SPOOL_THRESHOLD": "1024",
+				"APP_UPLOAD_SPOOL_DIR":       "/var/tmp",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 1024,
+						SpoolDir:       "/var/tmp",
+					},
+				},
+			},
+		},
+		"upload spool threshold must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":          "memory",
+				"APP_UPLOAD_SPOOL_THRESHOLD": "-1",
+			},
+			wantErr: true,
+		},
+		"search max page size must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":        "memory",
+				"APP_SEARCH_MAX_PAGE_SIZE": "-1",
+			},
+			wantErr: true,
+		},
+		"semantic rule modes": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":                  "memory",
+				"APP_SEMANTIC_BOM_REF_UNIQUE":        "reject",
+				"APP_SEMANTIC_DEPENDENCY_REFS":       "reject",
+				"APP_SEMANTIC_ASSET_TYPE_PROPERTIES": "off",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleReject,
+						DependencyRefs:      service.RuleReject,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleOff,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"semantic rule mode must be supported": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":       "memory",
+				"APP_SEMANTIC_OID_SYNTAX": "ignore",
+			},
+			wantErr: true,
+		},
+		"max validation errors must not be negative": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND":         "memory",
+				"APP_MAX_VALIDATION_ERRORS": "-1",
+			},
+			wantErr: true,
+		},
 		"port must be a number": {
 			envVars: map[string]string{
 				"APP_S3_REGION":         "eu-west-1",
@@ -142,6 +372,7 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: false,
 			want: env.Config{
+				StoreBackend: store.BackendS3,
 				Store: store.Config{
 					Region:       "eu-west-1",
 					Endpoint:     "http://localhost:9000",
@@ -157,7 +388,21 @@ func TestNewFunc(t *testing.T) {
 				},
 				LogLevel: slog.LevelInfo,
 				Service: service.Config{
-					CheckOnFetch: false,
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
 				},
 			},
 		},
@@ -171,6 +416,7 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: false,
 			want: env.Config{
+				StoreBackend: store.BackendS3,
 				Store: store.Config{
 					Region:       "eu-west-1",
 					Endpoint:     "http://localhost:9000",
@@ -186,7 +432,21 @@ func TestNewFunc(t *testing.T) {
 				},
 				LogLevel: slog.LevelInfo,
 				Service: service.Config{
-					CheckOnFetch: false,
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
 				},
 			},
 		},
@@ -200,6 +460,7 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: false,
 			want: env.Config{
+				StoreBackend: store.BackendS3,
 				Store: store.Config{
 					Region:       "eu-west-1",
 					Bucket:       "czertainly",
@@ -214,7 +475,21 @@ func TestNewFunc(t *testing.T) {
 				},
 				LogLevel: slog.LevelInfo,
 				Service: service.Config{
-					CheckOnFetch: false,
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
 				},
 			},
 		},
@@ -294,6 +569,237 @@ func TestNewFunc(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"s3 store backend set explicitly": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "s3",
+				"APP_S3_REGION":     "eu-west-1",
+				"APP_S3_BUCKET":     "czertainly",
+				"APP_S3_ACCESS_KEY": "minioadmin",
+				"APP_S3_SECRET_KEY": "adminpassword",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendS3,
+				Store: store.Config{
+					Region:       "eu-west-1",
+					Bucket:       "czertainly",
+					AccessKey:    "minioadmin",
+					SecretKey:    "adminpassword",
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"filesystem store backend": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "filesystem",
+				"APP_FS_DIR":        "/var/lib/cbom-repository",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendFilesystem,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Filesystem: store.FilesystemConfig{
+					Dir: "/var/lib/cbom-repository",
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"filesystem store backend requires directory": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "filesystem",
+				"APP_FS_DIR":        "  ",
+			},
+			wantErr: true,
+		},
+		"memory store backend does not require s3 settings": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "memory",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"metadata index file": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "memory",
+				"APP_INDEX_FILE":    "/var/lib/cbom-repository/index.jsonl",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendMemory,
+				Store: store.Config{
+					UsePathStyle: true,
+				},
+				Index: store.IndexConfig{
+					File: "/var/lib/cbom-repository/index.jsonl",
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"metadata index file with s3 store backend requires single writer": {
+			envVars: map[string]string{
+				"APP_S3_REGION":     "eu-west-1",
+				"APP_S3_BUCKET":     "czertainly",
+				"APP_S3_ACCESS_KEY": "minioadmin",
+				"APP_S3_SECRET_KEY": "adminpassword",
+				"APP_INDEX_FILE":    "/var/lib/cbom-repository/index.jsonl",
+			},
+			wantErr: true,
+		},
+		"metadata index file with s3 store backend and single writer": {
+			envVars: map[string]string{
+				"APP_S3_REGION":           "eu-west-1",
+				"APP_S3_BUCKET":           "czertainly",
+				"APP_S3_ACCESS_KEY":       "minioadmin",
+				"APP_S3_SECRET_KEY":       "adminpassword",
+				"APP_INDEX_FILE":          "/var/lib/cbom-repository/index.jsonl",
+				"APP_INDEX_SINGLE_WRITER": "true",
+			},
+			wantErr: false,
+			want: env.Config{
+				StoreBackend: store.BackendS3,
+				Store: store.Config{
+					Region:       "eu-west-1",
+					Bucket:       "czertainly",
+					AccessKey:    "minioadmin",
+					SecretKey:    "adminpassword",
+					UsePathStyle: true,
+				},
+				Index: store.IndexConfig{
+					File:         "/var/lib/cbom-repository/index.jsonl",
+					SingleWriter: true,
+				},
+				Http: http.Config{
+					Port:        8080,
+					Prefix:      "/api",
+					MaxBodySize: 20971520,
+				},
+				LogLevel: slog.LevelInfo,
+				Service: service.Config{
+					CheckOnFetch:        false,
+					SearchMaxPageSize:   1000,
+					MaxValidationErrors: 20,
+					Semantic: service.SemanticConfig{
+						BOMRefUnique:        service.RuleWarn,
+						DependencyRefs:      service.RuleWarn,
+						OIDSyntax:           service.RuleWarn,
+						AssetTypeProperties: service.RuleWarn,
+					},
+					Retention: service.RetentionConfig{
+						Interval: time.Hour,
+					},
+					Upload: service.UploadConfig{
+						SpoolThreshold: 4194304,
+					},
+				},
+			},
+		},
+		"unsupported store backend": {
+			envVars: map[string]string{
+				"APP_STORE_BACKEND": "floppy",
+				"APP_S3_REGION":     "eu-west-1",
+				"APP_S3_BUCKET":     "czertainly",
+				"APP_S3_ACCESS_KEY": "minioadmin",
+				"APP_S3_SECRET_KEY": "adminpassword",
+			},
+			wantErr: true,
+		},
 		"empty environment": {
 			envVars: map[string]string{},
 			wantErr: true,
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/health"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Delete(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	serial := "urn:uuid:4a1e5a0e-2c5b-4f0e-8d0e-6b3b0c9d2e7f"
+	for range 2 {
+		body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+		_, err := svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+		require.NoError(t, err)
+	}
+
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 1024}, svc, healthSvc)
+
+	// executed in order, each case sees the result of the previous ones
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+		expected       *service.BOMDeleted
+	}{
+		{
+			name:           "invalid urn",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/not-an-urn",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid version",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?version=latest",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid purge",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?purge=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown version",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?version=3",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "soft delete version",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?version=2",
+			expectedStatus: http.StatusOK,
+			expected:       &service.BOMDeleted{SerialNumber: serial, Versions: []string{"2"}},
+		},
+		{
+			name:           "soft deleted version is not found",
+			method:         http.MethodGet,
+			target:         "/api/v1/bom/" + serial + "?version=2",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "soft delete already deleted version",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?version=2",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "purge serial number",
+			method:         http.MethodDelete,
+			target:         "/api/v1/bom/" + serial + "?purge=true",
+			expectedStatus: http.StatusOK,
+			expected:       &service.BOMDeleted{SerialNumber: serial, Versions: []string{"1", "2"}, Purged: true},
+		},
+		{
+			name:           "purged serial number is not found",
+			method:         http.MethodGet,
+			target:         "/api/v1/bom/" + serial + "/versions",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expected != nil {
+				var got service.BOMDeleted
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+				require.Equal(t, *tt.expected, got)
+			}
+		})
+	}
+}
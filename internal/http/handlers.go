@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -16,29 +19,35 @@ import (
 
 func (h Server) Upload(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	// Assert content type and optional version
-	ok, version := CheckContentType(r.Header.Get(HeaderContentType))
+	ok, mediaType, version := h.checkUploadContentType(w, r)
 	if !ok {
-		unsupportedMediaType(w,
-			fmt.Sprintf("Content type value '%s' not allowed for path '%s' and method '%s'. Supported content types: %s",
-				r.Header.Get(HeaderContentType), r.URL.Path, r.Method, []string{"application/vnd.cyclonedx+json"}))
-		return
-	}
-
-	if !h.service.VersionSupported(version) {
-		badrequest(w, fmt.Sprintf("Version '%s' not supported, supported versions: %s", version, h.service.SupportedVersion()))
 		return
 	}
 
 	slog.InfoContext(ctx, "Start.")
 
 	var maxErr *http.MaxBytesError
-	resp, err := h.service.UploadBOM(ctx, r.Body, version)
+	var schemaErr *service.SchemaError
+	var semanticErr *service.SemanticError
+	var policyErr *service.PolicyError
+	resp, err := h.service.UploadBOMOfType(ctx, r.Body, mediaType, version)
 	switch {
 	case errors.As(err, &maxErr):
 		requestTooLarge(w, "HTTP request body exceeded the maximum allowed size.")
 		return
 
+	case errors.As(err, &schemaErr):
+		badrequestSchema(w, fmt.Sprintf("Validating BOM failed: %s", err), schemaErr)
+		return
+
+	case errors.As(err, &semanticErr):
+		badrequestSemantic(w, fmt.Sprintf("Validating BOM failed: %s", err), semanticErr)
+		return
+
+	case errors.As(err, &policyErr):
+		badrequestPolicy(w, fmt.Sprintf("Validating BOM failed: %s", err), policyErr)
+		return
+
 	case errors.Is(err, service.ErrAlreadyExists):
 		conflict(w, fmt.Sprintf(
 			"Conflict with existing BOM, serial number '%s', version '%d'.",
@@ -67,6 +76,83 @@ func (h Server) Upload(w http.ResponseWriter, r *http.Request) {
 	))
 }
 
+// Validate runs the validation of Upload without storing the BOM and responds
+// with the serial number and version the upload would assign.
+func (h Server) Validate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ok, mediaType, version := h.checkUploadContentType(w, r)
+	if !ok {
+		return
+	}
+
+	slog.InfoContext(ctx, "Start.")
+
+	var maxErr *http.MaxBytesError
+	var schemaErr *service.SchemaError
+	var semanticErr *service.SemanticError
+	var policyErr *service.PolicyError
+	resp, err := h.service.ValidateBOM(ctx, r.Body, mediaType, version)
+	switch {
+	case errors.As(err, &maxErr):
+		requestTooLarge(w, "HTTP request body exceeded the maximum allowed size.")
+		return
+
+	case errors.Is(err, service.ErrAlreadyExists):
+		conflict(w, fmt.Sprintf(
+			"Conflict with existing BOM, serial number '%s', version '%d'.",
+			resp.SerialNumber, resp.Version))
+		return
+
+	case errors.As(err, &schemaErr):
+		badrequestSchema(w, fmt.Sprintf("Validating BOM failed: %s", err), schemaErr)
+		return
+
+	case errors.As(err, &semanticErr):
+		badrequestSemantic(w, fmt.Sprintf("Validating BOM failed: %s", err), semanticErr)
+		return
+
+	case errors.As(err, &policyErr):
+		badrequestPolicy(w, fmt.Sprintf("Validating BOM failed: %s", err), policyErr)
+		return
+
+	case errors.Is(err, service.ErrValidation):
+		badrequest(w, fmt.Sprintf("Validating BOM failed: %s", err))
+		return
+
+	case err != nil:
+		internal(w, fmt.Sprintf("Validating BOM failed: %s", err))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "Finished.")
+}
+
+// checkUploadContentType asserts the content type and the optional version
+// of an uploaded BOM, it responds with an error and returns false if either
+// is not supported.
+func (h Server) checkUploadContentType(w http.ResponseWriter, r *http.Request) (bool, string, string) {
+	ok, mediaType, version := CheckContentType(r.Header.Get(HeaderContentType))
+	if !ok {
+		unsupportedMediaType(w,
+			fmt.Sprintf("Content type value '%s' not allowed for path '%s' and method '%s'. Supported content types: %s",
+				r.Header.Get(HeaderContentType), r.URL.Path, r.Method, uploadMediaTypes))
+		return false, "", ""
+	}
+
+	if !h.service.VersionSupported(version) {
+		badrequest(w, fmt.Sprintf("Version '%s' not supported, supported versions: %s", version, h.service.SupportedVersion()))
+		return false, "", ""
+	}
+
+	return true, mediaType, version
+}
+
 func (s Server) GetByURN(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	vars := mux.Vars(r)
@@ -78,26 +164,53 @@ func (s Server) GetByURN(w http.ResponseWriter, r *http.Request) {
 
 	version := r.URL.Query().Get("version")
 
-	slog.InfoContext(ctx, "Start.", slog.String("urn", urn), slog.String("version", version))
+	// the representation depends on the accept header, caches must not mix them
+	w.Header().Add("Vary", "Accept")
+	ok, mediaType, specVersion := NegotiateBOMType(r.Header.Get(HeaderAccept))
+	if !ok {
+		notAcceptable(w, fmt.Sprintf("None of the accepted media types is supported. Supported media types: %s.", strings.Join(uploadMediaTypes, ", ")))
+		return
+	}
+
+	slog.InfoContext(ctx, "Start.", slog.String("urn", urn), slog.String("version", version),
+		slog.String("media-type", mediaType), slog.String("spec-version", specVersion))
 
-	resp, err := s.service.GetBOMByUrn(ctx, urn, version)
+	resp, err := s.service.OpenBOMByUrnAs(ctx, urn, version, mediaType, specVersion)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrNotFound):
 			notfound(w, "Requested BOM not found.")
 			return
+		case errors.Is(err, service.ErrNotAcceptable):
+			notAcceptable(w, fmt.Sprintf("Requested BOM can't be returned in the accepted representation: %s.", err))
+			return
 		}
 
 		internal(w, fmt.Sprintf("Failed to get the requested BOM: %s.", err))
 		return
 	}
+	defer func() {
+		_ = resp.Close()
+	}()
 
-	w.Header().Set("Content-Type", "application/vnd.cyclonedx+json")
-	if _, err := w.Write(resp); err != nil {
-		slog.ErrorContext(ctx, "Writing to http.ResponseWriter failed.", slog.String("error", err.Error()))
+	contentType := resp.ContentType
+	if specVersion != "" {
+		contentType = mime.FormatMediaType(contentType, map[string]string{"version": specVersion})
+	}
+	w.Header().Set("Content-Type", contentType)
+	if resp.ContentLength > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	if resp.ETag != "" {
+		w.Header().Set("ETag", resp.ETag)
+	}
+	// the status is sent with the first chunk, failures are only logged afterwards
+	n, err := io.Copy(w, resp)
+	if err != nil {
+		slog.ErrorContext(ctx, "Streaming BOM to http.ResponseWriter failed.", slog.String("error", err.Error()), slog.Int64("written", n))
 		return
 	}
-	slog.InfoContext(ctx, "Finished.")
+	slog.InfoContext(ctx, "Finished.", slog.Int64("size", n))
 }
 
 func validateURNPathVariable(w http.ResponseWriter, urn string) bool {
@@ -139,6 +252,91 @@ func (s Server) URNVersions(w http.ResponseWriter, r *http.Request) {
 	slog.InfoContext(ctx, "Finished.")
 }
 
+// Findings evaluates the configured policies against a stored BOM.
+func (s Server) Findings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	urn := vars["urn"]
+
+	if !validateURNPathVariable(w, urn) {
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+
+	slog.InfoContext(ctx, "Start.", slog.String("urn", urn), slog.String("version", version))
+
+	resp, err := s.service.EvaluateBOMByUrn(ctx, urn, version)
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		notfound(w, "Requested BOM not found.")
+		return
+
+	case err != nil:
+		internal(w, fmt.Sprintf("Failed to evaluate policies against the requested BOM: %s", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "Finished.", slog.Int("findings", len(resp.Findings)))
+}
+
+func (s Server) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	urn := vars["urn"]
+
+	if !validateURNPathVariable(w, urn) {
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+
+	purge := false
+	if v := r.URL.Query().Get("purge"); strings.TrimSpace(v) != "" {
+		var err error
+		if purge, err = strconv.ParseBool(v); err != nil {
+			badrequest(w, "Request validation failed, query parameter 'purge' must be a boolean.")
+			return
+		}
+	}
+
+	slog.InfoContext(ctx, "Start.", slog.String("urn", urn), slog.String("version", version), slog.Bool("purge", purge))
+
+	deleteBOM := s.service.DeleteBOM
+	if purge {
+		deleteBOM = s.service.PurgeBOM
+	}
+
+	resp, err := deleteBOM(ctx, urn, version)
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		badrequest(w, fmt.Sprintf("Request validation failed, query parameter 'version' is not valid: %s.", err))
+		return
+
+	case errors.Is(err, service.ErrNotFound):
+		notfound(w, "Requested BOM not found.")
+		return
+
+	case err != nil:
+		internal(w, fmt.Sprintf("Failed to delete the requested BOM: %s", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "Finished.", slog.Any("versions", resp.Versions), slog.Bool("purged", resp.Purged))
+}
+
 func (h Server) Search(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	after := r.URL.Query().Get("after")
@@ -154,19 +352,188 @@ func (h Server) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.InfoContext(ctx, "Start.", slog.String("after", after))
+	query, msg := parseSearchQuery(r.URL.Query())
+	if msg != "" {
+		badrequest(w, fmt.Sprintf("Request validation failed, %s.", msg))
+		return
+	}
+	query.After = i
 
-	resp, err := h.service.Search(ctx, i)
-	if err != nil {
+	if query.Before > 0 && query.Before <= query.After {
+		badrequest(w, "Request validation failed, query parameter 'before' must be greater than 'after'.")
+		return
+	}
+
+	slog.InfoContext(ctx, "Start.", slog.String("after", after), slog.Int("limit", query.Limit))
+
+	page, err := h.service.Search(ctx, query)
+	switch {
+	case errors.Is(err, service.ErrValidation):
+		badrequest(w, fmt.Sprintf("Request validation failed, query parameter 'cursor' is not valid: %s.", err))
+		return
+
+	case err != nil:
 		internal(w, fmt.Sprintf("Failed to get the requested BOM: %s.", err))
 		return
 	}
 
+	if page.Next != "" {
+		w.Header().Set("Link", nextPageLink(r, page.Next))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(page.Items); err != nil {
+		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "Finished.", slog.Int("response-count", len(page.Items)))
+}
+
+// parseSearchQuery parses the optional query parameters of the search, it
+// returns a message describing the first invalid parameter, if any.
+func parseSearchQuery(values url.Values) (service.SearchQuery, string) {
+	query := service.SearchQuery{
+		Cursor:             values.Get("cursor"),
+		SerialNumberPrefix: values.Get("serialNumberPrefix"),
+	}
+
+	if v := values.Get("limit"); strings.TrimSpace(v) != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 1 {
+			return service.SearchQuery{}, "query parameter 'limit' must be a positive integer"
+		}
+		query.Limit = i
+	}
+
+	nonNegative := []struct {
+		name  string
+		value *int
+	}{
+		{"minCryptoAssets", &query.CryptoStats.MinCryptoAssets},
+		{"minAlgorithms", &query.CryptoStats.MinAlgorithms},
+		{"minCertificates", &query.CryptoStats.MinCertificates},
+		{"minProtocols", &query.CryptoStats.MinProtocols},
+		{"minRelatedCryptoMaterials", &query.CryptoStats.MinRelatedCryptoMaterials},
+	}
+	flags := []struct {
+		name  string
+		value **bool
+	}{
+		{"hasAlgorithms", &query.CryptoStats.HasAlgorithms},
+		{"hasCertificates", &query.CryptoStats.HasCertificates},
+		{"hasProtocols", &query.CryptoStats.HasProtocols},
+		{"hasRelatedCryptoMaterials", &query.CryptoStats.HasRelatedCryptoMaterials},
+	}
+
+	for _, p := range nonNegative {
+		if v := values.Get(p.name); strings.TrimSpace(v) != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return service.SearchQuery{}, fmt.Sprintf("query parameter '%s' must be a non-negative integer", p.name)
+			}
+			*p.value = i
+		}
+	}
+
+	for _, p := range flags {
+		if v := values.Get(p.name); strings.TrimSpace(v) != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return service.SearchQuery{}, fmt.Sprintf("query parameter '%s' must be a boolean", p.name)
+			}
+			*p.value = &b
+		}
+	}
+
+	if v := values.Get("latestOnly"); strings.TrimSpace(v) != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return service.SearchQuery{}, "query parameter 'latestOnly' must be a boolean"
+		}
+		query.LatestOnly = b
+	}
+
+	if v := values.Get("before"); strings.TrimSpace(v) != "" {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || i < 1 {
+			return service.SearchQuery{}, "query parameter 'before' must be a positive integer (unixtime)"
+		}
+		query.Before = i
+	}
+
+	return query, ""
+}
+
+// nextPageLink returns value of the `Link` header pointing to the following
+// page, it is the request URL with the cursor replaced.
+func nextPageLink(r *http.Request, cursor string) string {
+	q := r.URL.Query()
+	q.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return fmt.Sprintf(`<%s>; rel="next"`, next.String())
+}
+
+func (s Server) Reindex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); strings.TrimSpace(v) != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			badrequest(w, "Request validation failed, query parameter 'dryRun' must be a boolean.")
+			return
+		}
+	}
+
+	slog.InfoContext(ctx, "Start.", slog.Bool("dryRun", dryRun))
+
+	resp, err := s.service.Reindex(ctx, !dryRun)
+	switch {
+	case errors.Is(err, service.ErrIndexDisabled):
+		conflict(w, "Metadata index is not enabled, set environment variable `APP_INDEX_FILE` to enable it.")
+		return
+
+	case err != nil:
+		internal(w, fmt.Sprintf("Reconciling metadata index failed: %s", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "Finished.",
+		slog.Int("missing", len(resp.Missing)),
+		slog.Int("stale", len(resp.Stale)),
+		slog.Int("orphaned", len(resp.Orphaned)),
+	)
+}
+
+// Retention reports BOM versions which the retention policy would prune,
+// nothing is pruned.
+func (s Server) Retention(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	slog.InfoContext(ctx, "Start.")
+
+	resp, err := s.service.ApplyRetention(ctx, true)
+	switch {
+	case errors.Is(err, service.ErrRetentionDisabled):
+		conflict(w, "Retention policy is not configured, set environment variable `APP_RETENTION_KEEP_LAST` or `APP_RETENTION_KEEP_DAYS` to enable it.")
+		return
+
+	case err != nil:
+		internal(w, fmt.Sprintf("Evaluating retention policy failed: %s", err))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err = json.NewEncoder(w).Encode(resp); err != nil {
 		slog.ErrorContext(ctx, "`json.NewEncoder()` failed", slog.String("error", err.Error()))
 		return
 	}
-	slog.InfoContext(ctx, "Finished.", slog.Int("response-count", len(resp)))
+	slog.InfoContext(ctx, "Finished.", slog.Int("pruned-serial-numbers", len(resp.Pruned)))
 }
@@ -2,12 +2,15 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -245,9 +248,17 @@ func TestUpload(t *testing.T) {
 			setupMocks:     func(s3c *mockS3.MockS3Contract, s3m *mockS3.MockS3Manager) {},
 			expectedStatus: http.StatusUnsupportedMediaType,
 		},
+		{
+			// a JSON document is no protobuf message
+			name:           "malformed protobuf",
+			contentType:    "application/x.vnd.cyclonedx+protobuf",
+			body:           validBOM,
+			setupMocks:     func(s3c *mockS3.MockS3Contract, s3m *mockS3.MockS3Manager) {},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "unsupported version",
-			contentType:    "application/vnd.cyclonedx+json; version=1.5",
+			contentType:    "application/vnd.cyclonedx+json; version=1.3",
 			body:           validBOM,
 			setupMocks:     func(s3c *mockS3.MockS3Contract, s3m *mockS3.MockS3Manager) {},
 			expectedStatus: http.StatusBadRequest,
@@ -366,6 +377,205 @@ func TestMaxUploadSize(t *testing.T) {
 	require.Equal(t, "HTTP request body exceeded the maximum allowed size.", p.Detail)
 }
 
+func TestUpload_SchemaViolations(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{MaxValidationErrors: 1})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [
+			{"type": "not-a-type", "name": "first"},
+			{"type": "not-a-type", "name": "second"}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/bom", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/vnd.cyclonedx+json; version=1.6")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var p problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	require.Equal(t, http.StatusBadRequest, p.Status)
+	require.Contains(t, p.Detail, "Validating BOM failed: validation failed: does not conform to the declared schema, showing 1 of ")
+	require.Len(t, p.Errors, 1)
+	require.NotEmpty(t, p.Errors[0].Pointer)
+	require.NotEmpty(t, p.Errors[0].Keyword)
+	require.NotEmpty(t, p.Errors[0].Detail)
+}
+
+func TestUpload_SemanticViolations(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{
+		MaxValidationErrors: 20,
+		Semantic:            service.SemanticConfig{DependencyRefs: service.RuleReject},
+	})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [{"type": "library", "name": "lib", "bom-ref": "lib"}],
+		"dependencies": [{"ref": "lib", "dependsOn": ["missing"]}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/bom", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/vnd.cyclonedx+json; version=1.6")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var p problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	require.Equal(t, "Validating BOM failed: validation failed: violates semantic rules", p.Detail)
+	require.Equal(t, []problemError{{
+		Pointer: "/dependencies/0/dependsOn/0",
+		Keyword: service.RuleDependencyRefs,
+		Detail:  `ref "missing" does not match a bom-ref of a component or service`,
+	}}, p.Errors)
+}
+
+func TestUpload_PolicyViolations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+policies:
+  - name: no-md5
+    mode: reject
+    names: [MD5]
+    forbidden: true
+`), 0o600))
+	svc, err := service.New(store.NewMemory(), service.Config{
+		MaxValidationErrors: 20,
+		Policy:              service.PolicyConfig{File: file},
+	})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [{"type": "cryptographic-asset", "name": "MD5", "cryptoProperties": {"assetType": "algorithm"}}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/bom", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/vnd.cyclonedx+json; version=1.6")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var p problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	require.Equal(t, "Validating BOM failed: validation failed: violates policies", p.Detail)
+	require.Equal(t, []problemError{{
+		Pointer: "/components/0",
+		Keyword: "no-md5",
+		Detail:  `"MD5" is forbidden`,
+	}}, p.Errors)
+}
+
+func TestFindings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+policies:
+  - name: tls-version
+    mode: record
+    protocolTypes: [tls]
+    minVersion: "1.2"
+`), 0o600))
+	svc, err := service.New(store.NewMemory(), service.Config{
+		MaxValidationErrors: 20,
+		Policy:              service.PolicyConfig{File: file},
+	})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"version": 1,
+		"components": [{"type": "cryptographic-asset", "name": "TLS", "bom-ref": "tls",
+			"cryptoProperties": {"assetType": "protocol", "protocolProperties": {"type": "tls", "version": "1.0"}}}]
+	}`
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/bom/urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79/findings?version=1", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get(HeaderContentType))
+	require.JSONEq(t, `{
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"version": "1",
+		"findings": [{"policy": "tls-version", "mode": "record", "severity": "low", "pointer": "/components/0", "bomRef": "tls", "message": "\"TLS\" version 1.0 is below 1.2"}]
+	}`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/bom/urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79/findings?version=2", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/bom/not-an-urn/findings", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestValidate(t *testing.T) {
+	backend := store.NewMemory()
+	svc, err := service.New(backend, service.Config{
+		MaxValidationErrors: 20,
+		Semantic:            service.SemanticConfig{BOMRefUnique: service.RuleWarn},
+	})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"components": [
+			{"type": "library", "name": "first", "bom-ref": "lib"},
+			{"type": "library", "name": "second", "bom-ref": "lib"}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/bom/validate", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/vnd.cyclonedx+json; version=1.6")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var got service.BOMValidated
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Equal(t, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", got.SerialNumber)
+	require.Equal(t, 1, got.Version)
+	require.Len(t, got.Warnings, 1)
+
+	// nothing was stored
+	keys, err := backend.Search(context.Background(), 0)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// the content type is checked as for the upload
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/bom/validate", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/json")
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
 func TestGetByURN(t *testing.T) {
 	validURN := "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
 	invalidURN := "invalid-urn"
@@ -491,6 +701,119 @@ func TestGetByURN(t *testing.T) {
 	}
 }
 
+func TestGetByURN_Streaming(t *testing.T) {
+	backend := store.NewMemory()
+	urn := "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
+	contents := `{"bomFormat":"CycloneDX","specVersion":"1.6"}`
+	require.NoError(t, backend.Upload(context.Background(), urn+"-1", store.Metadata{Version: "1"}, []byte(contents)))
+
+	svc, err := service.New(backend, service.Config{})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api"}, svc, healthSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/bom/"+urn, nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/vnd.cyclonedx+json", w.Header().Get("Content-Type"))
+	require.Equal(t, fmt.Sprint(len(contents)), w.Header().Get("Content-Length"))
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	require.Equal(t, contents, w.Body.String())
+}
+
+func TestUpload_XML(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 4096}, svc, healthSvc)
+
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<bom xmlns="http://cyclonedx.org/schema/bom/1.6" serialNumber="urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79" version="1"></bom>`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/bom", strings.NewReader(body))
+	req.Header.Set(HeaderContentType, "application/vnd.cyclonedx+xml; version=1.6")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// the BOM is returned as uploaded
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/bom/urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/vnd.cyclonedx+xml", w.Header().Get("Content-Type"))
+	require.Equal(t, body, w.Body.String())
+}
+
+func TestGetByURN_Accept(t *testing.T) {
+	backend := store.NewMemory()
+	urn := "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
+	contents := `{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":"` + urn + `","version":1}`
+	require.NoError(t, backend.Upload(context.Background(), urn+"-1", store.Metadata{Version: "1"}, []byte(contents)))
+
+	svc, err := service.New(backend, service.Config{})
+	require.NoError(t, err)
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api"}, svc, healthSvc)
+
+	tests := map[string]struct {
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		"stored representation": {
+			accept:          "application/vnd.cyclonedx+json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.cyclonedx+json",
+			wantBody:        contents,
+		},
+		"xml": {
+			accept:          "application/vnd.cyclonedx+xml",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.cyclonedx+xml",
+			wantBody:        `xmlns="http://cyclonedx.org/schema/bom/1.6"`,
+		},
+		"older spec version": {
+			accept:          "application/vnd.cyclonedx+json; version=1.5",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.cyclonedx+json; version=1.5",
+			wantBody:        `"specVersion":"1.5"`,
+		},
+		"unknown spec version": {
+			accept:          "application/vnd.cyclonedx+json; version=0.9",
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/problem+json",
+		},
+		"unsupported media type": {
+			accept:          "text/html",
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/problem+json",
+		},
+		"protobuf": {
+			accept:          "application/x.vnd.cyclonedx+protobuf",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x.vnd.cyclonedx+protobuf",
+			wantBody:        urn,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/bom/"+urn, nil)
+			req.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			require.Equal(t, tc.wantContentType, w.Header().Get("Content-Type"))
+			require.Equal(t, "Accept", w.Header().Get("Vary"))
+			require.Contains(t, w.Body.String(), tc.wantBody)
+		})
+	}
+}
+
 func TestSearch(t *testing.T) {
 	now := time.Now()
 
@@ -522,6 +845,20 @@ func TestSearch(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			prefix:         "/api",
 		},
+		{
+			name:           "invalid limit parameter",
+			after:          "1672531200&limit=0",
+			setupMocks:     func(s3c *mockS3.MockS3Contract) {},
+			expectedStatus: http.StatusBadRequest,
+			prefix:         "/api",
+		},
+		{
+			name:           "invalid cursor parameter",
+			after:          "1672531200&cursor=abc",
+			setupMocks:     func(s3c *mockS3.MockS3Contract) {},
+			expectedStatus: http.StatusBadRequest,
+			prefix:         "/api",
+		},
 		{
 			name:  "successful search",
 			after: "1672531200",
@@ -620,6 +957,92 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearch_Pagination(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{SearchMaxPageSize: 10})
+	require.NoError(t, err)
+	for range 3 {
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+		require.NoError(t, err)
+	}
+
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 1024}, svc, healthSvc)
+
+	var items []service.SearchRes
+	next := "/api/v1/bom?after=0&limit=4"
+	for next != "" {
+		req := httptest.NewRequest(http.MethodGet, next, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page []service.SearchRes
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		items = append(items, page...)
+
+		next = ""
+		if link := w.Header().Get("Link"); link != "" {
+			require.True(t, strings.HasPrefix(link, "</api/v1/bom?"), link)
+			require.True(t, strings.HasSuffix(link, `>; rel="next"`), link)
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			require.Contains(t, next, "after=0")
+			require.Contains(t, next, "limit=4")
+		}
+	}
+	// three originals and three versions
+	require.Len(t, items, 6)
+}
+
+func TestSearch_Filters(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+	require.NoError(t, err)
+
+	healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+	server := New(Config{Prefix: "/api", MaxBodySize: 1024}, svc, healthSvc)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "latest only", query: "latestOnly=true", expectedStatus: http.StatusOK, expectedCount: 1},
+		{name: "has no certificates", query: "hasCertificates=false", expectedStatus: http.StatusOK, expectedCount: 2},
+		{name: "has certificates", query: "hasCertificates=true", expectedStatus: http.StatusOK, expectedCount: 0},
+		{name: "serial number prefix", query: "serialNumberPrefix=urn:uuid:", expectedStatus: http.StatusOK, expectedCount: 2},
+		{name: "before in the future", query: fmt.Sprintf("before=%d", time.Now().Add(time.Hour).Unix()), expectedStatus: http.StatusOK, expectedCount: 2},
+		{name: "min algorithms", query: "minAlgorithms=1", expectedStatus: http.StatusOK, expectedCount: 0},
+		{name: "invalid latest only", query: "latestOnly=sometimes", expectedStatus: http.StatusBadRequest},
+		{name: "invalid has certificates", query: "hasCertificates=1x", expectedStatus: http.StatusBadRequest},
+		{name: "negative min algorithms", query: "minAlgorithms=-1", expectedStatus: http.StatusBadRequest},
+		{name: "invalid before", query: "before=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "before not after after", query: "before=100&after=100", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/v1/bom?" + tt.query
+			if !strings.Contains(tt.query, "after=") {
+				target += "&after=0"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response []service.SearchRes
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				require.Len(t, response, tt.expectedCount)
+			}
+		})
+	}
+}
+
 func TestNotFoundHandler(t *testing.T) {
 	tests := []struct {
 		name   string
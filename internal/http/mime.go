@@ -1,8 +1,13 @@
 package http
 
 import (
+	"cmp"
 	"mime"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
 )
 
 const defaultBOMVersion = "1.6"
@@ -10,22 +15,89 @@ const defaultBOMVersion = "1.6"
 // HeaderContentType is the canonical key used when reading the request header for content type.
 const HeaderContentType = "content-type"
 
-func CheckContentType(contentType string) (bool, string) {
+// uploadMediaTypes are the media types accepted by the upload.
+var uploadMediaTypes = []string{service.MediaTypeJSON, service.MediaTypeXML, service.MediaTypeProtobuf}
+
+// CheckContentType parses the content type of an uploaded BOM, it returns
+// false if the media type is not supported, otherwise the media type and the
+// CycloneDX version from the `version` parameter, which defaults to the
+// newest supported version.
+func CheckContentType(contentType string) (bool, string, string) {
 	if strings.TrimSpace(contentType) == "" {
-		return false, ""
+		return false, "", ""
 	}
 
 	t, p, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return false, ""
+		return false, "", ""
 	}
-	if t != "application/vnd.cyclonedx+json" {
-		return false, ""
+	if !slices.Contains(uploadMediaTypes, t) {
+		return false, "", ""
 	}
 	version, ok := p["version"]
 	if !ok {
 		version = defaultBOMVersion
 	}
 
-	return true, version
+	return true, t, version
+}
+
+// HeaderAccept is the canonical key used when reading the request header for accepted media types.
+const HeaderAccept = "accept"
+
+// acceptAliases maps generic media types to the BOM media type they select.
+var acceptAliases = map[string]string{
+	"application/json": service.MediaTypeJSON,
+	"application/xml":  service.MediaTypeXML,
+	"text/xml":         service.MediaTypeXML,
+}
+
+// NegotiateBOMType selects the representation of a BOM from the accept
+// header. The media ranges are tried in the order of their quality, the first
+// supported one wins. It returns false if no media range is supported,
+// otherwise the media type and the CycloneDX version from the `version`
+// parameter. Both are empty if the stored representation is acceptable, as
+// for an empty header or wildcards.
+func NegotiateBOMType(accept string) (bool, string, string) {
+	if strings.TrimSpace(accept) == "" {
+		return true, "", ""
+	}
+
+	type mediaRange struct {
+		mediaType string
+		version   string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		t, p, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := p["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{mediaType: t, version: p["version"], q: q})
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		return cmp.Compare(b.q, a.q)
+	})
+
+	for _, r := range ranges {
+		switch {
+		case r.mediaType == "*/*", r.mediaType == "application/*":
+			return true, "", ""
+		case slices.Contains(uploadMediaTypes, r.mediaType):
+			return true, r.mediaType, r.version
+		case acceptAliases[r.mediaType] != "":
+			return true, acceptAliases[r.mediaType], r.version
+		}
+	}
+	return false, "", ""
 }
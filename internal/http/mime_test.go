@@ -9,9 +9,10 @@ import (
 
 func TestUploadInputChecks(t *testing.T) {
 	testCases := map[string]struct {
-		input   string
-		wantErr bool
-		version string
+		input     string
+		wantErr   bool
+		mediaType string
+		version   string
 	}{
 		"empty": {
 			input:   "",
@@ -22,14 +23,32 @@ func TestUploadInputChecks(t *testing.T) {
 			wantErr: true,
 		},
 		"missing version": {
-			input:   "application/vnd.cyclonedx+json",
-			wantErr: false,
-			version: "1.6",
+			input:     "application/vnd.cyclonedx+json",
+			wantErr:   false,
+			mediaType: "application/vnd.cyclonedx+json",
+			version:   "1.6",
 		},
 		"expected content type": {
-			input:   "application/vnd.cyclonedx+json; Version = 1.4",
-			wantErr: false,
-			version: "1.4",
+			input:     "application/vnd.cyclonedx+json; Version = 1.4",
+			wantErr:   false,
+			mediaType: "application/vnd.cyclonedx+json",
+			version:   "1.4",
+		},
+		"xml": {
+			input:     "application/vnd.cyclonedx+xml; version=1.6",
+			wantErr:   false,
+			mediaType: "application/vnd.cyclonedx+xml",
+			version:   "1.6",
+		},
+		"protobuf": {
+			input:     "application/x.vnd.cyclonedx+protobuf; version=1.6",
+			wantErr:   false,
+			mediaType: "application/x.vnd.cyclonedx+protobuf",
+			version:   "1.6",
+		},
+		"unexpected-2": {
+			input:   "application/xml",
+			wantErr: true,
 		},
 		"unexpected-1": {
 			input:   "application/json",
@@ -39,11 +58,86 @@ func TestUploadInputChecks(t *testing.T) {
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
-			ok, version := internalHttp.CheckContentType(tc.input)
+			ok, mediaType, version := internalHttp.CheckContentType(tc.input)
+			if tc.wantErr {
+				require.False(t, ok)
+			} else {
+				require.True(t, ok)
+				require.Equal(t, tc.mediaType, mediaType)
+				require.Equal(t, tc.version, version)
+			}
+		})
+	}
+}
+
+func TestNegotiateBOMType(t *testing.T) {
+	testCases := map[string]struct {
+		input     string
+		wantErr   bool
+		mediaType string
+		version   string
+	}{
+		"empty": {
+			input: "",
+		},
+		"any": {
+			input: "*/*",
+		},
+		"any application": {
+			input: "application/*",
+		},
+		"json": {
+			input:     "application/vnd.cyclonedx+json",
+			mediaType: "application/vnd.cyclonedx+json",
+		},
+		"xml with version": {
+			input:     "application/vnd.cyclonedx+xml; version=1.5",
+			mediaType: "application/vnd.cyclonedx+xml",
+			version:   "1.5",
+		},
+		"generic json": {
+			input:     "application/json",
+			mediaType: "application/vnd.cyclonedx+json",
+		},
+		"generic xml": {
+			input:     "text/xml",
+			mediaType: "application/vnd.cyclonedx+xml",
+		},
+		"first supported": {
+			input:     "text/html, application/vnd.cyclonedx+xml, */*",
+			mediaType: "application/vnd.cyclonedx+xml",
+		},
+		"quality": {
+			input:     "application/vnd.cyclonedx+json; q=0.5, application/vnd.cyclonedx+xml; version=1.4",
+			mediaType: "application/vnd.cyclonedx+xml",
+			version:   "1.4",
+		},
+		"excluded": {
+			input:     "application/vnd.cyclonedx+xml; q=0, application/json; q=0.1",
+			mediaType: "application/vnd.cyclonedx+json",
+		},
+		"protobuf": {
+			input:     "text/html, application/x.vnd.cyclonedx+protobuf",
+			mediaType: "application/x.vnd.cyclonedx+protobuf",
+		},
+		"unsupported": {
+			input:   "text/html, application/pdf",
+			wantErr: true,
+		},
+		"malformed": {
+			input:   "application/vnd.cyclonedx+json; q=high",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ok, mediaType, version := internalHttp.NegotiateBOMType(tc.input)
 			if tc.wantErr {
 				require.False(t, ok)
 			} else {
 				require.True(t, ok)
+				require.Equal(t, tc.mediaType, mediaType)
 				require.Equal(t, tc.version, version)
 			}
 		})
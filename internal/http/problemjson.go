@@ -2,9 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
 )
 
 const (
@@ -28,6 +31,59 @@ func badrequest(w http.ResponseWriter, detail string) {
 	p.Json(w)
 }
 
+// badrequestSchema responds with the schema violations listed in the
+// `errors` member of the problem, the detail notes if the list was capped.
+func badrequestSchema(w http.ResponseWriter, detail string, schemaErr *service.SchemaError) {
+	errs := make([]problemError, 0, len(schemaErr.Violations))
+	for _, v := range schemaErr.Violations {
+		errs = append(errs, problemError{
+			Pointer: v.Pointer,
+			Keyword: v.Keyword,
+			Detail:  v.Message,
+		})
+	}
+	badrequestErrors(w, detail, errs, schemaErr.Total)
+}
+
+// badrequestSemantic responds with the semantic rule violations listed in
+// the `errors` member of the problem, the rule is the keyword.
+func badrequestSemantic(w http.ResponseWriter, detail string, semanticErr *service.SemanticError) {
+	errs := make([]problemError, 0, len(semanticErr.Issues))
+	for _, issue := range semanticErr.Issues {
+		errs = append(errs, problemError{
+			Pointer: issue.Pointer,
+			Keyword: issue.Rule,
+			Detail:  issue.Message,
+		})
+	}
+	badrequestErrors(w, detail, errs, semanticErr.Total)
+}
+
+// badrequestPolicy responds with the findings of the rejecting policies
+// listed in the `errors` member of the problem, the policy is the keyword.
+func badrequestPolicy(w http.ResponseWriter, detail string, policyErr *service.PolicyError) {
+	errs := make([]problemError, 0, len(policyErr.Findings))
+	for _, finding := range policyErr.Findings {
+		errs = append(errs, problemError{
+			Pointer: finding.Pointer,
+			Keyword: finding.Policy,
+			Detail:  finding.Message,
+		})
+	}
+	badrequestErrors(w, detail, errs, policyErr.Total)
+}
+
+func badrequestErrors(w http.ResponseWriter, detail string, errs []problemError, total int) {
+	if len(errs) < total {
+		detail = fmt.Sprintf("%s, showing %d of %d errors", detail, len(errs), total)
+	}
+	p := template(detail, http.StatusBadRequest)
+	if len(errs) > 0 {
+		p.Errors = errs
+	}
+	p.Json(w)
+}
+
 func internal(w http.ResponseWriter, detail string) {
 	p := template(detail, http.StatusInternalServerError)
 	p.Json(w)
@@ -48,6 +104,11 @@ func unsupportedMediaType(w http.ResponseWriter, detail string) {
 	p.Json(w)
 }
 
+func notAcceptable(w http.ResponseWriter, detail string) {
+	p := template(detail, http.StatusNotAcceptable)
+	p.Json(w)
+}
+
 func requestTooLarge(w http.ResponseWriter, detail string) {
 	p := template(detail, http.StatusRequestEntityTooLarge)
 	p.Json(w)
@@ -60,6 +121,16 @@ type problem struct {
 	Detail    string `json:"detail,omitempty"`
 	Instance  string `json:"instance,omitempty"`
 	Timestamp string `json:"timestamp,omitempty"`
+	// Errors is an extension member listing the individual failures.
+	Errors []problemError `json:"errors,omitempty"`
+}
+
+// problemError is a single failure of a problem.
+type problemError struct {
+	// Pointer is the JSON pointer of the failing part of the request body.
+	Pointer string `json:"pointer"`
+	Keyword string `json:"keyword,omitempty"`
+	Detail  string `json:"detail"`
 }
 
 func (p problem) Json(w http.ResponseWriter) {
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/health"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Reindex(t *testing.T) {
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	tests := []struct {
+		name           string
+		backend        store.Backend
+		query          string
+		expectedStatus int
+	}{
+		{
+			name:           "index disabled",
+			backend:        store.NewMemory(),
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "invalid dry run",
+			backend:        store.NewIndexed(store.NewMemory(), idx),
+			query:          "?dryRun=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "dry run",
+			backend:        store.NewIndexed(store.NewMemory(), idx),
+			query:          "?dryRun=true",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "fix",
+			backend:        store.NewIndexed(store.NewMemory(), idx),
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, err := service.New(tt.backend, service.Config{})
+			require.NoError(t, err)
+
+			healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+			server := New(Config{Prefix: "/api", MaxBodySize: 1024}, svc, healthSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/reindex"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if w.Code == http.StatusOK {
+				var report store.ReindexReport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+				require.Equal(t, tt.query == "", report.Fixed)
+			}
+		})
+	}
+}
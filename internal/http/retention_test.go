@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/health"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Retention(t *testing.T) {
+	backend := store.NewMemory()
+	serial := "urn:uuid:4a1e5a0e-2c5b-4f0e-8d0e-6b3b0c9d2e7f"
+
+	tests := []struct {
+		name           string
+		retention      service.RetentionConfig
+		expectedStatus int
+		expected       []service.BOMDeleted
+	}{
+		{
+			name:           "retention disabled",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "dry run",
+			retention:      service.RetentionConfig{KeepLast: 1},
+			expectedStatus: http.StatusOK,
+			expected:       []service.BOMDeleted{{SerialNumber: serial, Versions: []string{"2", "1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, err := service.New(backend, service.Config{Retention: tt.retention})
+			require.NoError(t, err)
+			for range 3 {
+				body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+				_, err := svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+				require.NoError(t, err)
+			}
+			t.Cleanup(func() {
+				_, _ = svc.PurgeBOM(context.Background(), serial, "")
+			})
+
+			healthSvc := health.NewService(mockChecker{name: "storage", status: health.StatusUp})
+			server := New(Config{Prefix: "/api", MaxBodySize: 1024}, svc, healthSvc)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/retention", nil)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if w.Code == http.StatusOK {
+				var report service.RetentionReport
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+				require.True(t, report.DryRun)
+				require.Equal(t, tt.expected, report.Pruned)
+
+				// nothing was pruned
+				versions, err := svc.UrnVersions(context.Background(), serial)
+				require.NoError(t, err)
+				require.Len(t, versions, 3)
+			}
+		})
+	}
+}
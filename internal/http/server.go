@@ -18,10 +18,15 @@ const (
 	V1Prefix         = "/v1"
 	RouteBOM         = V1Prefix + "/bom"
 	RouteBOMByURN    = RouteBOM + "/{urn}"
+	RouteBOMValidate = RouteBOM + "/validate"
 	RouteBOMVersions = RouteBOMByURN + "/versions"
+	RouteBOMFindings = RouteBOMByURN + "/findings"
 	RouteHealth      = V1Prefix + "/health"
 	RouteHealthLive  = RouteHealth + "/liveness"
 	RouteHealthReady = RouteHealth + "/readiness"
+	RouteAdmin       = V1Prefix + "/admin"
+	RouteReindex     = RouteAdmin + "/reindex"
+	RouteRetention   = RouteAdmin + "/retention"
 )
 
 type Config struct {
@@ -58,8 +63,13 @@ func (s *Server) Handler() *mux.Router {
 
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOM), s.Upload).Methods(http.MethodPost)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOM), s.Search).Methods(http.MethodGet)
+	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOMValidate), s.Validate).Methods(http.MethodPost)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOMByURN), s.GetByURN).Methods(http.MethodGet)
+	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOMByURN), s.Delete).Methods(http.MethodDelete)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOMVersions), s.URNVersions).Methods(http.MethodGet)
+	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteBOMFindings), s.Findings).Methods(http.MethodGet)
+	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteReindex), s.Reindex).Methods(http.MethodPost)
+	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteRetention), s.Retention).Methods(http.MethodGet)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteHealth), s.HealthHandler).Methods(http.MethodGet)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteHealthLive), s.LivenessHandler).Methods(http.MethodGet)
 	r.HandleFunc(fmt.Sprintf("%s%s", s.cfg.Prefix, RouteHealthReady), s.ReadinessHandler).Methods(http.MethodGet)
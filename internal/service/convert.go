@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+)
+
+var ErrNotAcceptable = errors.New("requested representation not available")
+
+// OpenBOMByUrnAs is OpenBOMByUrn returning the BOM in the requested media type
+// and CycloneDX spec version. The stored document is streamed as-is if it is
+// already in the requested representation, otherwise it is decoded and encoded
+// again, cyclonedx-go drops fields not known to an older spec version.
+// The crypto properties, which cyclonedx-go keeps, are dropped as well.
+// Converted documents are held in memory and carry no entity tag.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - urn: The URN identifier of the BOM (format: urn:uuid:<uuid>)
+//   - version: The specific version to retrieve, or empty string for latest version
+//   - mediaType: MediaTypeJSON, MediaTypeXML or MediaTypeProtobuf, or empty string for the stored media type
+//   - specVersion: CycloneDX spec version (e.g. "1.5"), or empty string for the stored one
+//
+// Returns:
+//   - store.ObjectReader: The BOM document reader, its content type is the media type
+//     of the returned document, the caller must close it
+//   - error: ErrNotAcceptable if the BOM can't be converted to the requested media type
+//     or spec version, the errors of OpenBOMByUrn otherwise
+func (s Service) OpenBOMByUrnAs(ctx context.Context, urn, version, mediaType, specVersion string) (store.ObjectReader, error) {
+	obj, err := s.OpenBOMByUrn(ctx, urn, version)
+	if err != nil {
+		return store.ObjectReader{}, err
+	}
+
+	stored := storedMediaType(obj.ContentType)
+	obj.ContentType = stored
+	if mediaType == "" {
+		mediaType = stored
+	}
+	if mediaType == stored && specVersion == "" {
+		return obj, nil
+	}
+
+	ctx = log.ContextAttrs(ctx,
+		slog.String("media-type", mediaType),
+		slog.String("spec-version", specVersion),
+	)
+	defer func() {
+		_ = obj.Close()
+	}()
+
+	format, ok := bomFileFormat(mediaType)
+	if !ok {
+		return store.ObjectReader{}, fmt.Errorf("%w: unsupported media type %s", ErrNotAcceptable, mediaType)
+	}
+
+	b, err := io.ReadAll(obj)
+	if err != nil {
+		slog.ErrorContext(ctx, "`io.ReadAll()` failed.", slog.String("error", err.Error()))
+		return store.ObjectReader{}, err
+	}
+
+	storedFormat, _ := bomFileFormat(stored)
+	bom, err := decodeBOM(bytes.NewReader(b), storedFormat)
+	if err != nil {
+		slog.ErrorContext(ctx, "`cdx.Decode()` failed.", slog.String("error", err.Error()))
+		return store.ObjectReader{}, errors.New("BOM fetched from backend storage is malformed")
+	}
+
+	targetVersion := bom.SpecVersion
+	if specVersion != "" {
+		targetVersion, err = knownCdxVersion(specVersion)
+		if err != nil {
+			return store.ObjectReader{}, fmt.Errorf("%w: %s", ErrNotAcceptable, err)
+		}
+	}
+	if mediaType == stored && targetVersion == bom.SpecVersion {
+		// already in the requested representation
+		return store.ObjectReader{
+			ReadCloser:    io.NopCloser(bytes.NewReader(b)),
+			ContentLength: int64(len(b)),
+			ContentType:   stored,
+		}, nil
+	}
+
+	if targetVersion < cdx.SpecVersion1_6 {
+		dropCryptoProperties(&bom)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeBOM(&buf, &bom, format, targetVersion); err != nil {
+		slog.DebugContext(ctx, "`encodeBOM()` failed.", slog.String("error", err.Error()))
+		return store.ObjectReader{}, fmt.Errorf("%w: %s", ErrNotAcceptable, err)
+	}
+	slog.DebugContext(ctx, "BOM converted.",
+		slog.String("stored-media-type", stored),
+		slog.String("stored-spec-version", bom.SpecVersion.String()),
+	)
+
+	return store.ObjectReader{
+		ReadCloser:    io.NopCloser(&buf),
+		ContentLength: int64(buf.Len()),
+		ContentType:   mediaType,
+	}, nil
+}
+
+// dropCryptoProperties removes the crypto properties of all the components of
+// the BOM, including the nested ones, they are not known to spec versions older
+// than 1.6.
+func dropCryptoProperties(bom *cdx.BOM) {
+	var drop func(components *[]cdx.Component)
+	drop = func(components *[]cdx.Component) {
+		if components == nil {
+			return
+		}
+		for i := range *components {
+			(*components)[i].CryptoProperties = nil
+			drop((*components)[i].Components)
+		}
+	}
+	if bom.Metadata != nil && bom.Metadata.Component != nil {
+		bom.Metadata.Component.CryptoProperties = nil
+		drop(bom.Metadata.Component.Components)
+	}
+	drop(bom.Components)
+}
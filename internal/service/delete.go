@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+)
+
+// BOMDeleted lists the versions of a BOM removed by DeleteBOM or PurgeBOM.
+type BOMDeleted struct {
+	SerialNumber string   `json:"serialNumber"`
+	Versions     []string `json:"versions"`
+	// Purged is true if the versions were removed permanently.
+	Purged bool `json:"purged"`
+}
+
+// DeleteBOM soft deletes a version of a BOM, or all of its versions if
+// `version` is empty. Soft deleted versions are marked with tombstone metadata,
+// they are hidden from Search, UrnVersions and GetBOMByUrn, but they still
+// occupy their version number until they are purged.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - urn: The URN identifier of the BOM (format: urn:uuid:<uuid>)
+//   - version: A numeric version, "original", or empty string for all versions
+//
+// Returns:
+//   - BOMDeleted: The versions which were soft deleted
+//   - error: ErrValidation if the version is malformed, ErrNotFound if there
+//     is no version left to delete, other errors if the store operation fails
+func (s Service) DeleteBOM(ctx context.Context, urn, version string) (BOMDeleted, error) {
+	ctx = log.ContextAttrs(ctx,
+		slog.String("urn", urn),
+		slog.String("version", version),
+	)
+
+	versions, err := s.bomVersions(ctx, urn, version)
+	if err != nil {
+		return BOMDeleted{}, err
+	}
+
+	deletedAt := time.Now().UTC().Format(time.RFC3339)
+	res := BOMDeleted{SerialNumber: urn, Versions: []string{}}
+	for _, v := range versions {
+		key := fmt.Sprintf("%s-%s", urn, v)
+		head, err := s.store.GetHeadObject(ctx, key)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// purged in the meantime
+			continue
+
+		case err != nil:
+			return BOMDeleted{}, err
+		}
+		if store.Deleted(head.Metadata) {
+			continue
+		}
+
+		meta := store.ParseMetadata(head.Metadata)
+		meta.DeletedAt = deletedAt
+		err = s.store.UpdateMetadata(ctx, key, meta)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			continue
+
+		case err != nil:
+			return BOMDeleted{}, err
+		}
+		res.Versions = append(res.Versions, v)
+	}
+
+	if len(res.Versions) == 0 {
+		return BOMDeleted{}, ErrNotFound
+	}
+	slog.InfoContext(ctx, "BOM versions soft deleted.", slog.Any("versions", res.Versions))
+	return res, nil
+}
+
+// PurgeBOM permanently removes a version of a BOM, or all of its versions if
+// `version` is empty, including the soft deleted ones. Purged version numbers
+// may be reused by later uploads.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - urn: The URN identifier of the BOM (format: urn:uuid:<uuid>)
+//   - version: A numeric version, "original", or empty string for all versions
+//
+// Returns:
+//   - BOMDeleted: The versions which were purged
+//   - error: ErrValidation if the version is malformed, ErrNotFound if the
+//     BOM or the version does not exist, other errors if the store operation fails
+func (s Service) PurgeBOM(ctx context.Context, urn, version string) (BOMDeleted, error) {
+	ctx = log.ContextAttrs(ctx,
+		slog.String("urn", urn),
+		slog.String("version", version),
+	)
+
+	versions, err := s.bomVersions(ctx, urn, version)
+	if err != nil {
+		return BOMDeleted{}, err
+	}
+
+	res := BOMDeleted{SerialNumber: urn, Versions: []string{}, Purged: true}
+	for _, v := range versions {
+		if err := s.store.Delete(ctx, fmt.Sprintf("%s-%s", urn, v)); err != nil {
+			return BOMDeleted{}, err
+		}
+		res.Versions = append(res.Versions, v)
+	}
+
+	slog.InfoContext(ctx, "BOM versions purged.", slog.Any("versions", res.Versions))
+	return res, nil
+}
+
+// bomVersions returns the stored versions of a BOM, including soft deleted
+// ones, narrowed down to `version` unless it is empty.
+func (s Service) bomVersions(ctx context.Context, urn, version string) ([]string, error) {
+	if version != "" {
+		if !versionValid(version) {
+			return nil, fmt.Errorf("%w: version %q is neither a positive integer nor \"original\"", ErrValidation, version)
+		}
+
+		exists, err := s.store.KeyExists(ctx, fmt.Sprintf("%s-%s", urn, version))
+		switch {
+		case err != nil:
+			return nil, err
+
+		case !exists:
+			return nil, ErrNotFound
+		}
+		return []string{version}, nil
+	}
+
+	versions, hasOriginal, err := s.store.GetObjectVersions(ctx, urn)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, ErrNotFound
+
+	case err != nil:
+		return nil, err
+	}
+	return versionNames(versions, hasOriginal), nil
+}
+
+// versionNames returns numeric versions as strings followed by "original",
+// if present.
+func versionNames(versions []int, hasOriginal bool) []string {
+	var res []string
+	for _, v := range versions {
+		res = append(res, strconv.Itoa(v))
+	}
+	if hasOriginal {
+		res = append(res, "original")
+	}
+	return res
+}
+
+// versionValid returns true if `version` is a positive integer or "original".
+func versionValid(version string) bool {
+	if version == "original" {
+		return true
+	}
+	i, err := strconv.Atoi(version)
+	return err == nil && i > 0
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteBOM_SoftDelete(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	serial := "urn:uuid:7f3c2a8e-0d0c-4a4e-9a57-3f0b1b6c1d11"
+	for range 3 {
+		body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+		require.NoError(t, err)
+	}
+
+	deleted, err := svc.DeleteBOM(ctx, serial, "3")
+	require.NoError(t, err)
+	require.Equal(t, service.BOMDeleted{SerialNumber: serial, Versions: []string{"3"}}, deleted)
+
+	// deleting a tombstone again finds nothing to delete
+	_, err = svc.DeleteBOM(ctx, serial, "3")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	_, err = svc.GetBOMByUrn(ctx, serial, "3")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	// the latest version which is not deleted is returned
+	b, err := svc.GetBOMByUrn(ctx, serial, "")
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"version":2`)
+
+	versions, err := svc.UrnVersions(ctx, serial)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	res, err := svc.Search(ctx, service.SearchQuery{})
+	require.NoError(t, err)
+	require.Len(t, res.Items, 2)
+
+	// tombstones keep their version number occupied
+	body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+	created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Equal(t, 4, created.Version)
+
+	// deleting the whole serial number skips existing tombstones
+	deleted, err = svc.DeleteBOM(ctx, serial, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "2", "4"}, deleted.Versions)
+
+	_, err = svc.GetBOMByUrn(ctx, serial, "")
+	require.ErrorIs(t, err, service.ErrNotFound)
+	_, err = svc.UrnVersions(ctx, serial)
+	require.ErrorIs(t, err, service.ErrNotFound)
+	res, err = svc.Search(ctx, service.SearchQuery{})
+	require.NoError(t, err)
+	require.Empty(t, res.Items)
+}
+
+func TestPurgeBOM(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	// no serial number - both the original and version 1 are stored
+	created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+	require.NoError(t, err)
+	serial := created.SerialNumber
+
+	_, err = svc.DeleteBOM(ctx, serial, "1")
+	require.NoError(t, err)
+
+	// purge removes soft deleted versions as well
+	purged, err := svc.PurgeBOM(ctx, serial, "1")
+	require.NoError(t, err)
+	require.Equal(t, service.BOMDeleted{SerialNumber: serial, Versions: []string{"1"}, Purged: true}, purged)
+
+	_, err = svc.PurgeBOM(ctx, serial, "1")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	// purged version number is free again
+	body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+	created, err = svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Equal(t, 1, created.Version)
+
+	purged, err = svc.PurgeBOM(ctx, serial, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "original"}, purged.Versions)
+
+	_, err = svc.UrnVersions(ctx, serial)
+	require.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestDeleteBOM_InvalidVersion(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	for _, version := range []string{"0", "-1", "latest"} {
+		_, err := svc.DeleteBOM(context.Background(), "urn:uuid:7f3c2a8e-0d0c-4a4e-9a57-3f0b1b6c1d11", version)
+		require.ErrorIs(t, err, service.ErrValidation, version)
+		_, err = svc.PurgeBOM(context.Background(), "urn:uuid:7f3c2a8e-0d0c-4a4e-9a57-3f0b1b6c1d11", version)
+		require.ErrorIs(t, err, service.ErrValidation, version)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+)
+
+// BOMFindings is the outcome of the evaluation of a stored BOM against the
+// configured policies.
+type BOMFindings struct {
+	SerialNumber string `json:"serialNumber"`
+	// Version is the stored version evaluated, either a number or "original".
+	Version string `json:"version"`
+	// Findings of all the policies regardless of their mode, in the order of
+	// the components, empty if the BOM violates none.
+	Findings []PolicyFinding `json:"findings"`
+}
+
+// EvaluateBOMByUrn evaluates the currently configured policies against a
+// stored BOM, so that BOMs uploaded before a policy was added or changed can
+// be audited without uploading them again. Findings stored along with the BOM
+// at upload are not consulted. See GetBOMByUrn for the version selection, the
+// document is read through OpenBOMByUrn to decode it in its stored media type.
+// The version reported is the stored one, which differs from the version
+// declared by the document for the "original" version.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - urn: The URN identifier of the BOM (format: urn:uuid:<uuid>)
+//   - version: The specific version to evaluate, or empty string for latest version
+//
+// Returns:
+//   - BOMFindings: The findings of all the policies, not capped
+//   - error: Returns ErrNotFound if the URN or version doesn't exist or is soft
+//     deleted, or other errors from the store or decoding
+func (s Service) EvaluateBOMByUrn(ctx context.Context, urn, version string) (BOMFindings, error) {
+	obj, version, err := s.openBOMByUrn(ctx, urn, version)
+	if err != nil {
+		return BOMFindings{}, err
+	}
+	defer func() {
+		_ = obj.Close()
+	}()
+
+	ctx = log.ContextAttrs(ctx,
+		slog.String("urn", urn),
+		slog.String("version", version),
+	)
+
+	format, _ := bomFileFormat(storedMediaType(obj.ContentType))
+	bom, err := decodeBOM(obj, format)
+	if err != nil {
+		slog.ErrorContext(ctx, "`cdx.Decode()` failed.", slog.String("error", err.Error()))
+		return BOMFindings{}, errors.New("BOM fetched from backend storage is malformed")
+	}
+
+	findings := evaluatePolicies(&bom, s.policies)
+	if findings == nil {
+		findings = []PolicyFinding{}
+	}
+	slog.DebugContext(ctx, "Policies evaluated.", slog.Int("policies", len(s.policies)), slog.Int("findings", len(findings)))
+
+	return BOMFindings{
+		SerialNumber: urn,
+		Version:      version,
+		Findings:     findings,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"io"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+)
+
+// Media types of the supported BOM serializations.
+const (
+	MediaTypeJSON     = "application/vnd.cyclonedx+json"
+	MediaTypeXML      = "application/vnd.cyclonedx+xml"
+	MediaTypeProtobuf = "application/x.vnd.cyclonedx+protobuf"
+)
+
+// xmlNamespacePrefix is the XML namespace of a CycloneDX spec version without
+// the version.
+const xmlNamespacePrefix = "http://cyclonedx.org/schema/bom/"
+
+// bomFileFormatProtobuf is the file format of the protobuf serialization,
+// which cyclonedx-go doesn't know. It is understood by decodeBOM and
+// encodeBOM only, it must not be passed to cyclonedx-go.
+const bomFileFormatProtobuf = cdx.BOMFileFormatJSON + 1
+
+// bomFileFormat returns the cyclonedx-go file format of a BOM media type.
+func bomFileFormat(mediaType string) (cdx.BOMFileFormat, bool) {
+	switch mediaType {
+	case MediaTypeJSON:
+		return cdx.BOMFileFormatJSON, true
+	case MediaTypeXML:
+		return cdx.BOMFileFormatXML, true
+	case MediaTypeProtobuf:
+		return bomFileFormatProtobuf, true
+	default:
+		return 0, false
+	}
+}
+
+// storedMediaType returns the media type of a stored BOM from the content
+// type of its object. Objects stored before the media type was recorded carry
+// a plain JSON content type.
+func storedMediaType(contentType string) string {
+	if _, ok := bomFileFormat(contentType); ok {
+		return contentType
+	}
+	return MediaTypeJSON
+}
+
+// decodeBOM decodes a BOM serialized in `format`. XML and protobuf documents
+// carry no format field, XML ones are identified by the namespace, which the
+// decoder turns into the spec version, so the format field is filled in.
+func decodeBOM(r io.Reader, format cdx.BOMFileFormat) (cdx.BOM, error) {
+	if format == bomFileFormatProtobuf {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return cdx.BOM{}, err
+		}
+		return decodeProtoBOM(b)
+	}
+
+	var bom cdx.BOM
+	if err := cdx.NewBOMDecoder(r, format).Decode(&bom); err != nil {
+		return cdx.BOM{}, err
+	}
+	if format == cdx.BOMFileFormatXML {
+		bom.BOMFormat = cdx.BOMFormat
+	}
+	return bom, nil
+}
+
+// encodeBOM encodes the BOM in `format` and the spec version `specVersion`.
+// A BOM of the same spec version is encoded as-is, the conversion of
+// cyclonedx-go v0.10 turns cryptographic assets into applications. The
+// protobuf serialization is converted to an older spec version through JSON,
+// as cyclonedx-go converts BOMs only while encoding them.
+func encodeBOM(w io.Writer, bom *cdx.BOM, format cdx.BOMFileFormat, specVersion cdx.SpecVersion) error {
+	if format != bomFileFormatProtobuf {
+		encoder := cdx.NewBOMEncoder(w, format)
+		if specVersion != bom.SpecVersion {
+			return encoder.EncodeVersion(bom, specVersion)
+		}
+		if format == cdx.BOMFileFormatXML {
+			// the namespace is set by the conversion only
+			xmlBOM := *bom
+			xmlBOM.XMLNS = xmlNamespacePrefix + specVersion.String()
+			bom = &xmlBOM
+		}
+		return encoder.Encode(bom)
+	}
+
+	if specVersion != bom.SpecVersion {
+		var buf bytes.Buffer
+		if err := cdx.NewBOMEncoder(&buf, cdx.BOMFileFormatJSON).EncodeVersion(bom, specVersion); err != nil {
+			return err
+		}
+		converted, err := decodeBOM(&buf, cdx.BOMFileFormatJSON)
+		if err != nil {
+			return err
+		}
+		bom = &converted
+	}
+	b, err := encodeProtoBOM(bom)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
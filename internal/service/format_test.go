@@ -0,0 +1,312 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const xmlBOM = `<?xml version="1.0" encoding="UTF-8"?>
+<bom xmlns="http://cyclonedx.org/schema/bom/1.6" version="1">
+  <components>
+    <component type="cryptographic-asset" bom-ref="crypto/algorithm/aes-128-gcm">
+      <name>AES-128-GCM</name>
+      <cryptoProperties>
+        <assetType>algorithm</assetType>
+      </cryptoProperties>
+    </component>
+  </components>
+</bom>`
+
+func TestUploadBOMOfType_XML(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+	svc, err := service.New(backend, service.Config{CheckOnFetch: true})
+	require.NoError(t, err)
+
+	created, err := svc.UploadBOMOfType(ctx, io.NopCloser(strings.NewReader(xmlBOM)), service.MediaTypeXML, "1.6")
+	require.NoError(t, err)
+	require.Equal(t, 1, created.Version)
+	require.Equal(t, 1, created.CryptoStats.CryptoAsset.Algo.Total)
+
+	// the original is stored as uploaded, along with its media type
+	head, err := backend.GetHeadObject(ctx, created.SerialNumber+"-original")
+	require.NoError(t, err)
+	require.Equal(t, service.MediaTypeXML, head.ContentType)
+	require.Equal(t, service.MediaTypeXML, head.Metadata[store.MetaContentTypeKey])
+	original, err := backend.GetObject(ctx, created.SerialNumber+"-original")
+	require.NoError(t, err)
+	require.Equal(t, xmlBOM, string(original))
+
+	// the modified version is encoded as XML as well
+	obj, err := svc.OpenBOMByUrn(ctx, created.SerialNumber, "")
+	require.NoError(t, err)
+	defer func() {
+		_ = obj.Close()
+	}()
+	require.Equal(t, service.MediaTypeXML, obj.ContentType)
+	b, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.Contains(t, string(b), fmt.Sprintf("serialNumber=%q", created.SerialNumber))
+}
+
+func TestUploadBOMOfType_XMLValidation(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{MaxValidationErrors: 20})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		body    string
+		pointer string
+	}{
+		"unknown namespace": {
+			body: `<bom xmlns="http://example.com/schema/bom/1.6" version="1"></bom>`,
+		},
+		"different spec version": {
+			body: `<bom xmlns="http://cyclonedx.org/schema/bom/1.5" version="1"></bom>`,
+		},
+		"schema violation": {
+			body: `<bom xmlns="http://cyclonedx.org/schema/bom/1.6" version="1">
+  <components>
+    <component type="cryptographic-asset">
+      <name>AES-128-GCM</name>
+      <cryptoProperties>
+        <assetType>not-an-asset-type</assetType>
+      </cryptoProperties>
+    </component>
+  </components>
+</bom>`,
+			pointer: "/bom/components/component[1]/cryptoProperties/assetType",
+		},
+		// elements and attributes unknown to the decoder are dropped by it,
+		// the raw document is validated against the XML schema
+		"unknown element": {
+			body: `<bom xmlns="http://cyclonedx.org/schema/bom/1.6" version="1">
+  <components>
+    <component type="library">
+      <name>lib</name>
+      <color>blue</color>
+    </component>
+  </components>
+</bom>`,
+			pointer: "/bom/components/component[1]/color[1]",
+		},
+		"unknown attribute": {
+			body: `<bom xmlns="http://cyclonedx.org/schema/bom/1.6" version="1">
+  <components>
+    <component type="library">
+      <name>lib</name>
+      <hashes>
+        <hash alg="SHA-256" color="blue">9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08</hash>
+      </hashes>
+    </component>
+  </components>
+</bom>`,
+			pointer: "/bom/components/component[1]/hashes/hash[1]/@color",
+		},
+		"elements out of order": {
+			body: `<bom xmlns="http://cyclonedx.org/schema/bom/1.6" version="1">
+  <components>
+    <component type="library">
+      <version>1.0</version>
+      <name>lib</name>
+    </component>
+  </components>
+</bom>`,
+			pointer: "/bom/components/component[1]/version",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.UploadBOMOfType(context.Background(), io.NopCloser(strings.NewReader(tc.body)), service.MediaTypeXML, "1.6")
+			require.ErrorIs(t, err, service.ErrValidation)
+			if tc.pointer == "" {
+				return
+			}
+			var schemaErr *service.SchemaError
+			require.ErrorAs(t, err, &schemaErr)
+			require.Equal(t, tc.pointer, schemaErr.Violations[0].Pointer)
+		})
+	}
+
+	// malformed documents fail decoding
+	_, err = svc.UploadBOMOfType(context.Background(), io.NopCloser(strings.NewReader("<bom")), service.MediaTypeXML, "1.6")
+	require.Error(t, err)
+}
+
+func TestUploadBOMOfType_Protobuf(t *testing.T) {
+	ctx := context.Background()
+
+	// the protobuf document is the XML BOM converted by the service
+	xmlSvc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+	created, err := xmlSvc.UploadBOMOfType(ctx, io.NopCloser(strings.NewReader(xmlBOM)), service.MediaTypeXML, "1.6")
+	require.NoError(t, err)
+	obj, err := xmlSvc.OpenBOMByUrnAs(ctx, created.SerialNumber, "", service.MediaTypeProtobuf, "")
+	require.NoError(t, err)
+	require.Equal(t, service.MediaTypeProtobuf, obj.ContentType)
+	protoBOM, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.NoError(t, obj.Close())
+
+	backend := store.NewMemory()
+	svc, err := service.New(backend, service.Config{CheckOnFetch: true, MaxValidationErrors: 20})
+	require.NoError(t, err)
+	uploaded, err := svc.UploadBOMOfType(ctx, io.NopCloser(bytes.NewReader(protoBOM)), service.MediaTypeProtobuf, "1.6")
+	require.NoError(t, err)
+	require.Equal(t, created.SerialNumber, uploaded.SerialNumber)
+	require.Equal(t, 1, uploaded.Version)
+	require.Equal(t, 1, uploaded.CryptoStats.CryptoAsset.Algo.Total)
+
+	// the BOM is stored as uploaded, along with its media type
+	key := fmt.Sprintf("%s-%d", uploaded.SerialNumber, uploaded.Version)
+	head, err := backend.GetHeadObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, service.MediaTypeProtobuf, head.ContentType)
+	stored, err := backend.GetObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, protoBOM, stored)
+
+	obj, err = svc.OpenBOMByUrnAs(ctx, uploaded.SerialNumber, "", "", "")
+	require.NoError(t, err)
+	require.Equal(t, service.MediaTypeProtobuf, obj.ContentType)
+	b, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.NoError(t, obj.Close())
+	require.Equal(t, protoBOM, b)
+
+	obj, err = svc.OpenBOMByUrnAs(ctx, uploaded.SerialNumber, "", service.MediaTypeJSON, "")
+	require.NoError(t, err)
+	b, err = io.ReadAll(obj)
+	require.NoError(t, err)
+	require.NoError(t, obj.Close())
+	require.Contains(t, string(b), `"name":"AES-128-GCM"`)
+	require.Contains(t, string(b), `"assetType":"algorithm"`)
+
+	message := func(fields ...[]byte) []byte {
+		return bytes.Join(fields, nil)
+	}
+	str := func(num protowire.Number, s string) []byte {
+		return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), s)
+	}
+	varint := func(num protowire.Number, v uint64) []byte {
+		return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+	}
+	embed := func(num protowire.Number, m []byte) []byte {
+		return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), m)
+	}
+
+	// the decoded BOM is validated against the JSON schema
+	invalid := message(str(1, "1.6"), varint(2, 1), embed(5, message(
+		varint(1, 13), // cryptographic-asset
+		str(8, "ML-KEM-1024"),
+		embed(27, message(
+			varint(1, 1), // algorithm
+			embed(2, varint(11, 7)),
+		)),
+	)))
+	_, err = svc.UploadBOMOfType(ctx, io.NopCloser(bytes.NewReader(invalid)), service.MediaTypeProtobuf, "1.6")
+	var schemaErr *service.SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	require.Equal(t, "/components/0/cryptoProperties/algorithmProperties/nistQuantumSecurityLevel", schemaErr.Violations[0].Pointer)
+
+	// fields the codec doesn't cover are rejected instead of being dropped
+	services := message(str(1, "1.6"), varint(2, 1), embed(6, str(4, "api")))
+	_, err = svc.UploadBOMOfType(ctx, io.NopCloser(bytes.NewReader(services)), service.MediaTypeProtobuf, "1.6")
+	require.ErrorIs(t, err, service.ErrValidation)
+	require.ErrorContains(t, err, "field 6 is not supported")
+}
+
+func TestOpenBOMByUrnAs(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	created, err := svc.UploadBOMOfType(ctx, io.NopCloser(strings.NewReader(xmlBOM)), service.MediaTypeXML, "1.6")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		mediaType   string
+		specVersion string
+		wantType    string
+		wantBody    []string
+		wantErr     error
+	}{
+		"stored representation": {
+			wantType: service.MediaTypeXML,
+			wantBody: []string{`xmlns="http://cyclonedx.org/schema/bom/1.6"`, "<cryptoProperties>"},
+		},
+		"json": {
+			mediaType: service.MediaTypeJSON,
+			wantType:  service.MediaTypeJSON,
+			wantBody:  []string{`"specVersion":"1.6"`, `"type":"cryptographic-asset"`, `"cryptoProperties"`},
+		},
+		"same spec version": {
+			mediaType:   service.MediaTypeXML,
+			specVersion: "1.6",
+			wantType:    service.MediaTypeXML,
+			wantBody:    []string{`xmlns="http://cyclonedx.org/schema/bom/1.6"`},
+		},
+		"downgrade drops crypto properties": {
+			mediaType:   service.MediaTypeJSON,
+			specVersion: "1.5",
+			wantType:    service.MediaTypeJSON,
+			wantBody:    []string{`"specVersion":"1.5"`},
+		},
+		"unknown spec version": {
+			mediaType:   service.MediaTypeJSON,
+			specVersion: "2.0",
+			wantErr:     service.ErrNotAcceptable,
+		},
+		"protobuf": {
+			mediaType: service.MediaTypeProtobuf,
+			wantType:  service.MediaTypeProtobuf,
+			wantBody:  []string{"1.6", "AES-128-GCM", "crypto/algorithm/aes-128-gcm"},
+		},
+		"unknown media type": {
+			mediaType: "text/html",
+			wantErr:   service.ErrNotAcceptable,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj, err := svc.OpenBOMByUrnAs(ctx, created.SerialNumber, "", tc.mediaType, tc.specVersion)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer func() {
+				_ = obj.Close()
+			}()
+			require.Equal(t, tc.wantType, obj.ContentType)
+			b, err := io.ReadAll(obj)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(b)), obj.ContentLength)
+			for _, want := range tc.wantBody {
+				require.Contains(t, string(b), want)
+			}
+			if tc.specVersion == "1.5" {
+				require.NotContains(t, string(b), "cryptoProperties")
+				// the converted BOM conforms to the older schema
+				empty, err := service.New(store.NewMemory(), service.Config{})
+				require.NoError(t, err)
+				_, err = empty.ValidateBOM(ctx, io.NopCloser(bytes.NewReader(b)), tc.wantType, tc.specVersion)
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	_, err = svc.OpenBOMByUrnAs(ctx, "urn:uuid:00000000-0000-0000-0000-000000000000", "", service.MediaTypeJSON, "1.5")
+	require.ErrorIs(t, err, service.ErrNotFound)
+}
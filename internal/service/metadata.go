@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+)
+
+// metadataReserve holds the longest values of the metadata set after the
+// statistics and the findings are encoded, a version number and the time of
+// a soft delete, so that the metadata still fit once they are set.
+var metadataReserve = store.Metadata{
+	Version:   strconv.Itoa(math.MaxInt),
+	DeletedAt: time.Time{}.Format(time.RFC3339),
+}
+
+// storedMetadata returns the metadata shared by all the objects stored for an
+// uploaded BOM, the version is set by the caller. The metadata must fit
+// store.MaxMetadataSize, the statistics come first: their breakdowns are
+// folded to fit, see fitCryptoStats, the findings get the rest of the room
+// and are trimmed to fit it, see encodeFindings.
+func storedMetadata(cryptoStats CryptoStats, findings []PolicyFinding, mediaType string) (store.Metadata, error) {
+	meta := metadataReserve
+	meta.ContentType = mediaType
+
+	// the room of the findings which are always kept, their total
+	minimal, err := encodeFindings(findings, 0)
+	if err != nil {
+		return store.Metadata{}, err
+	}
+	meta.PolicyFindings = minimal
+
+	b, err := fitCryptoStats(cryptoStats, store.MaxMetadataSize-meta.Size())
+	if err != nil {
+		return store.Metadata{}, err
+	}
+	meta.CryptoStats = string(b)
+
+	meta.PolicyFindings = ""
+	limit := store.MaxMetadataSize - meta.Size() - len(store.MetaPolicyFindingsKey)
+	if meta.PolicyFindings, err = encodeFindings(findings, limit); err != nil {
+		return store.Metadata{}, err
+	}
+
+	meta.Version, meta.DeletedAt = "", ""
+	return meta, nil
+}
+
+// fitCryptoStats encodes the statistics in at most `limit` bytes if possible.
+// The breakdowns are folded to fewer entries until the statistics fit, and
+// left out at last. The statistics passed in are not modified.
+func fitCryptoStats(cryptoStats CryptoStats, limit int) ([]byte, error) {
+	for _, b := range cryptoStats.breakdowns() {
+		*b = maps.Clone(*b)
+	}
+	for entries := maxBreakdownEntries; ; entries-- {
+		b, err := marshalASCII(cryptoStats)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) <= limit {
+			return b, nil
+		}
+		for _, breakdown := range cryptoStats.breakdowns() {
+			if entries > 1 {
+				breakdown.fold(entries - 1)
+			} else {
+				*breakdown = nil
+			}
+		}
+		if entries == 0 {
+			// the totals alone don't fit
+			return b, nil
+		}
+	}
+}
+
+// marshalASCII is json.Marshal escaping all the characters out of the ASCII
+// range. Non-ASCII metadata values are encoded by the S3 clients as RFC 2047
+// words, which would make them longer than counted.
+func marshalASCII(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		switch {
+		case r < utf8.RuneSelf:
+			buf.WriteByte(byte(r))
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&buf, `\u%04x\u%04x`, r1, r2)
+		default:
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoredMetadata_LongNames(t *testing.T) {
+	level := 3
+	var components []cdx.Component
+	for i := range 40 {
+		long := fmt.Sprintf("%d-Älgörithm-%s", i, strings.Repeat("ž", 60))
+		components = append(components,
+			cdx.Component{
+				Type: cdx.ComponentTypeCryptographicAsset,
+				Name: long,
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType: cdx.CryptoAssetTypeAlgorithm,
+					AlgorithmProperties: &cdx.CryptoAlgorithmProperties{
+						Primitive:                cdx.CryptoPrimitive(long),
+						ParameterSetIdentifier:   long,
+						NistQuantumSecurityLevel: &level,
+					},
+				},
+			},
+			cdx.Component{
+				Type: cdx.ComponentTypeCryptographicAsset,
+				Name: "TLS",
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType:          cdx.CryptoAssetTypeProtocol,
+					ProtocolProperties: &cdx.CryptoProtocolProperties{Type: cdx.CryptoProtocolType(long), Version: long},
+				},
+			},
+		)
+	}
+	bom := cdx.BOM{Components: &components}
+	cryptoStats := CalculateCryptoStats(context.Background(), &bom)
+	for key := range cryptoStats.CryptoAsset.Algo.ByName {
+		require.LessOrEqual(t, utf8.RuneCountInString(key), maxBreakdownKeyLength)
+	}
+
+	findings := make([]PolicyFinding, 50)
+	for i := range findings {
+		findings[i] = PolicyFinding{
+			Policy:  "forbidden-" + strings.Repeat("ž", 40),
+			Mode:    PolicyWarn,
+			Pointer: fmt.Sprintf("/components/%d", i),
+			Message: fmt.Sprintf("%q is forbidden", components[i].Name),
+		}
+	}
+
+	meta, err := storedMetadata(cryptoStats, findings, MediaTypeXML)
+	require.NoError(t, err)
+	require.Empty(t, meta.Version)
+	require.Empty(t, meta.DeletedAt)
+	require.Equal(t, MediaTypeXML, meta.ContentType)
+
+	// the metadata fit once the version and the soft delete time are set
+	meta.Version = strconv.Itoa(math.MaxInt)
+	meta.DeletedAt = time.Now().UTC().Format(time.RFC3339)
+	require.LessOrEqual(t, meta.Size(), store.MaxMetadataSize)
+	for key, value := range meta.Map() {
+		for _, r := range value {
+			require.Less(t, r, rune(utf8.RuneSelf), "value of %s is not ASCII", key)
+		}
+	}
+
+	var stored CryptoStats
+	require.NoError(t, json.Unmarshal([]byte(meta.CryptoStats), &stored))
+	require.Equal(t, 80, stored.CryptoAsset.Total)
+	require.Equal(t, 40, stored.CryptoAsset.Algo.Total)
+	require.Equal(t, 40, stored.Quantum.Safe)
+	require.Less(t, len(stored.CryptoAsset.Algo.ByName), maxBreakdownEntries)
+
+	var storedFindings PolicyFindings
+	require.NoError(t, json.Unmarshal([]byte(meta.PolicyFindings), &storedFindings))
+	require.Equal(t, 50, storedFindings.Total)
+	require.Less(t, len(storedFindings.Findings), 50)
+	if len(storedFindings.Findings) > 0 {
+		require.Equal(t, findings[0], storedFindings.Findings[0])
+	}
+
+	// the statistics passed in are left intact
+	require.Len(t, cryptoStats.CryptoAsset.Algo.ByName, maxBreakdownEntries)
+}
+
+func TestMarshalASCII(t *testing.T) {
+	b, err := marshalASCII(map[string]string{"name": "Ž-🔑"})
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"\u017d-\ud83d\udd11"}`, string(b))
+
+	var v map[string]string
+	require.NoError(t, json.Unmarshal(b, &v))
+	require.Equal(t, "Ž-🔑", v["name"])
+}
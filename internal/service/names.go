@@ -0,0 +1,108 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// compoundAlgorithmNames are algorithm families named by several words. They
+// are matched as a single word, so that "DSA" matches neither "ML-DSA" nor
+// "EdDSA".
+var compoundAlgorithmNames = []string{
+	"ML-KEM", "ML-DSA", "SLH-DSA", "FN-DSA", "EdDSA", "FrodoKEM", "Classic-McEliece",
+}
+
+// gluedAlgorithmNames are words written without a separator, mapped to the
+// words they are made of, e.g. "RSASSA-PSS" is an RSA signature scheme.
+var gluedAlgorithmNames = map[string][]string{
+	"RSASSA": {"RSA", "SSA"},
+	"RSAES":  {"RSA", "ES"},
+}
+
+// nameMatches reports whether the algorithm name contains the pattern as a
+// sequence of whole words, see algorithmNameWords. So "SHA-1" matches "SHA1",
+// "HMAC-SHA1" and "sha1WithRSAEncryption", but not "SHA-192", and "DH" does
+// not match "ECDH" or "DHE".
+func nameMatches(name, pattern string) bool {
+	return containsWords(algorithmNameWords(name), algorithmNameWords(pattern))
+}
+
+// containsWords reports whether the pattern occurs in words, it never matches
+// an empty pattern.
+func containsWords(words, pattern []string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for i := 0; i+len(pattern) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(pattern)], pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// algorithmNameWords splits an algorithm name into upper-cased words, see
+// splitAlgorithmName. The words of gluedAlgorithmNames are split further and
+// the compoundAlgorithmNames are joined into a single word.
+func algorithmNameWords(name string) []string {
+	var words []string
+	for _, word := range splitAlgorithmName(name) {
+		if parts, ok := gluedAlgorithmNames[word]; ok {
+			words = append(words, parts...)
+			continue
+		}
+		words = append(words, word)
+	}
+
+	for _, compound := range compoundAlgorithmNames {
+		parts := splitAlgorithmName(compound)
+		for i := 0; i+len(parts) <= len(words); i++ {
+			if slices.Equal(words[i:i+len(parts)], parts) {
+				words = slices.Replace(words, i, i+len(parts), strings.Join(parts, ""))
+			}
+		}
+	}
+	return words
+}
+
+// splitAlgorithmName splits a name into upper-cased words at every character
+// other than a letter or a digit, between letters and digits and at the case
+// changes of camel case, e.g. "sha256WithRSAEncryption" is split into SHA,
+// 256, WITH, RSA and ENCRYPTION.
+func splitAlgorithmName(name string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToUpper(string(word)))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if len(word) > 0 {
+			prev := word[len(word)-1]
+			switch {
+			case unicode.IsDigit(prev) != unicode.IsDigit(r):
+				flush()
+			case unicode.IsLower(prev) && unicode.IsUpper(r):
+				flush()
+			case unicode.IsUpper(prev) && unicode.IsUpper(r) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+				// the last capital of an acronym starts the next word,
+				// "RSAEncryption"
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
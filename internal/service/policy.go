@@ -0,0 +1,393 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyMode is the action taken when a BOM violates a policy.
+type PolicyMode string
+
+const (
+	// PolicyReject rejects the BOM with ErrValidation.
+	PolicyReject PolicyMode = "reject"
+	// PolicyWarn accepts the BOM, the findings are stored along with it and
+	// reported in BOMCreated.
+	PolicyWarn PolicyMode = "warn"
+	// PolicyRecord accepts the BOM, the findings are stored along with it only.
+	PolicyRecord PolicyMode = "record"
+)
+
+// PolicySeverity is the severity of the findings of a policy.
+type PolicySeverity string
+
+const (
+	PolicySeverityLow      PolicySeverity = "low"
+	PolicySeverityMedium   PolicySeverity = "medium"
+	PolicySeverityHigh     PolicySeverity = "high"
+	PolicySeverityCritical PolicySeverity = "critical"
+)
+
+// defaultPolicySeverities are the severities of policies which set none, by
+// their mode.
+var defaultPolicySeverities = map[PolicyMode]PolicySeverity{
+	PolicyReject: PolicySeverityHigh,
+	PolicyWarn:   PolicySeverityMedium,
+	PolicyRecord: PolicySeverityLow,
+}
+
+// PolicyConfig configures the policies evaluated against uploaded BOMs.
+type PolicyConfig struct {
+	// File is the path of the policy file, in YAML or JSON. No policy is
+	// evaluated if it is empty.
+	File string `envconfig:"APP_POLICY_FILE"`
+}
+
+// Policy is a single rule of the policy file. The selectors pick the crypto
+// assets the policy applies to, all of the set ones must match. The
+// requirements are checked on every selected asset.
+//
+// Example of a policy file:
+//
+//	policies:
+//	  - name: no-weak-hashes
+//	    mode: reject
+//	    names: [MD5, SHA-1]
+//	    forbidden: true
+//	  - name: rsa-key-size
+//	    mode: warn
+//	    severity: high
+//	    names: [RSA]
+//	    minKeySize: 3072
+//	  - name: tls-version
+//	    mode: record
+//	    protocolTypes: [tls]
+//	    minVersion: "1.2"
+type Policy struct {
+	// Name identifies the policy in the findings.
+	Name string `yaml:"name"`
+	// Description is an optional human readable description of the policy.
+	Description string `yaml:"description"`
+	// Mode is one of reject, warn or record.
+	Mode PolicyMode `yaml:"mode"`
+	// Severity is one of low, medium, high or critical. It defaults to high
+	// for the reject mode, medium for warn and low for record.
+	Severity PolicySeverity `yaml:"severity"`
+
+	// AssetType selects assets by `cryptoProperties.assetType`.
+	AssetType cdx.CryptoAssetType `yaml:"assetType"`
+	// Names selects assets whose name contains one of the names as whole
+	// words, see nameMatches, e.g. "RSA" selects "sha256WithRSAEncryption" and
+	// "SHA-1" selects "sha1WithRSAEncryption", but not "SHA-192". "DSA"
+	// selects neither "ECDSA" nor "ML-DSA".
+	Names []string `yaml:"names"`
+	// Primitives selects algorithms by `algorithmProperties.primitive`.
+	Primitives []cdx.CryptoPrimitive `yaml:"primitives"`
+	// ProtocolTypes selects protocols by `protocolProperties.type`.
+	ProtocolTypes []cdx.CryptoProtocolType `yaml:"protocolTypes"`
+
+	// Forbidden reports every selected asset.
+	Forbidden bool `yaml:"forbidden"`
+	// MinKeySize reports assets with a smaller key size, the key size is the
+	// `algorithmProperties.parameterSetIdentifier` of algorithms and the
+	// `relatedCryptoMaterialProperties.size` of keys. Assets of an unknown key
+	// size are not reported.
+	MinKeySize int `yaml:"minKeySize"`
+	// MinVersion reports protocols of a lower dotted `protocolProperties.version`.
+	// Protocols of an unknown version are not reported.
+	MinVersion string `yaml:"minVersion"`
+}
+
+// policyFile is the layout of the policy file.
+type policyFile struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+// PolicyFinding is a single violation of a policy.
+type PolicyFinding struct {
+	// Policy is the name of the violated policy.
+	Policy string `json:"policy"`
+	// Mode of the violated policy.
+	Mode PolicyMode `json:"mode"`
+	// Severity of the violated policy.
+	Severity PolicySeverity `json:"severity"`
+	// Pointer is the JSON pointer of the offending component.
+	Pointer string `json:"pointer"`
+	// BOMRef of the offending component, if it has any.
+	BOMRef string `json:"bomRef,omitempty"`
+	// Message is the human readable description of the violation.
+	Message string `json:"message"`
+}
+
+// PolicyFindings are the findings stored along with a BOM.
+type PolicyFindings struct {
+	// Total is the number of findings, it exceeds the length of Findings if
+	// the list was trimmed to fit the object metadata.
+	Total    int             `json:"total"`
+	Findings []PolicyFinding `json:"findings"`
+}
+
+// PolicyError is returned when an uploaded BOM violates a policy in the
+// reject mode. It wraps ErrValidation, so errors.Is(err, ErrValidation) holds
+// for it.
+type PolicyError struct {
+	// Findings of the rejecting policies, capped to Config.MaxValidationErrors.
+	Findings []PolicyFinding
+	// Total is the number of findings, it exceeds the length of Findings if
+	// the list was capped.
+	Total int
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s: violates policies", ErrValidation)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrValidation
+}
+
+// LoadPolicies reads and checks the policy file. JSON is a subset of YAML,
+// so the file is parsed as YAML in both cases.
+func LoadPolicies(path string) ([]Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+	policies, err := parsePolicies(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+func parsePolicies(b []byte) ([]Policy, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var file policyFile
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(file.Policies))
+	for i, p := range file.Policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy %d: name is required", i)
+		}
+		if _, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("policy %q: name is not unique", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		switch p.Mode {
+		case PolicyReject, PolicyWarn, PolicyRecord:
+		default:
+			return nil, fmt.Errorf("policy %q: mode %q is not one of %s, %s, %s", p.Name, p.Mode, PolicyReject, PolicyWarn, PolicyRecord)
+		}
+		switch p.Severity {
+		case "":
+			file.Policies[i].Severity = defaultPolicySeverities[p.Mode]
+		case PolicySeverityLow, PolicySeverityMedium, PolicySeverityHigh, PolicySeverityCritical:
+		default:
+			return nil, fmt.Errorf("policy %q: severity %q is not one of %s, %s, %s, %s", p.Name, p.Severity,
+				PolicySeverityLow, PolicySeverityMedium, PolicySeverityHigh, PolicySeverityCritical)
+		}
+		if !p.Forbidden && p.MinKeySize <= 0 && p.MinVersion == "" {
+			return nil, fmt.Errorf("policy %q: one of forbidden, minKeySize or minVersion is required", p.Name)
+		}
+		if p.MinVersion != "" {
+			if _, ok := parseDottedVersion(p.MinVersion); !ok {
+				return nil, fmt.Errorf("policy %q: minVersion %q is not a dotted version", p.Name, p.MinVersion)
+			}
+		}
+	}
+	return file.Policies, nil
+}
+
+// evaluatePolicies evaluates the policies against the crypto assets of the
+// BOM, including the nested ones. The findings are in the order of the
+// components, and of the policies for a single component.
+func evaluatePolicies(bom *cdx.BOM, policies []Policy) []PolicyFinding {
+	if len(policies) == 0 {
+		return nil
+	}
+	var findings []PolicyFinding
+	var walk func(pointer string, components *[]cdx.Component)
+	walk = func(pointer string, components *[]cdx.Component) {
+		if components == nil {
+			return
+		}
+		for i := range *components {
+			component := &(*components)[i]
+			p := fmt.Sprintf("%s/%d", pointer, i)
+			if component.CryptoProperties != nil {
+				for _, policy := range policies {
+					if msg, ok := policy.check(component); ok {
+						findings = append(findings, PolicyFinding{
+							Policy:   policy.Name,
+							Mode:     policy.Mode,
+							Severity: policy.Severity,
+							Pointer:  p,
+							BOMRef:   component.BOMRef,
+							Message:  msg,
+						})
+					}
+				}
+			}
+			walk(p+"/components", component.Components)
+		}
+	}
+	walk("/components", bom.Components)
+	return findings
+}
+
+// check returns the message of the finding if the policy selects the crypto
+// asset and the asset violates it.
+func (p Policy) check(component *cdx.Component) (string, bool) {
+	if !p.selects(component) {
+		return "", false
+	}
+	props := component.CryptoProperties
+	if p.Forbidden {
+		return fmt.Sprintf("%q is forbidden", component.Name), true
+	}
+	if p.MinKeySize > 0 {
+		if size, ok := keySize(props); ok && size < p.MinKeySize {
+			return fmt.Sprintf("%q key size %d is below %d", component.Name, size, p.MinKeySize), true
+		}
+	}
+	if p.MinVersion != "" && props.ProtocolProperties != nil {
+		minimal, _ := parseDottedVersion(p.MinVersion)
+		if version, ok := parseDottedVersion(props.ProtocolProperties.Version); ok && slices.Compare(version, minimal) < 0 {
+			return fmt.Sprintf("%q version %s is below %s", component.Name, props.ProtocolProperties.Version, p.MinVersion), true
+		}
+	}
+	return "", false
+}
+
+func (p Policy) selects(component *cdx.Component) bool {
+	props := component.CryptoProperties
+	if p.AssetType != "" && props.AssetType != p.AssetType {
+		return false
+	}
+	if len(p.Names) > 0 && !slices.ContainsFunc(p.Names, func(name string) bool {
+		return nameMatches(component.Name, name)
+	}) {
+		return false
+	}
+	if len(p.Primitives) > 0 && (props.AlgorithmProperties == nil || !slices.Contains(p.Primitives, props.AlgorithmProperties.Primitive)) {
+		return false
+	}
+	if len(p.ProtocolTypes) > 0 && (props.ProtocolProperties == nil || !slices.Contains(p.ProtocolTypes, props.ProtocolProperties.Type)) {
+		return false
+	}
+	return true
+}
+
+// keySize returns the key size of algorithms and related crypto material.
+func keySize(props *cdx.CryptoProperties) (int, bool) {
+	switch {
+	case props.AlgorithmProperties != nil && props.AlgorithmProperties.ParameterSetIdentifier != "":
+		size, err := strconv.Atoi(props.AlgorithmProperties.ParameterSetIdentifier)
+		return size, err == nil
+	case props.RelatedCryptoMaterialProperties != nil && props.RelatedCryptoMaterialProperties.Size != nil:
+		return *props.RelatedCryptoMaterialProperties.Size, true
+	}
+	return 0, false
+}
+
+// parseDottedVersion parses versions like "1.2" or "v1.2.3".
+func parseDottedVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if v == "" {
+		return nil, false
+	}
+	var res []int
+	for part := range strings.SplitSeq(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		res = append(res, n)
+	}
+	return res, true
+}
+
+// splitFindings splits the findings into those of policies in the reject
+// mode and the rest.
+func splitFindings(findings []PolicyFinding) ([]PolicyFinding, []PolicyFinding) {
+	var rejected, accepted []PolicyFinding
+	for _, f := range findings {
+		if f.Mode == PolicyReject {
+			rejected = append(rejected, f)
+		} else {
+			accepted = append(accepted, f)
+		}
+	}
+	return rejected, accepted
+}
+
+// warnFindings returns the findings of policies in the warn mode, at most
+// `limit` of them.
+func warnFindings(findings []PolicyFinding, limit int) []PolicyFinding {
+	var res []PolicyFinding
+	for _, f := range findings {
+		if f.Mode != PolicyWarn {
+			continue
+		}
+		if len(res) >= limit {
+			break
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+// capFindings returns at most `limit` findings.
+func capFindings(findings []PolicyFinding, limit int) []PolicyFinding {
+	if len(findings) > limit {
+		return findings[:limit]
+	}
+	return findings
+}
+
+// encodeFindings returns the findings to be stored in the object metadata,
+// the list is trimmed to the longest prefix fitting `limit` bytes, the total
+// is kept. It returns an empty string if there are no findings.
+func encodeFindings(findings []PolicyFinding, limit int) (string, error) {
+	if len(findings) == 0 {
+		return "", nil
+	}
+	encode := func(n int) ([]byte, error) {
+		return marshalASCII(PolicyFindings{Total: len(findings), Findings: findings[:n]})
+	}
+
+	// binary search of the longest fitting prefix, the empty one is used even
+	// if it does not fit
+	lo, hi := 0, len(findings)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		b, err := encode(mid)
+		if err != nil {
+			return "", err
+		}
+		if len(b) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	b, err := encode(lo)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
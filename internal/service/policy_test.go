@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicies = `
+policies:
+  - name: no-weak-hashes
+    description: MD5 and SHA-1 are broken.
+    mode: reject
+    names: [MD5, SHA-1]
+    forbidden: true
+  - name: rsa-key-size
+    mode: warn
+    severity: high
+    names: [RSA]
+    minKeySize: 3072
+  - name: tls-version
+    mode: record
+    protocolTypes: [tls]
+    minVersion: "1.2"
+`
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := parsePolicies([]byte(testPolicies))
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+	require.Equal(t, Policy{
+		Name:        "no-weak-hashes",
+		Description: "MD5 and SHA-1 are broken.",
+		Mode:        PolicyReject,
+		Severity:    PolicySeverityHigh,
+		Names:       []string{"MD5", "SHA-1"},
+		Forbidden:   true,
+	}, policies[0])
+	require.Equal(t, PolicySeverityHigh, policies[1].Severity)
+	require.Equal(t, PolicySeverityLow, policies[2].Severity)
+
+	// JSON is accepted as well
+	policies, err = parsePolicies([]byte(`{"policies": [{"name": "rsa", "mode": "warn", "names": ["RSA"], "minKeySize": 3072}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []Policy{{Name: "rsa", Mode: PolicyWarn, Severity: PolicySeverityMedium, Names: []string{"RSA"}, MinKeySize: 3072}}, policies)
+
+	policies, err = parsePolicies(nil)
+	require.NoError(t, err)
+	require.Empty(t, policies)
+
+	for name, file := range map[string]string{
+		"missing name":        `{"policies": [{"mode": "warn", "forbidden": true}]}`,
+		"duplicate name":      `{"policies": [{"name": "a", "mode": "warn", "forbidden": true}, {"name": "a", "mode": "warn", "forbidden": true}]}`,
+		"invalid mode":        `{"policies": [{"name": "a", "mode": "off", "forbidden": true}]}`,
+		"invalid severity":    `{"policies": [{"name": "a", "mode": "warn", "severity": "info", "forbidden": true}]}`,
+		"missing requirement": `{"policies": [{"name": "a", "mode": "warn", "names": ["RSA"]}]}`,
+		"invalid version":     `{"policies": [{"name": "a", "mode": "warn", "minVersion": "latest"}]}`,
+		"unknown field":       `{"policies": [{"name": "a", "mode": "warn", "forbidden": true, "minKeysize": 2048}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePolicies([]byte(file))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	policies, err := parsePolicies([]byte(testPolicies))
+	require.NoError(t, err)
+	size := 2048
+
+	bom := cdx.BOM{
+		Components: &[]cdx.Component{
+			{Name: "SHA-1", BOMRef: "sha1", CryptoProperties: &cdx.CryptoProperties{AssetType: cdx.CryptoAssetTypeAlgorithm}},
+			{Name: "SHA-256", CryptoProperties: &cdx.CryptoProperties{AssetType: cdx.CryptoAssetTypeAlgorithm}},
+			{Name: "lib", Components: &[]cdx.Component{
+				{Name: "RSA-2048", CryptoProperties: &cdx.CryptoProperties{
+					AssetType:           cdx.CryptoAssetTypeAlgorithm,
+					AlgorithmProperties: &cdx.CryptoAlgorithmProperties{ParameterSetIdentifier: "2048"},
+				}},
+				{Name: "RSA-4096", CryptoProperties: &cdx.CryptoProperties{
+					AssetType:           cdx.CryptoAssetTypeAlgorithm,
+					AlgorithmProperties: &cdx.CryptoAlgorithmProperties{ParameterSetIdentifier: "4096"},
+				}},
+			}},
+			{Name: "rsa-key", CryptoProperties: &cdx.CryptoProperties{
+				AssetType:                       cdx.CryptoAssetTypeRelatedCryptoMaterial,
+				RelatedCryptoMaterialProperties: &cdx.RelatedCryptoMaterialProperties{Size: &size},
+			}},
+			{Name: "TLS", CryptoProperties: &cdx.CryptoProperties{
+				AssetType:          cdx.CryptoAssetTypeProtocol,
+				ProtocolProperties: &cdx.CryptoProtocolProperties{Type: cdx.CryptoProtocolTypeTLS, Version: "1.1"},
+			}},
+			{Name: "TLS", CryptoProperties: &cdx.CryptoProperties{
+				AssetType:          cdx.CryptoAssetTypeProtocol,
+				ProtocolProperties: &cdx.CryptoProtocolProperties{Type: cdx.CryptoProtocolTypeTLS, Version: "1.3"},
+			}},
+		},
+	}
+
+	require.Equal(t, []PolicyFinding{
+		{Policy: "no-weak-hashes", Mode: PolicyReject, Severity: PolicySeverityHigh, Pointer: "/components/0", BOMRef: "sha1", Message: `"SHA-1" is forbidden`},
+		{Policy: "rsa-key-size", Mode: PolicyWarn, Severity: PolicySeverityHigh, Pointer: "/components/2/components/0", Message: `"RSA-2048" key size 2048 is below 3072`},
+		{Policy: "rsa-key-size", Mode: PolicyWarn, Severity: PolicySeverityHigh, Pointer: "/components/3", Message: `"rsa-key" key size 2048 is below 3072`},
+		{Policy: "tls-version", Mode: PolicyRecord, Severity: PolicySeverityLow, Pointer: "/components/4", Message: `"TLS" version 1.1 is below 1.2`},
+	}, evaluatePolicies(&bom, policies))
+
+	require.Empty(t, evaluatePolicies(&bom, nil))
+}
+
+func TestNameMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    bool
+	}{
+		{name: "RSA-2048", pattern: "RSA", want: true},
+		{name: "sha256WithRSAEncryption", pattern: "RSA", want: true},
+		{name: "RSASSA-PSS", pattern: "rsa", want: true},
+		{name: "SHA-1", pattern: "SHA-1", want: true},
+		{name: "SHA1", pattern: "SHA-1", want: true},
+		{name: "sha1WithRSAEncryption", pattern: "SHA-1", want: true},
+		{name: "HMAC-SHA1", pattern: "SHA-1", want: true},
+		{name: "SHA-192", pattern: "SHA-1", want: false},
+		{name: "SHA-256", pattern: "SHA-1", want: false},
+		{name: "SHA3-256", pattern: "SHA-3", want: true},
+		{name: "SHA-384", pattern: "SHA-3", want: false},
+		{name: "md5", pattern: "MD5", want: true},
+		{name: "md5WithRSAEncryption", pattern: "MD5", want: true},
+		{name: "AES-128-GCM", pattern: "AES-128", want: true},
+		{name: "AES-1280", pattern: "AES-128", want: false},
+		{name: "AES-256-GCM", pattern: "AES-128", want: false},
+		{name: "ECDSA", pattern: "", want: false},
+		{name: "DSA-2048", pattern: "DSA", want: true},
+		{name: "dsaWithSHA256", pattern: "DSA", want: true},
+		{name: "ML-DSA-65", pattern: "DSA", want: false},
+		{name: "ECDSA-P256", pattern: "DSA", want: false},
+		{name: "EdDSA", pattern: "DSA", want: false},
+		{name: "MLDSA65", pattern: "ML-DSA", want: true},
+		{name: "DH-2048", pattern: "DH", want: true},
+		{name: "ECDH-P256", pattern: "DH", want: false},
+		{name: "ECDHE", pattern: "DH", want: false},
+		{name: "DHE", pattern: "DH", want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name+"/"+tc.pattern, func(t *testing.T) {
+			require.Equal(t, tc.want, nameMatches(tc.name, tc.pattern))
+		})
+	}
+}
+
+func TestEncodeFindings(t *testing.T) {
+	s, err := encodeFindings(nil, 1024)
+	require.NoError(t, err)
+	require.Empty(t, s)
+
+	findings := make([]PolicyFinding, 50)
+	for i := range findings {
+		findings[i] = PolicyFinding{Policy: "rsa-key-size", Mode: PolicyWarn, Pointer: "/components/0", Message: `"RSA-2048" key size 2048 is below 3072`}
+	}
+	s, err = encodeFindings(findings, 1024)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(s), 1024)
+
+	var stored PolicyFindings
+	require.NoError(t, json.Unmarshal([]byte(s), &stored))
+	require.Equal(t, 50, stored.Total)
+	require.NotEmpty(t, stored.Findings)
+	require.Less(t, len(stored.Findings), 50)
+}
+
+func TestUploadBOM_Policies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(testPolicies), 0o600))
+
+	backend := store.NewMemory()
+	svc, err := New(backend, Config{MaxValidationErrors: 20, Policy: PolicyConfig{File: file}})
+	require.NoError(t, err)
+
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"version": 1,
+		"components": [
+			{"type": "cryptographic-asset", "name": "RSA-2048", "bom-ref": "rsa",
+			 "cryptoProperties": {"assetType": "algorithm", "algorithmProperties": {"parameterSetIdentifier": "2048"}}},
+			{"type": "cryptographic-asset", "name": "TLS",
+			 "cryptoProperties": {"assetType": "protocol", "protocolProperties": {"type": "tls", "version": "1.0"}}}
+		]
+	}`
+
+	// warn findings are returned, warn and record findings are stored
+	created, err := svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Equal(t, []PolicyFinding{
+		{Policy: "rsa-key-size", Mode: PolicyWarn, Severity: PolicySeverityHigh, Pointer: "/components/0", BOMRef: "rsa", Message: `"RSA-2048" key size 2048 is below 3072`},
+	}, created.Findings)
+
+	head, err := backend.GetHeadObject(context.Background(), "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79-1")
+	require.NoError(t, err)
+	var stored PolicyFindings
+	require.NoError(t, json.Unmarshal([]byte(head.Metadata[store.MetaPolicyFindingsKey]), &stored))
+	require.Equal(t, 2, stored.Total)
+	require.Len(t, stored.Findings, 2)
+	require.Equal(t, PolicyRecord, stored.Findings[1].Mode)
+
+	// reject findings fail the upload
+	body = `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [
+			{"type": "cryptographic-asset", "name": "MD5", "cryptoProperties": {"assetType": "algorithm"}}
+		]
+	}`
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorIs(t, err, ErrValidation)
+	var policyErr *PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	require.Equal(t, 1, policyErr.Total)
+	require.Equal(t, "no-weak-hashes", policyErr.Findings[0].Policy)
+
+	// missing policy file fails the initialization
+	_, err = New(backend, Config{Policy: PolicyConfig{File: filepath.Join(t.TempDir(), "missing.yaml")}})
+	require.Error(t, err)
+}
+
+func TestEvaluateBOMByUrn(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+
+	// the BOM is stored before any policy is configured
+	svc, err := New(backend, Config{MaxValidationErrors: 20})
+	require.NoError(t, err)
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"serialNumber": "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		"version": 1,
+		"components": [
+			{"type": "cryptographic-asset", "name": "MD5", "bom-ref": "md5", "cryptoProperties": {"assetType": "algorithm"}}
+		]
+	}`
+	_, err = svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+
+	findings, err := svc.EvaluateBOMByUrn(ctx, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", "")
+	require.NoError(t, err)
+	require.Equal(t, BOMFindings{
+		SerialNumber: "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		Version:      "1",
+		Findings:     []PolicyFinding{},
+	}, findings)
+
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(testPolicies), 0o600))
+	svc, err = New(backend, Config{MaxValidationErrors: 20, Policy: PolicyConfig{File: file}})
+	require.NoError(t, err)
+
+	findings, err = svc.EvaluateBOMByUrn(ctx, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", "1")
+	require.NoError(t, err)
+	require.Equal(t, []PolicyFinding{
+		{Policy: "no-weak-hashes", Mode: PolicyReject, Severity: PolicySeverityHigh, Pointer: "/components/0", BOMRef: "md5", Message: `"MD5" is forbidden`},
+	}, findings.Findings)
+
+	_, err = svc.EvaluateBOMByUrn(ctx, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", "2")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// the stored version is reported, the original declares another one
+	svc, err = New(backend, Config{MaxValidationErrors: 20})
+	require.NoError(t, err)
+	created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat": "CycloneDX", "specVersion": "1.6", "version": 7}`)), "1.6")
+	require.NoError(t, err)
+	findings, err = svc.EvaluateBOMByUrn(ctx, created.SerialNumber, "original")
+	require.NoError(t, err)
+	require.Equal(t, "original", findings.Version)
+	findings, err = svc.EvaluateBOMByUrn(ctx, created.SerialNumber, "")
+	require.NoError(t, err)
+	require.Equal(t, "1", findings.Version)
+}
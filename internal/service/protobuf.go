@@ -0,0 +1,1135 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The CycloneDX protobuf serialization is read and written field by field
+// with protowire, cyclonedx-go provides no protobuf codec and no Go bindings
+// of `bom-1.6.proto` are published. The field numbers and enumerations below
+// follow `bom-1.6.proto`, the enumeration values are the indexes of the
+// tables, 0 being the unspecified value.
+//
+// The codec covers what a CBOM is made of: the metadata timestamp, tools and
+// component, the components with their hashes, properties, evidence
+// occurrences and crypto properties, the dependencies and the properties of
+// the BOM. Decoding fails on the other fields and encoding fails on a BOM
+// which uses them, instead of dropping them.
+
+var (
+	protoComponentTypes = []cdx.ComponentType{
+		"",
+		cdx.ComponentTypeApplication,
+		cdx.ComponentTypeFramework,
+		cdx.ComponentTypeLibrary,
+		cdx.ComponentTypeOS,
+		cdx.ComponentTypeDevice,
+		cdx.ComponentTypeFile,
+		cdx.ComponentTypeContainer,
+		cdx.ComponentTypeFirmware,
+		cdx.ComponentTypeDeviceDriver,
+		cdx.ComponentTypePlatform,
+		cdx.ComponentTypeMachineLearningModel,
+		cdx.ComponentTypeData,
+		cdx.ComponentTypeCryptographicAsset,
+	}
+	protoScopes        = []cdx.Scope{"", cdx.ScopeRequired, cdx.ScopeOptional, cdx.ScopeExcluded}
+	protoHashAlgorithm = []cdx.HashAlgorithm{
+		"",
+		cdx.HashAlgoMD5,
+		cdx.HashAlgoSHA1,
+		cdx.HashAlgoSHA256,
+		cdx.HashAlgoSHA384,
+		cdx.HashAlgoSHA512,
+		cdx.HashAlgoSHA3_256,
+		cdx.HashAlgoSHA3_384,
+		cdx.HashAlgoSHA3_512,
+		cdx.HashAlgoBlake2b_256,
+		cdx.HashAlgoBlake2b_384,
+		cdx.HashAlgoBlake2b_512,
+		cdx.HashAlgoBlake3,
+	}
+	protoCryptoAssetTypes = []cdx.CryptoAssetType{
+		"", "algorithm", "certificate", "protocol", "related-crypto-material",
+	}
+	protoCryptoPrimitives = []cdx.CryptoPrimitive{
+		"", "drbg", "mac", "block-cipher", "stream-cipher", "signature", "hash", "pke", "xof", "kdf",
+		"key-agree", "kem", "ae", "combiner", "other", "unknown",
+	}
+	protoExecutionEnvironments = []cdx.CryptoExecutionEnvironment{
+		"", "software-plain-ram", "software-encrypted-ram", "software-tee", "hardware", "other", "unknown",
+	}
+	protoImplementationPlatforms = []cdx.ImplementationPlatform{
+		"", "generic", "x86_32", "x86_64", "armv7-a", "armv7-m", "armv8-a", "armv8-m", "armv9-a", "armv9-m",
+		"s390x", "ppc64", "ppc64le", "other", "unknown",
+	}
+	protoCertificationLevels = []cdx.CryptoCertificationLevel{
+		"", "none",
+		"fips140-1-l1", "fips140-1-l2", "fips140-1-l3", "fips140-1-l4",
+		"fips140-2-l1", "fips140-2-l2", "fips140-2-l3", "fips140-2-l4",
+		"fips140-3-l1", "fips140-3-l2", "fips140-3-l3", "fips140-3-l4",
+		"cc-eal1", "cc-eal1+", "cc-eal2", "cc-eal2+", "cc-eal3", "cc-eal3+", "cc-eal4", "cc-eal4+",
+		"cc-eal5", "cc-eal5+", "cc-eal6", "cc-eal6+", "cc-eal7", "cc-eal7+",
+		"other", "unknown",
+	}
+	protoAlgorithmModes = []cdx.CryptoAlgorithmMode{
+		"", "cbc", "ecb", "ccm", "gcm", "cfb", "ofb", "ctr", "other", "unknown",
+	}
+	protoPaddings = []cdx.CryptoPadding{
+		"", "pkcs5", "pkcs7", "pkcs1v15", "oaep", "raw", "other", "unknown",
+	}
+	protoCryptoFunctions = []cdx.CryptoFunction{
+		"", "generate", "keygen", "encrypt", "decrypt", "digest", "tag", "keyderive", "sign", "verify",
+		"encapsulate", "decapsulate", "other", "unknown",
+	}
+	protoRelatedCryptoMaterialTypes = []cdx.RelatedCryptoMaterialType{
+		"", "private-key", "public-key", "secret-key", "key", "ciphertext", "signature", "digest",
+		"initialization-vector", "nonce", "seed", "salt", "shared-secret", "tag", "additional-data",
+		"password", "credential", "token", "other", "unknown",
+	}
+	protoKeyStates = []cdx.CryptoKeyState{
+		"", "pre-activation", "active", "suspended", "deactivated", "compromised", "destroyed",
+	}
+	protoProtocolTypes = []cdx.CryptoProtocolType{
+		"", "tls", "ssh", "ipsec", "ike", "sstp", "wpa", "other", "unknown",
+	}
+)
+
+// decodeProtoBOM decodes a BOM serialized as a protobuf `Bom` message. The
+// message carries no format field, the format field is filled in.
+func decodeProtoBOM(b []byte) (cdx.BOM, error) {
+	bom := cdx.BOM{BOMFormat: cdx.BOMFormat}
+	err := rangeProtoFields(b, func(f protoField) error {
+		var (
+			v   string
+			err error
+		)
+		switch f.num {
+		case 1: // spec_version
+			if v, err = f.string(); err == nil {
+				bom.SpecVersion, err = knownCdxVersion(v)
+			}
+		case 2: // version
+			bom.Version, err = f.int()
+		case 3: // serial_number
+			bom.SerialNumber, err = f.string()
+		case 4: // metadata
+			bom.Metadata = &cdx.Metadata{}
+			err = f.message("metadata", func(b []byte) error {
+				return decodeProtoMetadata(b, bom.Metadata)
+			})
+		case 5: // components
+			err = addProtoMessage(f, "components", &bom.Components, decodeProtoComponent)
+		case 8: // dependencies
+			err = addProtoMessage(f, "dependencies", &bom.Dependencies, decodeProtoDependency)
+		case 12: // properties
+			err = addProtoMessage(f, "properties", &bom.Properties, decodeProtoProperty)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+	if err != nil {
+		return cdx.BOM{}, err
+	}
+	return bom, nil
+}
+
+func decodeProtoMetadata(b []byte, m *cdx.Metadata) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // timestamp
+			m.Timestamp, err = f.timestamp("timestamp")
+		case 2: // tools
+			if m.Tools == nil {
+				m.Tools = &cdx.ToolsChoice{}
+			}
+			err = f.message("tools", func(b []byte) error {
+				return decodeProtoTools(b, m.Tools)
+			})
+		case 4: // component
+			m.Component = &cdx.Component{}
+			err = f.message("component", func(b []byte) error {
+				return decodeProtoComponent(b, m.Component)
+			})
+		case 8: // properties
+			err = addProtoMessage(f, "properties", &m.Properties, decodeProtoProperty)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+// decodeProtoTools decodes a `Tool` message, the deprecated vendor, name,
+// version and hashes make a legacy tool, the components are added to the
+// components of the tools.
+func decodeProtoTools(b []byte, tools *cdx.ToolsChoice) error {
+	var tool cdx.Tool
+	err := rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // vendor
+			tool.Vendor, err = f.string()
+		case 2: // name
+			tool.Name, err = f.string()
+		case 3: // version
+			tool.Version, err = f.string()
+		case 4: // hashes
+			err = addProtoMessage(f, "hashes", &tool.Hashes, decodeProtoHash)
+		case 6: // components
+			err = addProtoMessage(f, "components", &tools.Components, decodeProtoComponent)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if tool != (cdx.Tool{}) {
+		addProtoValue(&tools.Tools, tool)
+	}
+	return nil
+}
+
+func decodeProtoComponent(b []byte, c *cdx.Component) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // type
+			c.Type, err = protoEnumField(f, protoComponentTypes)
+		case 2: // mime_type
+			c.MIMEType, err = f.string()
+		case 3: // bom_ref
+			c.BOMRef, err = f.string()
+		case 5: // author
+			c.Author, err = f.string()
+		case 6: // publisher
+			c.Publisher, err = f.string()
+		case 7: // group
+			c.Group, err = f.string()
+		case 8: // name
+			c.Name, err = f.string()
+		case 9: // version
+			c.Version, err = f.string()
+		case 10: // description
+			c.Description, err = f.string()
+		case 11: // scope
+			c.Scope, err = protoEnumField(f, protoScopes)
+		case 12: // hashes
+			err = addProtoMessage(f, "hashes", &c.Hashes, decodeProtoHash)
+		case 14: // copyright
+			c.Copyright, err = f.string()
+		case 15: // cpe
+			c.CPE, err = f.string()
+		case 16: // purl
+			c.PackageURL, err = f.string()
+		case 18: // modified
+			var v bool
+			v, err = f.bool()
+			c.Modified = &v
+		case 21: // components
+			err = addProtoMessage(f, "components", &c.Components, decodeProtoComponent)
+		case 22: // properties
+			err = addProtoMessage(f, "properties", &c.Properties, decodeProtoProperty)
+		case 23: // evidence
+			if c.Evidence == nil {
+				c.Evidence = &cdx.Evidence{}
+			}
+			err = f.message("evidence", func(b []byte) error {
+				return decodeProtoEvidence(b, c.Evidence)
+			})
+		case 27: // cryptoProperties
+			c.CryptoProperties = &cdx.CryptoProperties{}
+			err = f.message("cryptoProperties", func(b []byte) error {
+				return decodeProtoCryptoProperties(b, c.CryptoProperties)
+			})
+		case 30: // tags
+			err = addProtoString(f, &c.Tags)
+		case 31: // omniborId
+			err = addProtoString(f, &c.OmniborID)
+		case 32: // swhid
+			err = addProtoString(f, &c.SWHID)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoEvidence(b []byte, e *cdx.Evidence) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		switch f.num {
+		case 4: // occurrences
+			return addProtoMessage(f, "occurrences", &e.Occurrences, decodeProtoOccurrence)
+		default:
+			return f.unsupported()
+		}
+	})
+}
+
+func decodeProtoOccurrence(b []byte, o *cdx.EvidenceOccurrence) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // bom_ref
+			o.BOMRef, err = f.string()
+		case 2: // location
+			o.Location, err = f.string()
+		case 3: // line
+			o.Line, err = f.intPointer()
+		case 4: // offset
+			o.Offset, err = f.intPointer()
+		case 5: // symbol
+			o.Symbol, err = f.string()
+		case 6: // additionalContext
+			o.AdditionalContext, err = f.string()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoCryptoProperties(b []byte, p *cdx.CryptoProperties) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // assetType
+			p.AssetType, err = protoEnumField(f, protoCryptoAssetTypes)
+		case 2: // algorithmProperties
+			p.AlgorithmProperties = &cdx.CryptoAlgorithmProperties{}
+			err = f.message("algorithmProperties", func(b []byte) error {
+				return decodeProtoAlgorithmProperties(b, p.AlgorithmProperties)
+			})
+		case 3: // certificateProperties
+			p.CertificateProperties = &cdx.CertificateProperties{}
+			err = f.message("certificateProperties", func(b []byte) error {
+				return decodeProtoCertificateProperties(b, p.CertificateProperties)
+			})
+		case 4: // relatedCryptoMaterialProperties
+			p.RelatedCryptoMaterialProperties = &cdx.RelatedCryptoMaterialProperties{}
+			err = f.message("relatedCryptoMaterialProperties", func(b []byte) error {
+				return decodeProtoRelatedCryptoMaterialProperties(b, p.RelatedCryptoMaterialProperties)
+			})
+		case 5: // protocolProperties
+			p.ProtocolProperties = &cdx.CryptoProtocolProperties{}
+			err = f.message("protocolProperties", func(b []byte) error {
+				return decodeProtoProtocolProperties(b, p.ProtocolProperties)
+			})
+		case 6: // oid
+			p.OID, err = f.string()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoAlgorithmProperties(b []byte, p *cdx.CryptoAlgorithmProperties) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // primitive
+			p.Primitive, err = protoEnumField(f, protoCryptoPrimitives)
+		case 2: // parameterSetIdentifier
+			p.ParameterSetIdentifier, err = f.string()
+		case 3: // curve
+			p.Curve, err = f.string()
+		case 4: // executionEnvironment
+			p.ExecutionEnvironment, err = protoEnumField(f, protoExecutionEnvironments)
+		case 5: // implementationPlatform
+			p.ImplementationPlatform, err = protoEnumField(f, protoImplementationPlatforms)
+		case 6: // certificationLevel
+			err = addProtoEnums(f, protoCertificationLevels, &p.CertificationLevel)
+		case 7: // mode
+			p.Mode, err = protoEnumField(f, protoAlgorithmModes)
+		case 8: // padding
+			p.Padding, err = protoEnumField(f, protoPaddings)
+		case 9: // cryptoFunctions
+			err = addProtoEnums(f, protoCryptoFunctions, &p.CryptoFunctions)
+		case 10: // classicalSecurityLevel
+			p.ClassicalSecurityLevel, err = f.intPointer()
+		case 11: // nistQuantumSecurityLevel
+			p.NistQuantumSecurityLevel, err = f.intPointer()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoCertificateProperties(b []byte, p *cdx.CertificateProperties) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var (
+			v   string
+			err error
+		)
+		switch f.num {
+		case 1: // subjectName
+			p.SubjectName, err = f.string()
+		case 2: // issuerName
+			p.IssuerName, err = f.string()
+		case 3: // notValidBefore
+			p.NotValidBefore, err = f.timestamp("notValidBefore")
+		case 4: // notValidAfter
+			p.NotValidAfter, err = f.timestamp("notValidAfter")
+		case 5: // signatureAlgorithmRef
+			v, err = f.string()
+			p.SignatureAlgorithmRef = cdx.BOMReference(v)
+		case 6: // subjectPublicKeyRef
+			v, err = f.string()
+			p.SubjectPublicKeyRef = cdx.BOMReference(v)
+		case 7: // certificateFormat
+			p.CertificateFormat, err = f.string()
+		case 8: // certificateExtension
+			p.CertificateExtension, err = f.string()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoRelatedCryptoMaterialProperties(b []byte, p *cdx.RelatedCryptoMaterialProperties) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var (
+			v   string
+			err error
+		)
+		switch f.num {
+		case 1: // type
+			p.Type, err = protoEnumField(f, protoRelatedCryptoMaterialTypes)
+		case 2: // id
+			p.ID, err = f.string()
+		case 3: // state
+			p.State, err = protoEnumField(f, protoKeyStates)
+		case 4: // algorithmRef
+			v, err = f.string()
+			p.AlgorithmRef = cdx.BOMReference(v)
+		case 5: // creationDate
+			p.CreationDate, err = f.timestamp("creationDate")
+		case 6: // activationDate
+			p.ActivationDate, err = f.timestamp("activationDate")
+		case 7: // updateDate
+			p.UpdateDate, err = f.timestamp("updateDate")
+		case 8: // expirationDate
+			p.ExpirationDate, err = f.timestamp("expirationDate")
+		case 9: // value
+			p.Value, err = f.string()
+		case 10: // size
+			p.Size, err = f.intPointer()
+		case 11: // format
+			p.Format, err = f.string()
+		case 12: // securedBy
+			p.SecuredBy = &cdx.SecuredBy{}
+			err = f.message("securedBy", func(b []byte) error {
+				return decodeProtoSecuredBy(b, p.SecuredBy)
+			})
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoSecuredBy(b []byte, s *cdx.SecuredBy) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var (
+			v   string
+			err error
+		)
+		switch f.num {
+		case 1: // mechanism
+			s.Mechanism, err = f.string()
+		case 2: // algorithmRef
+			v, err = f.string()
+			s.AlgorithmRef = cdx.BOMReference(v)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoProtocolProperties(b []byte, p *cdx.CryptoProtocolProperties) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // type
+			p.Type, err = protoEnumField(f, protoProtocolTypes)
+		case 2: // version
+			p.Version, err = f.string()
+		case 3: // cipherSuites
+			err = addProtoMessage(f, "cipherSuites", &p.CipherSuites, decodeProtoCipherSuite)
+		case 5: // cryptoRefArray
+			err = addProtoString(f, &p.CryptoRefArray)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoCipherSuite(b []byte, s *cdx.CipherSuite) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // name
+			s.Name, err = f.string()
+		case 2: // algorithms
+			err = addProtoString(f, &s.Algorithms)
+		case 3: // identifiers
+			err = addProtoString(f, &s.Identifiers)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+// decodeProtoDependency decodes a `Dependency` message, the dependencies are
+// `Dependency` messages of their reference only.
+func decodeProtoDependency(b []byte, d *cdx.Dependency) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // ref
+			d.Ref, err = f.string()
+		case 2: // dependencies
+			var dependency cdx.Dependency
+			err = f.message("dependencies", func(b []byte) error {
+				if err := decodeProtoDependency(b, &dependency); err != nil {
+					return err
+				}
+				if dependency.Dependencies != nil || dependency.Provides != nil {
+					return fmt.Errorf("nested dependencies are not supported")
+				}
+				return nil
+			})
+			addProtoValue(&d.Dependencies, dependency.Ref)
+		case 3: // provides
+			err = addProtoString(f, &d.Provides)
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoHash(b []byte, h *cdx.Hash) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // alg
+			h.Algorithm, err = protoEnumField(f, protoHashAlgorithm)
+		case 2: // value
+			h.Value, err = f.string()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+func decodeProtoProperty(b []byte, p *cdx.Property) error {
+	return rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // name
+			p.Name, err = f.string()
+		case 2: // value
+			p.Value, err = f.string()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+}
+
+// decodeProtoTimestamp decodes a `google.protobuf.Timestamp` message into an
+// RFC 3339 date-time in UTC.
+func decodeProtoTimestamp(b []byte) (string, error) {
+	var seconds, nanos int
+	err := rangeProtoFields(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1: // seconds
+			seconds, err = f.int()
+		case 2: // nanos
+			nanos, err = f.int()
+		default:
+			err = f.unsupported()
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return time.Unix(int64(seconds), int64(nanos)).UTC().Format(time.RFC3339Nano), nil
+}
+
+// protoField is a field of a protobuf message as read from the wire, the
+// value of a varint field is in varint, the value of a length delimited one
+// in bytes.
+type protoField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// rangeProtoFields calls fn for each field of the message encoded in b, in
+// the order they are encoded.
+func rangeProtoFields(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := protoField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsupported returns the error of a field the codec does not cover.
+func (f protoField) unsupported() error {
+	return fmt.Errorf("field %d is not supported", f.num)
+}
+
+func (f protoField) expect(typ protowire.Type) error {
+	if f.typ != typ {
+		return fmt.Errorf("field %d: unexpected wire type %d", f.num, f.typ)
+	}
+	return nil
+}
+
+func (f protoField) string() (string, error) {
+	if err := f.expect(protowire.BytesType); err != nil {
+		return "", err
+	}
+	if !utf8.Valid(f.bytes) {
+		return "", fmt.Errorf("field %d: invalid UTF-8", f.num)
+	}
+	return string(f.bytes), nil
+}
+
+// int returns the value of an int32 or int64 field.
+func (f protoField) int() (int, error) {
+	if err := f.expect(protowire.VarintType); err != nil {
+		return 0, err
+	}
+	return int(int64(f.varint)), nil
+}
+
+// intPointer returns the value of an optional int32 or int64 field.
+func (f protoField) intPointer() (*int, error) {
+	v, err := f.int()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (f protoField) bool() (bool, error) {
+	if err := f.expect(protowire.VarintType); err != nil {
+		return false, err
+	}
+	return protowire.DecodeBool(f.varint), nil
+}
+
+// varints returns the values of a repeated varint field, which are either
+// packed in the field or one per field.
+func (f protoField) varints() ([]uint64, error) {
+	if f.typ == protowire.VarintType {
+		return []uint64{f.varint}, nil
+	}
+	if err := f.expect(protowire.BytesType); err != nil {
+		return nil, err
+	}
+	var values []uint64
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values, nil
+}
+
+// message decodes the message the field embeds, the errors of decode are
+// prefixed by the name of the field.
+func (f protoField) message(name string, decode func(b []byte) error) error {
+	if err := f.expect(protowire.BytesType); err != nil {
+		return err
+	}
+	if err := decode(f.bytes); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// timestamp returns the `google.protobuf.Timestamp` the field embeds as an
+// RFC 3339 date-time.
+func (f protoField) timestamp(name string) (string, error) {
+	var v string
+	err := f.message(name, func(b []byte) error {
+		var err error
+		v, err = decodeProtoTimestamp(b)
+		return err
+	})
+	return v, err
+}
+
+// protoEnumField returns the value of an enumeration field from the table of
+// its values.
+func protoEnumField[T ~string](f protoField, values []T) (T, error) {
+	n, err := f.int()
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n >= len(values) {
+		return "", fmt.Errorf("field %d: unknown enumeration value %d", f.num, n)
+	}
+	return values[n], nil
+}
+
+// addProtoEnums appends the values of a repeated enumeration field to list.
+func addProtoEnums[T ~string](f protoField, values []T, list **[]T) error {
+	numbers, err := f.varints()
+	if err != nil {
+		return err
+	}
+	for _, n := range numbers {
+		if n == 0 || n >= uint64(len(values)) {
+			return fmt.Errorf("field %d: unknown enumeration value %d", f.num, n)
+		}
+		addProtoValue(list, values[n])
+	}
+	return nil
+}
+
+// addProtoString appends the value of a repeated string field to list.
+func addProtoString[T ~string](f protoField, list **[]T) error {
+	v, err := f.string()
+	if err != nil {
+		return err
+	}
+	addProtoValue(list, T(v))
+	return nil
+}
+
+// addProtoMessage decodes the message of a repeated message field and
+// appends it to list, the errors are prefixed by the name of the field and
+// the index of the message.
+func addProtoMessage[T any](f protoField, name string, list **[]T, decode func(b []byte, v *T) error) error {
+	var index int
+	if *list != nil {
+		index = len(**list)
+	}
+	var v T
+	err := f.message(fmt.Sprintf("%s[%d]", name, index), func(b []byte) error {
+		return decode(b, &v)
+	})
+	if err != nil {
+		return err
+	}
+	addProtoValue(list, v)
+	return nil
+}
+
+func addProtoValue[T any](list **[]T, v T) {
+	if *list == nil {
+		*list = &[]T{}
+	}
+	**list = append(**list, v)
+}
+
+// encodeProtoBOM encodes the BOM as a protobuf `Bom` message. It fails if
+// the BOM uses a field the codec does not cover.
+func encodeProtoBOM(bom *cdx.BOM) ([]byte, error) {
+	e := &protoEncoder{}
+	e.unsupported("services", bom.Services != nil)
+	e.unsupported("externalReferences", bom.ExternalReferences != nil)
+	e.unsupported("compositions", bom.Compositions != nil)
+	e.unsupported("vulnerabilities", bom.Vulnerabilities != nil)
+	e.unsupported("annotations", bom.Annotations != nil)
+	e.unsupported("formulation", bom.Formulation != nil)
+	e.unsupported("declarations", bom.Declarations != nil)
+	e.unsupported("definitions", bom.Definitions != nil)
+	e.unsupported("signature", bom.Signature != nil)
+
+	e.string(1, bom.SpecVersion.String())
+	e.int(2, bom.Version)
+	e.string(3, bom.SerialNumber)
+	if bom.Metadata != nil {
+		e.message(4, "metadata", func(e *protoEncoder) {
+			encodeProtoMetadata(e, bom.Metadata)
+		})
+	}
+	encodeProtoMessages(e, 5, "components", bom.Components, encodeProtoComponent)
+	encodeProtoMessages(e, 8, "dependencies", bom.Dependencies, encodeProtoDependency)
+	encodeProtoMessages(e, 12, "properties", bom.Properties, encodeProtoProperty)
+	return e.b, e.err
+}
+
+func encodeProtoMetadata(e *protoEncoder, m *cdx.Metadata) {
+	e.unsupported("lifecycles", m.Lifecycles != nil)
+	e.unsupported("authors", m.Authors != nil)
+	e.unsupported("manufacture", m.Manufacture != nil)
+	e.unsupported("manufacturer", m.Manufacturer != nil)
+	e.unsupported("supplier", m.Supplier != nil)
+	e.unsupported("licenses", m.Licenses != nil)
+
+	e.timestamp(1, "timestamp", m.Timestamp)
+	if m.Tools != nil {
+		encodeProtoTools(e, m.Tools)
+	}
+	if m.Component != nil {
+		e.message(4, "component", func(e *protoEncoder) {
+			encodeProtoComponent(e, m.Component)
+		})
+	}
+	encodeProtoMessages(e, 8, "properties", m.Properties, encodeProtoProperty)
+}
+
+// encodeProtoTools encodes the tools as `Tool` messages, a legacy tool each
+// and the components in one more.
+func encodeProtoTools(e *protoEncoder, tools *cdx.ToolsChoice) {
+	e.unsupported("tools.services", tools.Services != nil)
+
+	encodeProtoMessages(e, 2, "tools", tools.Tools, func(e *protoEncoder, tool *cdx.Tool) {
+		e.unsupported("externalReferences", tool.ExternalReferences != nil)
+
+		e.string(1, tool.Vendor)
+		e.string(2, tool.Name)
+		e.string(3, tool.Version)
+		encodeProtoMessages(e, 4, "hashes", tool.Hashes, encodeProtoHash)
+	})
+	if tools.Components != nil {
+		e.message(2, "tools", func(e *protoEncoder) {
+			encodeProtoMessages(e, 6, "components", tools.Components, encodeProtoComponent)
+		})
+	}
+}
+
+func encodeProtoComponent(e *protoEncoder, c *cdx.Component) {
+	e.unsupported("supplier", c.Supplier != nil)
+	e.unsupported("manufacturer", c.Manufacturer != nil)
+	e.unsupported("authors", c.Authors != nil)
+	e.unsupported("licenses", c.Licenses != nil)
+	e.unsupported("swid", c.SWID != nil)
+	e.unsupported("pedigree", c.Pedigree != nil)
+	e.unsupported("externalReferences", c.ExternalReferences != nil)
+	e.unsupported("releaseNotes", c.ReleaseNotes != nil)
+	e.unsupported("modelCard", c.ModelCard != nil)
+	e.unsupported("data", c.Data != nil)
+	e.unsupported("signature", c.Signature != nil)
+
+	encodeProtoEnum(e, 1, protoComponentTypes, c.Type)
+	e.string(2, c.MIMEType)
+	e.string(3, c.BOMRef)
+	e.string(5, c.Author)
+	e.string(6, c.Publisher)
+	e.string(7, c.Group)
+	e.string(8, c.Name)
+	e.string(9, c.Version)
+	e.string(10, c.Description)
+	encodeProtoEnum(e, 11, protoScopes, c.Scope)
+	encodeProtoMessages(e, 12, "hashes", c.Hashes, encodeProtoHash)
+	e.string(14, c.Copyright)
+	e.string(15, c.CPE)
+	e.string(16, c.PackageURL)
+	e.boolPointer(18, c.Modified)
+	encodeProtoMessages(e, 21, "components", c.Components, encodeProtoComponent)
+	encodeProtoMessages(e, 22, "properties", c.Properties, encodeProtoProperty)
+	if c.Evidence != nil {
+		e.message(23, "evidence", func(e *protoEncoder) {
+			encodeProtoEvidence(e, c.Evidence)
+		})
+	}
+	if c.CryptoProperties != nil {
+		e.message(27, "cryptoProperties", func(e *protoEncoder) {
+			encodeProtoCryptoProperties(e, c.CryptoProperties)
+		})
+	}
+	encodeProtoStrings(e, 30, c.Tags)
+	encodeProtoStrings(e, 31, c.OmniborID)
+	encodeProtoStrings(e, 32, c.SWHID)
+}
+
+func encodeProtoEvidence(e *protoEncoder, ev *cdx.Evidence) {
+	e.unsupported("identity", ev.Identity != nil)
+	e.unsupported("callstack", ev.Callstack != nil)
+	e.unsupported("licenses", ev.Licenses != nil)
+	e.unsupported("copyright", ev.Copyright != nil)
+
+	encodeProtoMessages(e, 4, "occurrences", ev.Occurrences, func(e *protoEncoder, o *cdx.EvidenceOccurrence) {
+		e.string(1, o.BOMRef)
+		e.string(2, o.Location)
+		e.intPointer(3, o.Line)
+		e.intPointer(4, o.Offset)
+		e.string(5, o.Symbol)
+		e.string(6, o.AdditionalContext)
+	})
+}
+
+func encodeProtoCryptoProperties(e *protoEncoder, p *cdx.CryptoProperties) {
+	encodeProtoEnum(e, 1, protoCryptoAssetTypes, p.AssetType)
+	if a := p.AlgorithmProperties; a != nil {
+		e.message(2, "algorithmProperties", func(e *protoEncoder) {
+			encodeProtoEnum(e, 1, protoCryptoPrimitives, a.Primitive)
+			e.string(2, a.ParameterSetIdentifier)
+			e.string(3, a.Curve)
+			encodeProtoEnum(e, 4, protoExecutionEnvironments, a.ExecutionEnvironment)
+			encodeProtoEnum(e, 5, protoImplementationPlatforms, a.ImplementationPlatform)
+			encodeProtoEnums(e, 6, protoCertificationLevels, a.CertificationLevel)
+			encodeProtoEnum(e, 7, protoAlgorithmModes, a.Mode)
+			encodeProtoEnum(e, 8, protoPaddings, a.Padding)
+			encodeProtoEnums(e, 9, protoCryptoFunctions, a.CryptoFunctions)
+			e.intPointer(10, a.ClassicalSecurityLevel)
+			e.intPointer(11, a.NistQuantumSecurityLevel)
+		})
+	}
+	if c := p.CertificateProperties; c != nil {
+		e.message(3, "certificateProperties", func(e *protoEncoder) {
+			e.string(1, c.SubjectName)
+			e.string(2, c.IssuerName)
+			e.timestamp(3, "notValidBefore", c.NotValidBefore)
+			e.timestamp(4, "notValidAfter", c.NotValidAfter)
+			e.string(5, string(c.SignatureAlgorithmRef))
+			e.string(6, string(c.SubjectPublicKeyRef))
+			e.string(7, c.CertificateFormat)
+			e.string(8, c.CertificateExtension)
+		})
+	}
+	if r := p.RelatedCryptoMaterialProperties; r != nil {
+		e.message(4, "relatedCryptoMaterialProperties", func(e *protoEncoder) {
+			encodeProtoEnum(e, 1, protoRelatedCryptoMaterialTypes, r.Type)
+			e.string(2, r.ID)
+			encodeProtoEnum(e, 3, protoKeyStates, r.State)
+			e.string(4, string(r.AlgorithmRef))
+			e.timestamp(5, "creationDate", r.CreationDate)
+			e.timestamp(6, "activationDate", r.ActivationDate)
+			e.timestamp(7, "updateDate", r.UpdateDate)
+			e.timestamp(8, "expirationDate", r.ExpirationDate)
+			e.string(9, r.Value)
+			e.intPointer(10, r.Size)
+			e.string(11, r.Format)
+			if s := r.SecuredBy; s != nil {
+				e.message(12, "securedBy", func(e *protoEncoder) {
+					e.string(1, s.Mechanism)
+					e.string(2, string(s.AlgorithmRef))
+				})
+			}
+		})
+	}
+	if pp := p.ProtocolProperties; pp != nil {
+		e.message(5, "protocolProperties", func(e *protoEncoder) {
+			e.unsupported("ikev2TransformTypes", pp.IKEv2TransformTypes != nil)
+
+			encodeProtoEnum(e, 1, protoProtocolTypes, pp.Type)
+			e.string(2, pp.Version)
+			encodeProtoMessages(e, 3, "cipherSuites", pp.CipherSuites, func(e *protoEncoder, s *cdx.CipherSuite) {
+				e.string(1, s.Name)
+				encodeProtoStrings(e, 2, s.Algorithms)
+				encodeProtoStrings(e, 3, s.Identifiers)
+			})
+			encodeProtoStrings(e, 5, pp.CryptoRefArray)
+		})
+	}
+	e.string(6, p.OID)
+}
+
+func encodeProtoDependency(e *protoEncoder, d *cdx.Dependency) {
+	e.string(1, d.Ref)
+	if d.Dependencies != nil {
+		for _, ref := range *d.Dependencies {
+			e.message(2, "dependencies", func(e *protoEncoder) {
+				e.string(1, ref)
+			})
+		}
+	}
+	encodeProtoStrings(e, 3, d.Provides)
+}
+
+func encodeProtoHash(e *protoEncoder, h *cdx.Hash) {
+	encodeProtoEnum(e, 1, protoHashAlgorithm, h.Algorithm)
+	e.string(2, h.Value)
+}
+
+func encodeProtoProperty(e *protoEncoder, p *cdx.Property) {
+	e.string(1, p.Name)
+	e.string(2, p.Value)
+}
+
+// protoEncoder appends the fields of a protobuf message to b. The first error
+// is kept in err, the fields are not appended any more then.
+type protoEncoder struct {
+	b   []byte
+	err error
+}
+
+// unsupported fails the encoding if a field the codec does not cover is set.
+func (e *protoEncoder) unsupported(name string, set bool) {
+	if set && e.err == nil {
+		e.err = fmt.Errorf("%s is not supported by the protobuf serialization", name)
+	}
+}
+
+// string appends a string field, empty strings are left out.
+func (e *protoEncoder) string(num protowire.Number, s string) {
+	if s == "" || e.err != nil {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendString(e.b, s)
+}
+
+// varint appends a varint field, zero values are left out.
+func (e *protoEncoder) varint(num protowire.Number, v uint64) {
+	if v == 0 || e.err != nil {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, v)
+}
+
+// int appends an int32 or int64 field, zero values are left out.
+func (e *protoEncoder) int(num protowire.Number, v int) {
+	e.varint(num, uint64(int64(v)))
+}
+
+// intPointer appends an optional int32 or int64 field if it is set.
+func (e *protoEncoder) intPointer(num protowire.Number, v *int) {
+	if v == nil || e.err != nil {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, uint64(int64(*v)))
+}
+
+// boolPointer appends an optional bool field if it is set.
+func (e *protoEncoder) boolPointer(num protowire.Number, v *bool) {
+	if v == nil || e.err != nil {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, protowire.EncodeBool(*v))
+}
+
+// message appends a message field encoded by encode, its errors are prefixed
+// by the name of the field.
+func (e *protoEncoder) message(num protowire.Number, name string, encode func(e *protoEncoder)) {
+	if e.err != nil {
+		return
+	}
+	m := &protoEncoder{}
+	encode(m)
+	if m.err != nil {
+		e.err = fmt.Errorf("%s: %w", name, m.err)
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, m.b)
+}
+
+// timestamp appends an RFC 3339 date-time as a `google.protobuf.Timestamp`
+// message field, empty strings are left out.
+func (e *protoEncoder) timestamp(num protowire.Number, name, s string) {
+	if s == "" {
+		return
+	}
+	e.message(num, name, func(e *protoEncoder) {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.int(1, int(t.Unix()))
+		e.int(2, t.Nanosecond())
+	})
+}
+
+// encodeProtoEnum appends an enumeration field from the table of its values,
+// unspecified values are left out.
+func encodeProtoEnum[T ~string](e *protoEncoder, num protowire.Number, values []T, v T) {
+	if v == "" {
+		return
+	}
+	n := slices.Index(values, v)
+	if n < 0 && e.err == nil {
+		e.err = fmt.Errorf("unknown enumeration value %q", v)
+	}
+	e.varint(num, uint64(n))
+}
+
+// encodeProtoEnums appends a packed repeated enumeration field.
+func encodeProtoEnums[T ~string](e *protoEncoder, num protowire.Number, values []T, list *[]T) {
+	if list == nil || len(*list) == 0 || e.err != nil {
+		return
+	}
+	var packed []byte
+	for _, v := range *list {
+		n := slices.Index(values, v)
+		if n <= 0 {
+			e.err = fmt.Errorf("unknown enumeration value %q", v)
+			return
+		}
+		packed = protowire.AppendVarint(packed, uint64(n))
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, packed)
+}
+
+// encodeProtoStrings appends a repeated string field.
+func encodeProtoStrings[T ~string](e *protoEncoder, num protowire.Number, list *[]T) {
+	if list == nil || e.err != nil {
+		return
+	}
+	for _, v := range *list {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, string(v))
+	}
+}
+
+// encodeProtoMessages appends a repeated message field, the errors are
+// prefixed by the name of the field and the index of the message.
+func encodeProtoMessages[T any](e *protoEncoder, num protowire.Number, name string, list *[]T, encode func(e *protoEncoder, v *T)) {
+	if list == nil {
+		return
+	}
+	for i := range *list {
+		e.message(num, fmt.Sprintf("%s[%d]", name, i), func(e *protoEncoder) {
+			encode(e, &(*list)[i])
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"testing"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestProtoBOM_RoundTrip(t *testing.T) {
+	level, size, line := 3, 256, 42
+	modified := false
+	bom := cdx.BOM{
+		BOMFormat:    cdx.BOMFormat,
+		SpecVersion:  cdx.SpecVersion1_6,
+		SerialNumber: "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		Version:      2,
+		Metadata: &cdx.Metadata{
+			Timestamp: "2024-05-01T10:00:00.5Z",
+			Tools: &cdx.ToolsChoice{
+				Components: &[]cdx.Component{{Type: cdx.ComponentTypeApplication, Name: "scanner", Version: "1.0"}},
+			},
+			Component:  &cdx.Component{Type: cdx.ComponentTypeApplication, Name: "app", BOMRef: "app"},
+			Properties: &[]cdx.Property{{Name: "source", Value: "ci"}},
+		},
+		Components: &[]cdx.Component{
+			{
+				Type:       cdx.ComponentTypeLibrary,
+				BOMRef:     "lib",
+				Group:      "org.example",
+				Name:       "crypto",
+				Version:    "2.1",
+				Scope:      cdx.ScopeRequired,
+				Hashes:     &[]cdx.Hash{{Algorithm: cdx.HashAlgoSHA256, Value: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}},
+				PackageURL: "pkg:maven/org.example/crypto@2.1",
+				Modified:   &modified,
+				Tags:       &[]string{"crypto"},
+				Components: &[]cdx.Component{{Type: cdx.ComponentTypeFile, Name: "crypto.jar"}},
+			},
+			{
+				Type:   cdx.ComponentTypeCryptographicAsset,
+				BOMRef: "aes",
+				Name:   "AES-128-GCM",
+				Evidence: &cdx.Evidence{
+					Occurrences: &[]cdx.EvidenceOccurrence{{Location: "src/Main.java", Line: &line, Symbol: "encrypt"}},
+				},
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType: cdx.CryptoAssetTypeAlgorithm,
+					AlgorithmProperties: &cdx.CryptoAlgorithmProperties{
+						Primitive:                "ae",
+						ParameterSetIdentifier:   "128",
+						ExecutionEnvironment:     "software-plain-ram",
+						ImplementationPlatform:   "x86_64",
+						CertificationLevel:       &[]cdx.CryptoCertificationLevel{"fips140-3-l1", "cc-eal4+"},
+						Mode:                     "gcm",
+						CryptoFunctions:          &[]cdx.CryptoFunction{"encrypt", "decrypt"},
+						ClassicalSecurityLevel:   &size,
+						NistQuantumSecurityLevel: &level,
+					},
+					OID: "2.16.840.1.101.3.4.1.6",
+				},
+			},
+			{
+				Type:   cdx.ComponentTypeCryptographicAsset,
+				BOMRef: "cert",
+				Name:   "CN=example.com",
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType: cdx.CryptoAssetTypeCertificate,
+					CertificateProperties: &cdx.CertificateProperties{
+						SubjectName:           "CN=example.com",
+						IssuerName:            "CN=CA",
+						NotValidBefore:        "2024-01-01T00:00:00Z",
+						NotValidAfter:         "2025-01-01T00:00:00Z",
+						SignatureAlgorithmRef: "aes",
+						CertificateFormat:     "X.509",
+					},
+				},
+			},
+			{
+				Type:   cdx.ComponentTypeCryptographicAsset,
+				BOMRef: "key",
+				Name:   "key",
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType: cdx.CryptoAssetTypeRelatedCryptoMaterial,
+					RelatedCryptoMaterialProperties: &cdx.RelatedCryptoMaterialProperties{
+						Type:         "secret-key",
+						State:        "active",
+						AlgorithmRef: "aes",
+						CreationDate: "2024-01-01T00:00:00Z",
+						Size:         &size,
+						SecuredBy:    &cdx.SecuredBy{Mechanism: "HSM", AlgorithmRef: "aes"},
+					},
+				},
+			},
+			{
+				Type:   cdx.ComponentTypeCryptographicAsset,
+				BOMRef: "tls",
+				Name:   "TLS",
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType: cdx.CryptoAssetTypeProtocol,
+					ProtocolProperties: &cdx.CryptoProtocolProperties{
+						Type:    "tls",
+						Version: "1.3",
+						CipherSuites: &[]cdx.CipherSuite{{
+							Name:        "TLS_AES_128_GCM_SHA256",
+							Algorithms:  &[]cdx.BOMReference{"aes"},
+							Identifiers: &[]string{"0x13", "0x01"},
+						}},
+						CryptoRefArray: &[]cdx.BOMReference{"cert"},
+					},
+				},
+			},
+		},
+		Dependencies: &[]cdx.Dependency{
+			{Ref: "app", Dependencies: &[]string{"lib"}},
+			{Ref: "lib", Provides: &[]string{"aes", "tls"}},
+		},
+		Properties: &[]cdx.Property{{Name: "team", Value: "crypto"}},
+	}
+
+	b, err := encodeProtoBOM(&bom)
+	require.NoError(t, err)
+	decoded, err := decodeProtoBOM(b)
+	require.NoError(t, err)
+	require.Equal(t, bom, decoded)
+}
+
+func TestDecodeProtoBOM(t *testing.T) {
+	message := func(fields ...func(b []byte) []byte) []byte {
+		var b []byte
+		for _, field := range fields {
+			b = field(b)
+		}
+		return b
+	}
+	str := func(num protowire.Number, s string) func(b []byte) []byte {
+		return func(b []byte) []byte {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			return protowire.AppendString(b, s)
+		}
+	}
+	varint := func(num protowire.Number, v uint64) func(b []byte) []byte {
+		return func(b []byte) []byte {
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			return protowire.AppendVarint(b, v)
+		}
+	}
+	embed := func(num protowire.Number, m []byte) func(b []byte) []byte {
+		return func(b []byte) []byte {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			return protowire.AppendBytes(b, m)
+		}
+	}
+
+	component := message(
+		varint(1, 13), // cryptographic-asset
+		str(8, "ML-KEM-768"),
+		embed(27, message(
+			varint(1, 1), // algorithm
+			embed(2, message(
+				varint(1, 11), // kem
+				// repeated enumerations one per field as well as packed
+				varint(9, 2), // keygen
+				embed(9, protowire.AppendVarint([]byte{}, 10)), // encapsulate
+				varint(11, 3),
+			)),
+		)),
+	)
+
+	bom, err := decodeProtoBOM(message(str(1, "1.6"), varint(2, 1), embed(5, component)))
+	require.NoError(t, err)
+	require.Equal(t, cdx.BOMFormat, bom.BOMFormat)
+	require.Equal(t, cdx.SpecVersion1_6, bom.SpecVersion)
+	require.Equal(t, 1, bom.Version)
+	require.Len(t, *bom.Components, 1)
+	c := (*bom.Components)[0]
+	require.Equal(t, cdx.ComponentTypeCryptographicAsset, c.Type)
+	require.Equal(t, cdx.CryptoAssetTypeAlgorithm, c.CryptoProperties.AssetType)
+	algorithm := c.CryptoProperties.AlgorithmProperties
+	require.Equal(t, cdx.CryptoPrimitive("kem"), algorithm.Primitive)
+	require.Equal(t, []cdx.CryptoFunction{"keygen", "encapsulate"}, *algorithm.CryptoFunctions)
+	require.Equal(t, 3, *algorithm.NistQuantumSecurityLevel)
+
+	tests := map[string]struct {
+		b   []byte
+		err string
+	}{
+		"unsupported field": {
+			b:   message(str(1, "1.6"), embed(6, nil)),
+			err: "field 6 is not supported",
+		},
+		"unsupported nested field": {
+			b:   message(str(1, "1.6"), embed(5, component), embed(5, message(str(8, "lib"), embed(13, nil)))),
+			err: "components[1]: field 13 is not supported",
+		},
+		"unknown enumeration value": {
+			b:   message(embed(5, message(varint(1, 99)))),
+			err: "components[0]: field 1: unknown enumeration value 99",
+		},
+		"wrong wire type": {
+			b:   message(varint(1, 6)),
+			err: "field 1: unexpected wire type",
+		},
+		"unknown spec version": {
+			b:   message(str(1, "0.9")),
+			err: "0.9",
+		},
+		"truncated": {
+			b: message(str(1, "1.6"))[:3],
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeProtoBOM(tc.b)
+			require.Error(t, err)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestEncodeProtoBOM_Unsupported(t *testing.T) {
+	tests := map[string]struct {
+		bom cdx.BOM
+		err string
+	}{
+		"services": {
+			bom: cdx.BOM{SpecVersion: cdx.SpecVersion1_6, Services: &[]cdx.Service{{Name: "api"}}},
+			err: "services is not supported by the protobuf serialization",
+		},
+		"component licenses": {
+			bom: cdx.BOM{SpecVersion: cdx.SpecVersion1_6, Components: &[]cdx.Component{
+				{Name: "lib"},
+				{Name: "lib", Components: &[]cdx.Component{{Name: "nested", Licenses: &cdx.Licenses{}}}},
+			}},
+			err: "components[1]: components[0]: licenses is not supported",
+		},
+		"unknown enumeration value": {
+			bom: cdx.BOM{SpecVersion: cdx.SpecVersion1_6, Components: &[]cdx.Component{{Name: "lib", Type: "gadget"}}},
+			err: `components[0]: unknown enumeration value "gadget"`,
+		},
+		"invalid timestamp": {
+			bom: cdx.BOM{SpecVersion: cdx.SpecVersion1_6, Metadata: &cdx.Metadata{Timestamp: "yesterday"}},
+			err: "metadata: timestamp:",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := encodeProtoBOM(&tc.bom)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
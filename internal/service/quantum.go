@@ -0,0 +1,148 @@
+package service
+
+import (
+	"slices"
+	"strconv"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+)
+
+// Quantum safety classes of crypto assets.
+const (
+	QuantumVulnerable = "vulnerable"
+	QuantumSafe       = "safe"
+	QuantumUnknown    = "unknown"
+)
+
+// quantumVulnerableFamilies are algorithm families broken by Shor's algorithm,
+// EC stands for elliptic curve keys, e.g. "id-ecPublicKey".
+var quantumVulnerableFamilies = []string{
+	"RSA", "DSA", "EC", "ECDSA", "EdDSA", "Ed25519", "Ed448",
+	"DH", "DHE", "ECDH", "ECDHE", "X25519", "X448", "ECIES", "ElGamal",
+}
+
+// quantumSafeFamilies are post-quantum algorithm families, standardized by
+// NIST or selected as candidates.
+var quantumSafeFamilies = []string{
+	"ML-KEM", "ML-DSA", "SLH-DSA", "FN-DSA", "Kyber", "Dilithium",
+	"SPHINCS+", "Falcon", "XMSS", "XMSSMT", "LMS", "HSS", "HQC", "FrodoKEM",
+	"Classic-McEliece", "X25519MLKEM768", "SecP256r1MLKEM768", "SecP384r1MLKEM1024",
+}
+
+// QuantumStats describes the quantum safety of the algorithms, certificates
+// and protocols of a BOM. Related crypto material is not classified.
+type QuantumStats struct {
+	// ByNISTLevel counts algorithms by `algorithmProperties.nistQuantumSecurityLevel`.
+	ByNISTLevel Breakdown `json:"byNistQuantumSecurityLevel,omitempty"`
+	// Vulnerable counts assets broken by a quantum computer.
+	Vulnerable int `json:"vulnerable"`
+	// Safe counts assets resistant to a quantum computer.
+	Safe int `json:"safe"`
+	// Unknown counts assets which could not be classified, they are left out
+	// of the readiness score.
+	Unknown int `json:"unknown"`
+	// ReadinessScore is the percentage of quantum-safe assets out of the
+	// classified ones, it is omitted if no asset was classified.
+	ReadinessScore *int `json:"readinessScore,omitempty"`
+}
+
+// addAlgorithm classifies an algorithm and counts it.
+func (q *QuantumStats) addAlgorithm(component cdx.Component) {
+	props := component.CryptoProperties.AlgorithmProperties
+	if props != nil && props.NistQuantumSecurityLevel != nil {
+		q.ByNISTLevel.add(strconv.Itoa(*props.NistQuantumSecurityLevel))
+	}
+	q.count(classifyQuantum(component.Name, props))
+}
+
+// count counts an asset of the quantum safety class.
+func (q *QuantumStats) count(class string) {
+	switch class {
+	case QuantumVulnerable:
+		q.Vulnerable++
+	case QuantumSafe:
+		q.Safe++
+	default:
+		q.Unknown++
+	}
+}
+
+// score sets the readiness score from the counts.
+func (q *QuantumStats) score() {
+	classified := q.Safe + q.Vulnerable
+	if classified == 0 {
+		q.ReadinessScore = nil
+		return
+	}
+	score := q.Safe * 100 / classified
+	q.ReadinessScore = &score
+}
+
+// classifyQuantum returns the quantum safety class of an algorithm. The NIST
+// quantum security level decides if it is declared, level 0 is vulnerable and
+// the higher levels are safe. Otherwise the algorithm name is matched against
+// the known families as whole words, see nameMatches, a hybrid of a
+// vulnerable and a safe family is safe.
+func classifyQuantum(name string, props *cdx.CryptoAlgorithmProperties) string {
+	if props != nil && props.NistQuantumSecurityLevel != nil {
+		if *props.NistQuantumSecurityLevel > 0 {
+			return QuantumSafe
+		}
+		return QuantumVulnerable
+	}
+
+	words := algorithmNameWords(name)
+	for _, family := range quantumSafeFamilies {
+		if containsWords(words, algorithmNameWords(family)) {
+			return QuantumSafe
+		}
+	}
+	for _, family := range quantumVulnerableFamilies {
+		if containsWords(words, algorithmNameWords(family)) {
+			return QuantumVulnerable
+		}
+	}
+	return QuantumUnknown
+}
+
+// classifyCertificate returns the quantum safety class of a certificate, the
+// class of the algorithm referenced by its `signatureAlgorithmRef`. The
+// classes of the algorithms of the BOM are looked up by their bom-ref.
+func classifyCertificate(props *cdx.CertificateProperties, algorithms map[cdx.BOMReference]string) string {
+	if props == nil {
+		return QuantumUnknown
+	}
+	if class, ok := algorithms[props.SignatureAlgorithmRef]; ok {
+		return class
+	}
+	return QuantumUnknown
+}
+
+// classifyProtocol returns the quantum safety class of a protocol from its
+// cipher suites. A protocol offering a vulnerable cipher suite is vulnerable,
+// otherwise it is safe if a cipher suite is safe. A cipher suite is classified
+// by its name and by the algorithms it references, looked up by their bom-ref,
+// like a hybrid: it is safe if any of them is safe.
+func classifyProtocol(props *cdx.CryptoProtocolProperties, algorithms map[cdx.BOMReference]string) string {
+	if props == nil || props.CipherSuites == nil {
+		return QuantumUnknown
+	}
+
+	res := QuantumUnknown
+	for _, suite := range *props.CipherSuites {
+		classes := []string{classifyQuantum(suite.Name, nil)}
+		if suite.Algorithms != nil {
+			for _, ref := range *suite.Algorithms {
+				classes = append(classes, algorithms[ref])
+			}
+		}
+
+		switch {
+		case slices.Contains(classes, QuantumSafe):
+			res = QuantumSafe
+		case slices.Contains(classes, QuantumVulnerable):
+			return QuantumVulnerable
+		}
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"testing"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyQuantum(t *testing.T) {
+	level := func(l int) *cdx.CryptoAlgorithmProperties {
+		return &cdx.CryptoAlgorithmProperties{NistQuantumSecurityLevel: &l}
+	}
+
+	tests := map[string]struct {
+		name  string
+		props *cdx.CryptoAlgorithmProperties
+		want  string
+	}{
+		"rsa":                       {name: "RSA-2048", want: QuantumVulnerable},
+		"rsa signature":             {name: "sha-512-rsa", want: QuantumVulnerable},
+		"ecdsa":                     {name: "ECDSA-P256", want: QuantumVulnerable},
+		"x25519":                    {name: "x25519", want: QuantumVulnerable},
+		"rsa signature by oid name": {name: "sha256WithRSAEncryption", want: QuantumVulnerable},
+		"ec public key":             {name: "id-ecPublicKey", want: QuantumVulnerable},
+		"ml-dsa is not dsa":         {name: "ML-DSA-87", want: QuantumSafe},
+		"ml-kem":                    {name: "ML-KEM-768", want: QuantumSafe},
+		"slh-dsa":                   {name: "SLH-DSA-SHA2-128s", want: QuantumSafe},
+		"sphincs":                   {name: "SPHINCS+-SHAKE-256f", want: QuantumSafe},
+		"hybrid":                    {name: "X25519MLKEM768", want: QuantumSafe},
+		"hybrid of families":        {name: "ECDH-P256+ML-KEM-768", want: QuantumSafe},
+		"family as part of a word":  {name: "DHEX", want: QuantumUnknown},
+		"symmetric":                 {name: "AES-128-GCM", want: QuantumUnknown},
+		"level overrides name":      {name: "RSA-2048", props: level(1), want: QuantumSafe},
+		"level zero":                {name: "AES-128-GCM", props: level(0), want: QuantumVulnerable},
+		"properties without level":  {name: "ML-DSA-65", props: &cdx.CryptoAlgorithmProperties{}, want: QuantumSafe},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, classifyQuantum(tc.name, tc.props))
+		})
+	}
+}
+
+func TestClassifyCertificate(t *testing.T) {
+	algorithms := map[cdx.BOMReference]string{
+		"crypto/algorithm/sha256-rsa": QuantumVulnerable,
+		"crypto/algorithm/ml-dsa-65":  QuantumSafe,
+	}
+
+	tests := map[string]struct {
+		props *cdx.CertificateProperties
+		want  string
+	}{
+		"vulnerable signature": {props: &cdx.CertificateProperties{SignatureAlgorithmRef: "crypto/algorithm/sha256-rsa"}, want: QuantumVulnerable},
+		"safe signature":       {props: &cdx.CertificateProperties{SignatureAlgorithmRef: "crypto/algorithm/ml-dsa-65"}, want: QuantumSafe},
+		"unresolved reference": {props: &cdx.CertificateProperties{SignatureAlgorithmRef: "crypto/algorithm/missing"}, want: QuantumUnknown},
+		"no reference":         {props: &cdx.CertificateProperties{}, want: QuantumUnknown},
+		"no properties":        {want: QuantumUnknown},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, classifyCertificate(tc.props, algorithms))
+		})
+	}
+}
+
+func TestClassifyProtocol(t *testing.T) {
+	algorithms := map[cdx.BOMReference]string{
+		"crypto/algorithm/ecdh":   QuantumVulnerable,
+		"crypto/algorithm/mlkem":  QuantumSafe,
+		"crypto/algorithm/aes128": QuantumUnknown,
+	}
+	suites := func(suites ...cdx.CipherSuite) *cdx.CryptoProtocolProperties {
+		return &cdx.CryptoProtocolProperties{Type: cdx.CryptoProtocolTypeTLS, CipherSuites: &suites}
+	}
+	refs := func(refs ...cdx.BOMReference) *[]cdx.BOMReference {
+		return &refs
+	}
+
+	tests := map[string]struct {
+		props *cdx.CryptoProtocolProperties
+		want  string
+	}{
+		"vulnerable by name": {
+			props: suites(cdx.CipherSuite{Name: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}),
+			want:  QuantumVulnerable,
+		},
+		"vulnerable by reference": {
+			props: suites(cdx.CipherSuite{Name: "TLS_AES_128_GCM_SHA256", Algorithms: refs("crypto/algorithm/ecdh", "crypto/algorithm/aes128")}),
+			want:  QuantumVulnerable,
+		},
+		"hybrid suite": {
+			props: suites(cdx.CipherSuite{Name: "TLS_AES_128_GCM_SHA256", Algorithms: refs("crypto/algorithm/ecdh", "crypto/algorithm/mlkem")}),
+			want:  QuantumSafe,
+		},
+		"one vulnerable suite": {
+			props: suites(
+				cdx.CipherSuite{Algorithms: refs("crypto/algorithm/mlkem")},
+				cdx.CipherSuite{Name: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			),
+			want: QuantumVulnerable,
+		},
+		"symmetric only": {
+			props: suites(cdx.CipherSuite{Name: "TLS_AES_128_GCM_SHA256", Algorithms: refs("crypto/algorithm/aes128", "crypto/algorithm/missing")}),
+			want:  QuantumUnknown,
+		},
+		"no cipher suites": {props: &cdx.CryptoProtocolProperties{Type: cdx.CryptoProtocolTypeTLS}, want: QuantumUnknown},
+		"no properties":    {want: QuantumUnknown},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, classifyProtocol(tc.props, algorithms))
+		})
+	}
+}
+
+func TestQuantumStats_Score(t *testing.T) {
+	var q QuantumStats
+	q.score()
+	require.Nil(t, q.ReadinessScore)
+
+	q = QuantumStats{Safe: 1, Vulnerable: 2, Unknown: 5}
+	q.score()
+	require.NotNil(t, q.ReadinessScore)
+	require.Equal(t, 33, *q.ReadinessScore)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+)
+
+var ErrIndexDisabled = errors.New("metadata index disabled")
+
+// Reindex reconciles the metadata index with the store backend and reports
+// missing, stale and orphaned index entries. Objects whose metadata lack
+// crypto statistics, e.g. because they were written by other tools, get them
+// computed from their contents.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - fix: Whether to fix the differences in the index or just report them
+//
+// Returns:
+//   - store.ReindexReport: Keys of objects whose index entry differs from the store backend
+//   - error: ErrIndexDisabled if the store backend does not maintain a metadata index,
+//     or other errors from the store
+func (s Service) Reindex(ctx context.Context, fix bool) (store.ReindexReport, error) {
+	reindexer, ok := s.store.(store.Reindexer)
+	if !ok {
+		return store.ReindexReport{}, ErrIndexDisabled
+	}
+
+	ctx = log.ContextAttrs(ctx, slog.Bool("fix", fix))
+	slog.DebugContext(ctx, "Calling `store.Reindex()`.")
+	return reindexer.Reindex(ctx, fix, s.completeHead)
+}
+
+// completeHead computes crypto statistics from the contents of an object
+// whose metadata lack them. Objects which can't be decoded as a BOM are
+// left untouched, so are soft deleted objects, which are hidden anyway and
+// whose contents the store does not return.
+func (s Service) completeHead(ctx context.Context, key string, head store.HeadObject) (store.HeadObject, error) {
+	if v, ok := head.Metadata[store.MetaCryptoStatsKey]; ok && v != "" {
+		return head, nil
+	}
+	if store.Deleted(head.Metadata) {
+		return head, nil
+	}
+
+	ctx = log.ContextAttrs(ctx, slog.String("object-key", key))
+	slog.InfoContext(ctx, fmt.Sprintf("There is no key %q in object metadata, computing it from the object contents.", store.MetaCryptoStatsKey))
+
+	b, err := s.store.GetObject(ctx, key)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// soft deleted or removed since it was listed
+		slog.WarnContext(ctx, "Object not found, crypto statistics can't be computed.")
+		return head, nil
+
+	case err != nil:
+		return store.HeadObject{}, err
+	}
+
+	format, _ := bomFileFormat(storedMediaType(head.ContentType))
+	bom, err := decodeBOM(bytes.NewReader(b), format)
+	if err != nil {
+		slog.WarnContext(ctx, "`cdx.Decode()` failed, crypto statistics can't be computed.", slog.String("error", err.Error()))
+		return head, nil
+	}
+
+	// the statistics get the room left by the other metadata
+	meta := store.ParseMetadata(head.Metadata)
+	meta.CryptoStats = ""
+	if meta.DeletedAt == "" {
+		meta.DeletedAt = metadataReserve.DeletedAt
+	}
+	cryptoStats, err := fitCryptoStats(CalculateCryptoStats(ctx, &bom), store.MaxMetadataSize-meta.Size())
+	if err != nil {
+		return store.HeadObject{}, fmt.Errorf("`json.Marshal()` failed: %w", err)
+	}
+
+	metadata := maps.Clone(head.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[store.MetaCryptoStatsKey] = string(cryptoStats)
+	head.Metadata = metadata
+	return head, nil
+}
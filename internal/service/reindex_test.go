@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReindex_IndexDisabled(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	_, err = svc.Reindex(context.Background(), true)
+	require.ErrorIs(t, err, service.ErrIndexDisabled)
+}
+
+func TestReindex_ComputesMissingCryptoStats(t *testing.T) {
+	ctx := context.Background()
+	urn := "urn:uuid:e8c355aa-2142-4084-a8c7-6d42c8610ba2"
+
+	backend := store.NewMemory()
+	// written by another tool, without crypto stats in metadata
+	require.NoError(t, backend.Upload(ctx, urn+"-1", store.Metadata{Version: "1"}, []byte(`{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"serialNumber": "`+urn+`",
+		"version": 1,
+		"components": [
+			{
+				"name": "AES-128-GCM",
+				"type": "cryptographic-asset",
+				"bom-ref": "crypto/algorithm/aes-128-gcm",
+				"cryptoProperties": {"assetType": "algorithm"}
+			}
+		]
+	}`)))
+
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	svc, err := service.New(store.NewIndexed(backend, idx), service.Config{})
+	require.NoError(t, err)
+
+	report, err := svc.Reindex(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{urn + "-1"}, report.Missing)
+
+	head, ok := idx.Get(urn + "-1")
+	require.True(t, ok)
+	var cryptoStats service.CryptoStats
+	require.NoError(t, json.Unmarshal([]byte(head.Metadata[store.MetaCryptoStatsKey]), &cryptoStats))
+	require.Equal(t, 1, cryptoStats.CryptoAsset.Algo.Total)
+
+	versions, err := svc.UrnVersions(ctx, urn)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, 1, versions[0].CryptoStats.CryptoAsset.Total)
+}
+
+func TestReindex_SoftDeletedWithoutCryptoStats(t *testing.T) {
+	ctx := context.Background()
+	urn := "urn:uuid:e8c355aa-2142-4084-a8c7-6d42c8610ba2"
+
+	backend := store.NewMemory()
+	// written by another tool and soft deleted afterwards, without crypto stats in metadata
+	require.NoError(t, backend.Upload(ctx, urn+"-1", store.Metadata{Version: "1"}, []byte(`{"bomFormat": "CycloneDX", "specVersion": "1.6"}`)))
+	require.NoError(t, backend.UpdateMetadata(ctx, urn+"-1", store.Metadata{Version: "1", DeletedAt: "2024-01-15T12:00:00Z"}))
+	require.NoError(t, backend.Upload(ctx, urn+"-2", store.Metadata{Version: "2"}, []byte(`{"bomFormat": "CycloneDX", "specVersion": "1.6"}`)))
+
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	svc, err := service.New(store.NewIndexed(backend, idx), service.Config{})
+	require.NoError(t, err)
+
+	report, err := svc.Reindex(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{urn + "-1", urn + "-2"}, report.Missing)
+
+	head, ok := idx.Get(urn + "-1")
+	require.True(t, ok)
+	require.True(t, store.Deleted(head.Metadata))
+	require.Empty(t, head.Metadata[store.MetaCryptoStatsKey])
+
+	head, ok = idx.Get(urn + "-2")
+	require.True(t, ok)
+	require.NotEmpty(t, head.Metadata[store.MetaCryptoStatsKey])
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+)
+
+var ErrRetentionDisabled = errors.New("retention policy not configured")
+
+// RetentionConfig selects old BOM versions pruned by the retention worker. A
+// version is kept if any of the enabled rules keeps it, the "original"
+// version and the newest live version of every serial number are always kept.
+type RetentionConfig struct {
+	// KeepLast is the number of the newest versions kept for every serial
+	// number. Zero disables the rule.
+	KeepLast int `envconfig:"APP_RETENTION_KEEP_LAST" default:"0"`
+	// KeepDays keeps versions created within the given number of days. Zero
+	// disables the rule.
+	KeepDays int `envconfig:"APP_RETENTION_KEEP_DAYS" default:"0"`
+	// Interval is the period of the retention worker. Zero disables the
+	// worker, the policy can still be previewed via the dry-run report.
+	Interval time.Duration `envconfig:"APP_RETENTION_INTERVAL" default:"1h"`
+	// Purge removes pruned versions permanently instead of soft deleting
+	// them, the versions soft deleted before are purged as well.
+	Purge bool `envconfig:"APP_RETENTION_PURGE" default:"false"`
+}
+
+// Enabled reports whether at least one retention rule is configured.
+func (c RetentionConfig) Enabled() bool {
+	return c.KeepLast > 0 || c.KeepDays > 0
+}
+
+// RetentionReport lists BOM versions pruned by the retention policy.
+type RetentionReport struct {
+	// SerialNumbers is the number of serial numbers checked.
+	SerialNumbers int `json:"serialNumbers"`
+	// Pruned lists versions which were pruned, or would be pruned in the
+	// dry-run mode, grouped by serial number.
+	Pruned []BOMDeleted `json:"pruned"`
+	// DryRun is true if nothing was actually pruned.
+	DryRun bool `json:"dryRun"`
+}
+
+// ApplyRetention prunes BOM versions which are kept by none of the configured
+// retention rules. Soft deleted versions are not counted for the "keep last"
+// rule, they are pruned only if purging, which removes them permanently along
+// with the pruned live versions.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - dryRun: Whether to only report the versions which would be pruned
+//
+// Returns:
+//   - RetentionReport: The versions pruned, or to be pruned in the dry-run mode
+//   - error: ErrRetentionDisabled if no retention rule is configured, or other
+//     errors from the store
+func (s Service) ApplyRetention(ctx context.Context, dryRun bool) (RetentionReport, error) {
+	cfg := s.config.Retention
+	if !cfg.Enabled() {
+		return RetentionReport{}, ErrRetentionDisabled
+	}
+
+	ctx = log.ContextAttrs(ctx, slog.Bool("dry-run", dryRun))
+
+	versionsBySerial, err := s.retentionVersions(ctx)
+	if err != nil {
+		return RetentionReport{}, err
+	}
+
+	report := RetentionReport{
+		SerialNumbers: len(versionsBySerial),
+		Pruned:        []BOMDeleted{},
+		DryRun:        dryRun,
+	}
+	keepAfter := time.Now().AddDate(0, 0, -cfg.KeepDays)
+	for _, serial := range slices.Sorted(maps.Keys(versionsBySerial)) {
+		versions := versionsBySerial[serial]
+		// newest first
+		slices.SortFunc(versions, func(a, b retentionVersion) int {
+			return compareVersions(b.version, a.version)
+		})
+
+		pruned := BOMDeleted{SerialNumber: serial, Versions: []string{}, Purged: cfg.Purge}
+		numeric := 0
+		for _, v := range versions {
+			if v.deleted {
+				// already removed by a user or by a previous run, only a
+				// purge removes it for good
+				if cfg.Purge {
+					pruned.Versions = append(pruned.Versions, v.version)
+				}
+				continue
+			}
+			if _, err := strconv.Atoi(v.version); err != nil {
+				// the original is always kept
+				continue
+			}
+			numeric++
+
+			switch {
+			case numeric == 1:
+				// the newest version is always kept, so that a serial number
+				// is never pruned as a whole
+				continue
+			case cfg.KeepLast > 0 && numeric <= cfg.KeepLast:
+				continue
+			case cfg.KeepDays > 0 && v.lastModified.After(keepAfter):
+				continue
+			}
+			pruned.Versions = append(pruned.Versions, v.version)
+		}
+		if len(pruned.Versions) == 0 {
+			continue
+		}
+
+		if !dryRun {
+			if err := s.prune(ctx, serial, pruned.Versions); err != nil {
+				return RetentionReport{}, err
+			}
+		}
+		report.Pruned = append(report.Pruned, pruned)
+	}
+
+	slog.InfoContext(ctx, "Retention policy applied.",
+		slog.Int("serial-numbers", report.SerialNumbers),
+		slog.Int("pruned-serial-numbers", len(report.Pruned)),
+	)
+	return report, nil
+}
+
+// retentionVersion is a stored version of a BOM as seen by the retention
+// policy.
+type retentionVersion struct {
+	version      string
+	lastModified time.Time
+	deleted      bool
+}
+
+// retentionVersions returns all the stored versions grouped by serial number,
+// including the soft deleted ones. Heads are read unless listed by the store,
+// versions removed in the meantime are left out.
+func (s Service) retentionVersions(ctx context.Context) (map[string][]retentionVersion, error) {
+	objects, err := s.store.List(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]retentionVersion)
+	for _, obj := range objects {
+		idx := strings.LastIndex(obj.Key, "-")
+		if idx == -1 {
+			slog.ErrorContext(ctx, "Key does NOT adhere to the naming invariant.",
+				slog.String("key", obj.Key), slog.String("expected-format", "urn:uuid:<uuid>-<version>"))
+			return nil, errors.New("unexpected key returned from store")
+		}
+
+		head := obj.Head
+		if head == nil {
+			h, err := s.store.GetHeadObject(ctx, obj.Key)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				continue
+
+			case err != nil:
+				return nil, err
+			}
+			head = &h
+		}
+
+		serial := obj.Key[:idx]
+		res[serial] = append(res[serial], retentionVersion{
+			version:      obj.Key[idx+1:],
+			lastModified: obj.LastModified,
+			deleted:      store.Deleted(head.Metadata),
+		})
+	}
+	return res, nil
+}
+
+// prune soft deletes or purges the versions of a BOM, as configured. Versions
+// removed in the meantime are skipped.
+func (s Service) prune(ctx context.Context, serial string, versions []string) error {
+	deleteBOM := s.DeleteBOM
+	if s.config.Retention.Purge {
+		deleteBOM = s.PurgeBOM
+	}
+
+	for _, v := range versions {
+		if _, err := deleteBOM(ctx, serial, v); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRetention applies the retention policy periodically until `ctx` is
+// cancelled. It returns immediately if no retention rule or no interval is
+// configured. Failed runs are logged and retried in the next period.
+func (s Service) RunRetention(ctx context.Context) {
+	cfg := s.config.Retention
+	if !cfg.Enabled() || cfg.Interval <= 0 {
+		slog.DebugContext(ctx, "Retention worker disabled.")
+		return
+	}
+
+	slog.InfoContext(ctx, "Retention worker started.",
+		slog.Int("keep-last", cfg.KeepLast),
+		slog.Int("keep-days", cfg.KeepDays),
+		slog.Duration("interval", cfg.Interval),
+		slog.Bool("purge", cfg.Purge),
+	)
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if _, err := s.ApplyRetention(ctx, false); err != nil {
+				slog.ErrorContext(ctx, "Applying retention policy failed.", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend, err := store.NewFilesystem(store.FilesystemConfig{Dir: dir})
+	require.NoError(t, err)
+
+	upload := func(svc service.Service, body string) service.BOMCreated {
+		created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+		require.NoError(t, err)
+		return created
+	}
+	// age sets the last modified time of a stored version, the filesystem
+	// backend takes it from the contents file named after the encoded key
+	age := func(serial, version string, days int) {
+		mtime := time.Now().AddDate(0, 0, -days)
+		name := strings.ReplaceAll(serial+"-"+version, ":", "%3A")
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "objects", name), mtime, mtime))
+	}
+
+	svc, err := service.New(backend, service.Config{})
+	require.NoError(t, err)
+
+	// first serial number has the original and versions 1 to 5, all of them old
+	created := upload(svc, `{"bomFormat":"CycloneDX","specVersion":"1.6"}`)
+	serial := created.SerialNumber
+	for range 4 {
+		upload(svc, fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial))
+	}
+	for _, v := range []string{"original", "1", "2", "3", "4", "5"} {
+		age(serial, v, 60)
+	}
+	// version 5 is recent
+	age(serial, "5", 1)
+
+	// second serial number has a single old version
+	other := "urn:uuid:2c0b7f5e-5d6a-4c3b-9e8f-7a6b5c4d3e2f"
+	upload(svc, fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, other))
+	age(other, "1", 60)
+
+	_, err = svc.ApplyRetention(ctx, true)
+	require.ErrorIs(t, err, service.ErrRetentionDisabled)
+
+	tests := map[string]struct {
+		retention service.RetentionConfig
+		expected  []service.BOMDeleted
+	}{
+		"keep last": {
+			retention: service.RetentionConfig{KeepLast: 2},
+			expected: []service.BOMDeleted{
+				{SerialNumber: serial, Versions: []string{"3", "2", "1"}},
+			},
+		},
+		"keep days": {
+			// the single old version of the other serial number is the newest one
+			retention: service.RetentionConfig{KeepDays: 30},
+			expected: []service.BOMDeleted{
+				{SerialNumber: serial, Versions: []string{"4", "3", "2", "1"}},
+			},
+		},
+		"keep last or days": {
+			retention: service.RetentionConfig{KeepLast: 1, KeepDays: 30, Purge: true},
+			expected: []service.BOMDeleted{
+				{SerialNumber: serial, Versions: []string{"4", "3", "2", "1"}, Purged: true},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc, err := service.New(backend, service.Config{Retention: tc.retention})
+			require.NoError(t, err)
+
+			report, err := svc.ApplyRetention(ctx, true)
+			require.NoError(t, err)
+			require.True(t, report.DryRun)
+			require.Equal(t, 2, report.SerialNumbers)
+			require.ElementsMatch(t, tc.expected, report.Pruned)
+
+			// nothing was pruned in the dry-run mode
+			versions, err := svc.UrnVersions(ctx, serial)
+			require.NoError(t, err)
+			require.Len(t, versions, 6)
+		})
+	}
+
+	// pruning soft deletes the versions, the original and the latest are kept
+	svc, err = service.New(backend, service.Config{Retention: service.RetentionConfig{KeepLast: 1}})
+	require.NoError(t, err)
+	report, err := svc.ApplyRetention(ctx, false)
+	require.NoError(t, err)
+	require.False(t, report.DryRun)
+
+	versions, err := svc.UrnVersions(ctx, serial)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, "5", versions[0].Version)
+	require.Equal(t, "original", versions[1].Version)
+
+	// soft deleted versions are not pruned again
+	report, err = svc.ApplyRetention(ctx, true)
+	require.NoError(t, err)
+	require.Empty(t, report.Pruned)
+
+	// the newest live version is kept even if it is older than the kept days
+	age(serial, "5", 60)
+	svc, err = service.New(backend, service.Config{Retention: service.RetentionConfig{KeepDays: 30}})
+	require.NoError(t, err)
+	report, err = svc.ApplyRetention(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Pruned)
+	for _, urn := range []string{serial, other} {
+		versions, err := svc.UrnVersions(ctx, urn)
+		require.NoError(t, err)
+		require.NotEmpty(t, versions)
+	}
+
+	// purging removes the soft deleted versions as well
+	svc, err = service.New(backend, service.Config{Retention: service.RetentionConfig{KeepLast: 1, Purge: true}})
+	require.NoError(t, err)
+	report, err = svc.ApplyRetention(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []service.BOMDeleted{
+		{SerialNumber: serial, Versions: []string{"4", "3", "2", "1"}, Purged: true},
+	}, report.Pruned)
+	for _, v := range []string{"1", "2", "3", "4"} {
+		exists, err := backend.KeyExists(ctx, serial+"-"+v)
+		require.NoError(t, err)
+		require.False(t, exists, "version %s", v)
+	}
+
+	report, err = svc.ApplyRetention(ctx, true)
+	require.NoError(t, err)
+	require.Empty(t, report.Pruned)
+}
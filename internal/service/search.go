@@ -0,0 +1,511 @@
+package service
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+)
+
+type SearchRes struct {
+	SerialNumber string      `json:"serialNumber"`
+	Version      string      `json:"version"`
+	Timestamp    string      `json:"created_at"`
+	CryptoStats  CryptoStats `json:"cryptoStats"`
+	// VersionCount is the number of numeric versions stored for the serial
+	// number, soft deleted ones excluded. It is set in the latest-only mode
+	// only. It is counted from the listing of the store, so soft deleted
+	// versions are recognized only if the store lists the heads (memory store,
+	// metadata index) or if their heads were read for the search.
+	VersionCount int `json:"versionCount,omitempty"`
+}
+
+// SearchQuery selects the BOMs returned by Search.
+type SearchQuery struct {
+	// After is a Unix timestamp (seconds since epoch), only BOMs modified
+	// after this time are returned.
+	After int64
+	// Limit is the maximum number of results returned, it is capped by the
+	// configured maximum page size. Zero means the maximum page size.
+	Limit int
+	// Cursor continues the search after the last result of the previous
+	// page, it is the value of SearchPage.Next.
+	Cursor string
+
+	// Before is a Unix timestamp, only BOMs modified before this time are
+	// returned. Zero means no upper bound.
+	Before int64
+	// SerialNumberPrefix selects BOMs whose serial number starts with it.
+	SerialNumberPrefix string
+	// LatestOnly collapses the results to one entry per serial number, the
+	// highest numeric version among the BOMs matching the other criteria,
+	// along with the number of versions stored for the serial number.
+	// "original" versions are left out.
+	LatestOnly bool
+	// CryptoStats selects BOMs by their crypto statistics.
+	CryptoStats CryptoStatsFilter
+}
+
+// CryptoStatsFilter selects BOMs by thresholds on their crypto statistics,
+// zero value matches every BOM.
+type CryptoStatsFilter struct {
+	MinCryptoAssets           int
+	MinAlgorithms             int
+	MinCertificates           int
+	MinProtocols              int
+	MinRelatedCryptoMaterials int
+	HasAlgorithms             *bool
+	HasCertificates           *bool
+	HasProtocols              *bool
+	HasRelatedCryptoMaterials *bool
+}
+
+// Match reports whether crypto statistics satisfy all thresholds of the
+// filter.
+func (f CryptoStatsFilter) Match(stats CryptoStats) bool {
+	a := stats.CryptoAsset
+	return a.Total >= f.MinCryptoAssets &&
+		a.Algo.Total >= f.MinAlgorithms &&
+		a.Cert.Total >= f.MinCertificates &&
+		a.Protocol.Total >= f.MinProtocols &&
+		a.Related.Total >= f.MinRelatedCryptoMaterials &&
+		matchHas(f.HasAlgorithms, a.Algo.Total) &&
+		matchHas(f.HasCertificates, a.Cert.Total) &&
+		matchHas(f.HasProtocols, a.Protocol.Total) &&
+		matchHas(f.HasRelatedCryptoMaterials, a.Related.Total)
+}
+
+// matchHas reports whether the count matches the optional presence
+// requirement.
+func matchHas(has *bool, count int) bool {
+	return has == nil || *has == (count > 0)
+}
+
+// SearchPage is a single page of search results.
+type SearchPage struct {
+	Items []SearchRes
+	// Next is the cursor of the following page, empty on the last page.
+	Next string
+}
+
+// searchEntry is a search result along with the fields used for ordering.
+type searchEntry struct {
+	SearchRes
+	lastModified time.Time
+}
+
+// searchCandidate is a listed object which becomes a search result if its
+// head matches the query.
+type searchCandidate struct {
+	key          string
+	serialNumber string
+	version      string
+	lastModified time.Time
+	// head is nil until it is read, unless listed along with the object
+	head *store.HeadObject
+}
+
+// searchGroup holds candidates yielding at most one search result, the first
+// one matching the query: a single object, or the numeric versions of a serial
+// number from the highest one in the latest-only mode.
+type searchGroup struct {
+	candidates []*searchCandidate
+	// result is set once the first matching candidate is found
+	result *searchEntry
+}
+
+// searchCursor identifies the last result of a page. It is serialized as
+// base64 encoded json, so that it is opaque to the callers.
+type searchCursor struct {
+	LastModified int64  `json:"t"`
+	SerialNumber string `json:"s"`
+	Version      string `json:"v"`
+}
+
+// Search retrieves a page of BOMs with a last modified timestamp greater than `query.After`.
+// The function lists the objects of the underlying store and narrows them down by the criteria
+// known from the listing, the time range, serial number prefix and cursor, before the heads of
+// the remaining objects are read in the order of the results until the page is full. Results
+// carry cryptographic asset statistics extracted from object metadata and are further narrowed
+// down by the optional criteria of the query, see SearchQuery. In the latest-only mode, every
+// returned entry carries the number of versions stored for its serial number.
+//
+// Results are ordered by their last modified timestamp, then by serial number and version, so
+// that the order is stable and a cursor can be used to fetch the following page. BOMs stored
+// after a page was returned appear on later pages as they carry a newer timestamp.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields.
+//   - query: Lower bound timestamp, filtering criteria, page size and cursor of the previous page
+//
+// Returns:
+//   - SearchPage: Search results containing serial number, version, timestamp, and crypto statistics,
+//     along with the cursor of the following page
+//   - error: ErrValidation if the cursor is malformed, other errors if the store query fails,
+//     key format is invalid, or JSON unmarshaling fails
+func (s Service) Search(ctx context.Context, query SearchQuery) (SearchPage, error) {
+	ctx = log.ContextAttrs(ctx, slog.Int64("timestamp", query.After))
+
+	var after *searchCursor
+	if query.Cursor != "" {
+		c, err := decodeSearchCursor(query.Cursor)
+		if err != nil {
+			return SearchPage{}, fmt.Errorf("%w: %s", ErrValidation, err)
+		}
+		after = &c
+	}
+
+	limit := query.Limit
+	if maxSize := s.config.SearchMaxPageSize; maxSize > 0 && (limit <= 0 || limit > maxSize) {
+		limit = maxSize
+	}
+
+	groups, versions, err := s.searchGroups(ctx, query)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	// one more result than the limit tells whether there is a following page
+	want := -1
+	if limit > 0 {
+		want = limit + 1
+	}
+	entries, err := s.resolveSearchGroups(ctx, groups, after, want, query.CryptoStats)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	page := SearchPage{Items: []SearchRes{}}
+	if limit > 0 && len(entries) > limit {
+		page.Next = encodeSearchCursor(entries[limit-1])
+		entries = entries[:limit]
+	}
+	for _, e := range entries {
+		page.Items = append(page.Items, e.SearchRes)
+	}
+
+	if query.LatestOnly {
+		for i := range page.Items {
+			page.Items[i].VersionCount = versionCount(versions[page.Items[i].SerialNumber])
+		}
+	}
+
+	slog.DebugContext(ctx, "Search page assembled.",
+		slog.Int("count", len(page.Items)),
+		slog.Int("limit", limit),
+		slog.Bool("has-next", page.Next != ""),
+	)
+	return page, nil
+}
+
+// versionCount returns the number of the listed numeric versions of a serial
+// number, leaving out the versions known to be soft deleted: those listed along
+// with their heads and those whose head was read while assembling the page.
+func versionCount(versions []*searchCandidate) int {
+	count := 0
+	for _, c := range versions {
+		if c.head == nil || !store.Deleted(c.head.Metadata) {
+			count++
+		}
+	}
+	return count
+}
+
+// searchGroups lists the objects of the store and narrows them down by the
+// criteria known without reading their heads: the time range and the serial
+// number prefix. In the latest-only mode, the numeric versions of a serial
+// number form a single group and "original" versions are left out; all the
+// numeric versions of the serial numbers matching the prefix are returned as
+// well, regardless of the time range, to be counted by versionCount.
+func (s Service) searchGroups(ctx context.Context, query SearchQuery) ([]*searchGroup, map[string][]*searchCandidate, error) {
+	// versions out of the time range are counted in the latest-only mode
+	ts := query.After
+	if query.LatestOnly {
+		ts = 0
+	}
+
+	slog.DebugContext(ctx, "Calling `store.List()`.")
+
+	objects, err := s.store.List(ctx, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.DebugContext(ctx, "`store.List()` finished.", slog.Int("count", len(objects)))
+
+	after := time.Unix(query.After, 0)
+	before := time.Unix(query.Before, 0)
+
+	var groups []*searchGroup
+	latest := make(map[string]*searchGroup)
+	versions := make(map[string][]*searchCandidate)
+	for _, obj := range objects {
+		idx := strings.LastIndex(obj.Key, "-")
+		if idx == -1 {
+			slog.ErrorContext(ctx, "Key does NOT adhere to the naming invariant.",
+				slog.String("key", obj.Key), slog.String("expected-format", "urn:uuid:<uuid>-<version>"))
+			return nil, nil, errors.New("unexpected key returned from store")
+		}
+		c := &searchCandidate{
+			key:          obj.Key,
+			serialNumber: obj.Key[:idx],
+			version:      obj.Key[idx+1:],
+			lastModified: obj.LastModified,
+			head:         obj.Head,
+		}
+
+		if !strings.HasPrefix(c.serialNumber, query.SerialNumberPrefix) {
+			continue
+		}
+
+		if !query.LatestOnly {
+			if query.Before > 0 && !c.lastModified.Before(before) {
+				continue
+			}
+			groups = append(groups, &searchGroup{candidates: []*searchCandidate{c}})
+			continue
+		}
+
+		if _, err := strconv.Atoi(c.version); err != nil {
+			continue
+		}
+		versions[c.serialNumber] = append(versions[c.serialNumber], c)
+
+		if !after.Before(c.lastModified) || (query.Before > 0 && !c.lastModified.Before(before)) {
+			continue
+		}
+		g, ok := latest[c.serialNumber]
+		if !ok {
+			g = &searchGroup{}
+			latest[c.serialNumber] = g
+			groups = append(groups, g)
+		}
+		g.candidates = append(g.candidates, c)
+	}
+
+	for _, g := range latest {
+		slices.SortFunc(g.candidates, func(a, b *searchCandidate) int {
+			return -compareVersions(a.version, b.version)
+		})
+	}
+	return groups, versions, nil
+}
+
+// resolveSearchGroups returns up to `want` search results following the
+// cursor `after` in the order of compareSearchCursors, a negative `want`
+// means all of them. Heads are read lazily: a group is resolved only when
+// it could yield the next result, so the heads read are bounded by the size
+// of the page rather than by the size of the store, apart from candidates
+// which do not match the query.
+//
+// A group is ordered by the lowest cursor among its remaining candidates
+// until it is resolved, which is never greater than the cursor of its result,
+// and by the cursor of its result afterwards. Resolved groups are therefore
+// popped in the order of their results.
+func (s Service) resolveSearchGroups(ctx context.Context, groups []*searchGroup, after *searchCursor, want int, filter CryptoStatsFilter) ([]searchEntry, error) {
+	h := make(searchGroupHeap, 0, len(groups))
+	for _, g := range groups {
+		if len(g.candidates) > 0 {
+			h = append(h, g)
+		}
+	}
+	heap.Init(&h)
+
+	res := []searchEntry{}
+	for h.Len() > 0 && (want < 0 || len(res) < want) {
+		g := heap.Pop(&h).(*searchGroup)
+
+		if g.result != nil {
+			if after == nil || compareSearchCursors(g.result.cursor(), *after) > 0 {
+				res = append(res, *g.result)
+			}
+			continue
+		}
+
+		// every result of the group would precede the cursor
+		if after != nil && compareSearchCursors(g.maxCursor(), *after) <= 0 {
+			continue
+		}
+
+		entry, ok, err := s.readSearchCandidate(ctx, g.candidates[0], filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			g.result = &entry
+		} else if g.candidates = g.candidates[1:]; len(g.candidates) == 0 {
+			continue
+		}
+		heap.Push(&h, g)
+	}
+	return res, nil
+}
+
+// readSearchCandidate returns the search result of the candidate and whether
+// it matches the crypto statistics filter, reading its head if it was not
+// listed. Soft deleted objects and objects removed in the meantime do not
+// match.
+func (s Service) readSearchCandidate(ctx context.Context, c *searchCandidate, filter CryptoStatsFilter) (searchEntry, bool, error) {
+	if c.head == nil {
+		head, err := s.store.GetHeadObject(ctx, c.key)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			slog.WarnContext(ctx, fmt.Sprintf("Fetching HeadObject for key %q failed although version was previously returned by `store.List()`. Skipping from result set.", c.key))
+			return searchEntry{}, false, nil
+
+		case err != nil:
+			return searchEntry{}, false, err
+		}
+		c.head = &head
+	}
+	if store.Deleted(c.head.Metadata) {
+		return searchEntry{}, false, nil
+	}
+
+	cryptoStatsValue, ok := c.head.Metadata[store.MetaCryptoStatsKey]
+	if !ok {
+		slog.WarnContext(ctx,
+			fmt.Sprintf("There is no key %q in object metadata. Skipping from result set.", store.MetaCryptoStatsKey),
+			slog.String("object-key", c.key))
+		return searchEntry{}, false, nil
+	}
+
+	var cryptoStats CryptoStats
+	if err := json.Unmarshal([]byte(cryptoStatsValue), &cryptoStats); err != nil {
+		slog.ErrorContext(ctx,
+			fmt.Sprintf("Unmarshaling metadata key %q value failed.", store.MetaCryptoStatsKey),
+			slog.String("error", err.Error()), slog.String("object-key", c.key))
+		return searchEntry{}, false, errors.New("unmarshaling json failed")
+	}
+	if !filter.Match(cryptoStats) {
+		return searchEntry{}, false, nil
+	}
+
+	return searchEntry{
+		SearchRes: SearchRes{
+			SerialNumber: c.serialNumber,
+			Version:      c.version,
+			Timestamp:    c.lastModified.Format(time.RFC3339),
+			CryptoStats:  cryptoStats,
+		},
+		lastModified: c.lastModified,
+	}, true, nil
+}
+
+// cursor returns the position of the candidate in the search results.
+func (c *searchCandidate) cursor() searchCursor {
+	return searchCursor{
+		LastModified: c.lastModified.UnixNano(),
+		SerialNumber: c.serialNumber,
+		Version:      c.version,
+	}
+}
+
+// minCursor returns the lowest cursor the group may yield, the cursor of its
+// result once resolved.
+func (g *searchGroup) minCursor() searchCursor {
+	if g.result != nil {
+		return g.result.cursor()
+	}
+	res := g.candidates[0].cursor()
+	for _, c := range g.candidates[1:] {
+		if cur := c.cursor(); compareSearchCursors(cur, res) < 0 {
+			res = cur
+		}
+	}
+	return res
+}
+
+// maxCursor returns the highest cursor among the remaining candidates.
+func (g *searchGroup) maxCursor() searchCursor {
+	res := g.candidates[0].cursor()
+	for _, c := range g.candidates[1:] {
+		if cur := c.cursor(); compareSearchCursors(cur, res) > 0 {
+			res = cur
+		}
+	}
+	return res
+}
+
+// searchGroupHeap is a container/heap of search groups ordered by minCursor.
+type searchGroupHeap []*searchGroup
+
+func (h searchGroupHeap) Len() int { return len(h) }
+
+func (h searchGroupHeap) Less(i, j int) bool {
+	return compareSearchCursors(h[i].minCursor(), h[j].minCursor()) < 0
+}
+
+func (h searchGroupHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *searchGroupHeap) Push(x any) { *h = append(*h, x.(*searchGroup)) }
+
+func (h *searchGroupHeap) Pop() any {
+	old := *h
+	g := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return g
+}
+
+// cursor returns the position of the entry in the search results.
+func (e searchEntry) cursor() searchCursor {
+	return searchCursor{
+		LastModified: e.lastModified.UnixNano(),
+		SerialNumber: e.SerialNumber,
+		Version:      e.Version,
+	}
+}
+
+// compareSearchCursors orders search results by last modified timestamp, then
+// by serial number and version.
+func compareSearchCursors(a, b searchCursor) int {
+	return cmp.Or(
+		cmp.Compare(a.LastModified, b.LastModified),
+		strings.Compare(a.SerialNumber, b.SerialNumber),
+		compareVersions(a.Version, b.Version),
+	)
+}
+
+// compareVersions orders numeric versions numerically, followed by the
+// "original" version.
+func compareVersions(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func encodeSearchCursor(e searchEntry) string {
+	b, _ := json.Marshal(e.cursor())
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSearchCursor(v string) (searchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return searchCursor{}, errors.New("cursor is malformed")
+	}
+	var c searchCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.SerialNumber == "" {
+		return searchCursor{}, errors.New("cursor is malformed")
+	}
+	return c, nil
+}
@@ -0,0 +1,396 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	mockS3 "github.com/CZERTAINLY/CBOM-Repository/internal/store/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSearch_Ordering(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().ListObjectsV2(gomock.Any(), gomock.Any(), gomock.Any()).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: awsString("urn:uuid:b-10"), LastModified: &older},
+			{Key: awsString("urn:uuid:b-2"), LastModified: &older},
+			{Key: awsString("urn:uuid:a-original"), LastModified: &newer},
+			{Key: awsString("urn:uuid:a-1"), LastModified: &newer},
+			{Key: awsString("urn:uuid:c-1"), LastModified: &older},
+		},
+	}, nil)
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			lastModified := older
+			if strings.HasPrefix(*in.Key, "urn:uuid:a-") {
+				lastModified = newer
+			}
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(1),
+				ContentType:   aws.String("application/json"),
+				LastModified:  &lastModified,
+				Metadata:      map[string]string{store.MetaCryptoStatsKey: "{}"},
+			}, nil
+		}).Times(5)
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil)
+	svc, err := service.New(st, service.Config{})
+	require.NoError(t, err)
+
+	res, err := svc.Search(context.Background(), service.SearchQuery{})
+	require.NoError(t, err)
+
+	var got []string
+	for _, item := range res.Items {
+		got = append(got, item.SerialNumber+"-"+item.Version)
+	}
+	require.Equal(t, []string{
+		"urn:uuid:b-2",
+		"urn:uuid:b-10",
+		"urn:uuid:c-1",
+		"urn:uuid:a-1",
+		"urn:uuid:a-original",
+	}, got)
+}
+
+func TestSearch_HeadsBoundedByPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Now().Add(-time.Hour)
+	modified := map[string]time.Time{}
+	var contents []types.Object
+	for i := range 10 {
+		key := fmt.Sprintf("urn:uuid:%02d-1", i)
+		modified[key] = start.Add(time.Duration(i) * time.Minute)
+		lastModified := modified[key]
+		contents = append(contents, types.Object{Key: awsString(key), LastModified: &lastModified})
+	}
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().ListObjectsV2(gomock.Any(), gomock.Any(), gomock.Any()).Return(&s3.ListObjectsV2Output{
+		Contents: contents,
+	}, nil).Times(2)
+	var heads []string
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			heads = append(heads, *in.Key)
+			lastModified := modified[*in.Key]
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(1),
+				ContentType:   aws.String("application/json"),
+				LastModified:  &lastModified,
+				Metadata:      map[string]string{store.MetaCryptoStatsKey: "{}"},
+			}, nil
+		}).AnyTimes()
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil)
+	svc, err := service.New(st, service.Config{})
+	require.NoError(t, err)
+
+	// one head more than the page size tells whether there is a next page
+	page, err := svc.Search(context.Background(), service.SearchQuery{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 3)
+	require.NotEmpty(t, page.Next)
+	require.Equal(t, []string{"urn:uuid:00-1", "urn:uuid:01-1", "urn:uuid:02-1", "urn:uuid:03-1"}, heads)
+
+	// objects preceding the cursor are not read again
+	heads = nil
+	page, err = svc.Search(context.Background(), service.SearchQuery{Limit: 3, Cursor: page.Next})
+	require.NoError(t, err)
+	require.Equal(t, "urn:uuid:03", page.Items[0].SerialNumber)
+	require.Equal(t, []string{"urn:uuid:03-1", "urn:uuid:04-1", "urn:uuid:05-1", "urn:uuid:06-1"}, heads)
+}
+
+func TestSearch_LatestOnlyVersionCountFromListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	// a single listing, no listing of the versions per serial number
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().ListObjectsV2(gomock.Any(), gomock.Any(), gomock.Any()).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: awsString("urn:uuid:a-original"), LastModified: &older},
+			{Key: awsString("urn:uuid:a-1"), LastModified: &older},
+			{Key: awsString("urn:uuid:a-2"), LastModified: &older},
+			{Key: awsString("urn:uuid:a-3"), LastModified: &newer},
+			{Key: awsString("urn:uuid:b-1"), LastModified: &newer},
+		},
+	}, nil).Times(1)
+	var heads []string
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			heads = append(heads, *in.Key)
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(1),
+				ContentType:   aws.String("application/json"),
+				LastModified:  &newer,
+				Metadata:      map[string]string{store.MetaCryptoStatsKey: "{}"},
+			}, nil
+		}).AnyTimes()
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil)
+	svc, err := service.New(st, service.Config{})
+	require.NoError(t, err)
+
+	// versions older than `after` are counted, but not read
+	page, err := svc.Search(context.Background(), service.SearchQuery{After: older.Add(time.Minute).Unix(), LatestOnly: true})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	counts := map[string]int{}
+	for _, item := range page.Items {
+		counts[item.SerialNumber+"-"+item.Version] = item.VersionCount
+	}
+	require.Equal(t, map[string]int{"urn:uuid:a-3": 3, "urn:uuid:b-1": 1}, counts)
+	require.ElementsMatch(t, []string{"urn:uuid:a-3", "urn:uuid:b-1"}, heads)
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{SearchMaxPageSize: 4})
+	require.NoError(t, err)
+
+	for range 5 {
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+		require.NoError(t, err)
+	}
+
+	all, err := svc.Search(ctx, service.SearchQuery{})
+	require.NoError(t, err)
+	// maximum page size applies when no limit is requested
+	require.Len(t, all.Items, 4)
+	require.NotEmpty(t, all.Next)
+
+	// walk all ten objects (five originals and five versions) page by page
+	var (
+		seen   = map[string]struct{}{}
+		cursor string
+		pages  int
+	)
+	for {
+		page, err := svc.Search(ctx, service.SearchQuery{Limit: 3, Cursor: cursor})
+		require.NoError(t, err)
+		pages++
+		for _, item := range page.Items {
+			key := fmt.Sprintf("%s-%s", item.SerialNumber, item.Version)
+			require.NotContains(t, seen, key)
+			seen[key] = struct{}{}
+		}
+		if page.Next == "" {
+			break
+		}
+		require.Len(t, page.Items, 3)
+		cursor = page.Next
+	}
+	require.Len(t, seen, 10)
+	require.Equal(t, 4, pages)
+
+	// limit is capped by the maximum page size
+	page, err := svc.Search(ctx, service.SearchQuery{Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 4)
+}
+
+func TestSearch_Filters(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	const (
+		withCert    = "urn:uuid:1a6a6b58-bb3e-4b3c-9d4b-f6c6f8b5b001"
+		withoutCert = "urn:uuid:2b7b7c69-cc4f-4c4d-8e5c-07d709c6c002"
+	)
+	upload := func(serial string, version int, certificates int) {
+		var components []string
+		components = append(components, `{"name":"AES","type":"cryptographic-asset","bom-ref":"algo","cryptoProperties":{"assetType":"algorithm"}}`)
+		for i := range certificates {
+			components = append(components, fmt.Sprintf(`{"name":"cert%d","type":"cryptographic-asset","bom-ref":"cert%d","cryptoProperties":{"assetType":"certificate"}}`, i, i))
+		}
+		body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q,"version":%d,"components":[%s]}`,
+			serial, version, strings.Join(components, ","))
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+		require.NoError(t, err)
+	}
+	upload(withCert, 1, 1)
+	upload(withCert, 2, 2)
+	upload(withoutCert, 1, 0)
+
+	search := func(query service.SearchQuery) []string {
+		page, err := svc.Search(ctx, query)
+		require.NoError(t, err)
+		res := []string{}
+		for _, item := range page.Items {
+			res = append(res, item.SerialNumber+"-"+item.Version)
+		}
+		return res
+	}
+	yes, no := true, false
+
+	require.Len(t, search(service.SearchQuery{}), 3)
+	require.Empty(t, search(service.SearchQuery{Before: time.Now().Add(-time.Hour).Unix()}))
+	require.Len(t, search(service.SearchQuery{Before: time.Now().Add(time.Hour).Unix()}), 3)
+	require.ElementsMatch(t, []string{withCert + "-1", withCert + "-2"},
+		search(service.SearchQuery{SerialNumberPrefix: "urn:uuid:1a6a"}))
+	require.ElementsMatch(t, []string{withCert + "-2", withoutCert + "-1"},
+		search(service.SearchQuery{LatestOnly: true}))
+	require.ElementsMatch(t, []string{withCert + "-1", withCert + "-2"},
+		search(service.SearchQuery{CryptoStats: service.CryptoStatsFilter{HasCertificates: &yes}}))
+	require.ElementsMatch(t, []string{withoutCert + "-1"},
+		search(service.SearchQuery{CryptoStats: service.CryptoStatsFilter{HasCertificates: &no}}))
+	require.ElementsMatch(t, []string{withCert + "-2"},
+		search(service.SearchQuery{CryptoStats: service.CryptoStatsFilter{MinCertificates: 2}}))
+	require.ElementsMatch(t, []string{withCert + "-1", withCert + "-2", withoutCert + "-1"},
+		search(service.SearchQuery{CryptoStats: service.CryptoStatsFilter{MinAlgorithms: 1}}))
+	require.Empty(t, search(service.SearchQuery{CryptoStats: service.CryptoStatsFilter{MinCryptoAssets: 4}}))
+	// latest only is applied after the other criteria
+	require.ElementsMatch(t, []string{withCert + "-2"},
+		search(service.SearchQuery{LatestOnly: true, CryptoStats: service.CryptoStatsFilter{HasCertificates: &yes}}))
+}
+
+func TestSearch_LatestOnly(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	const serial = "urn:uuid:3c8c8d7a-dd5a-4d5e-9f6d-18e81ad7d003"
+	bodies := []string{
+		// version 1 carries a certificate, version 2 does not
+		`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q,"components":[{"name":"cert","type":"cryptographic-asset","bom-ref":"cert","cryptoProperties":{"assetType":"certificate"}}]}`,
+		`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`,
+	}
+	for _, body := range bodies {
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(fmt.Sprintf(body, serial))), "1.6")
+		require.NoError(t, err)
+	}
+	// a BOM with generated serial number, stored as version 1 and original
+	created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+	require.NoError(t, err)
+
+	page, err := svc.Search(ctx, service.SearchQuery{LatestOnly: true})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	got := map[string]service.SearchRes{}
+	for _, item := range page.Items {
+		got[item.SerialNumber] = item
+	}
+	require.Equal(t, "2", got[serial].Version)
+	require.Equal(t, 2, got[serial].VersionCount)
+	require.Equal(t, "1", got[created.SerialNumber].Version)
+	require.Equal(t, 1, got[created.SerialNumber].VersionCount)
+
+	// the latest matching version is returned, the count covers all versions
+	yes := true
+	page, err = svc.Search(ctx, service.SearchQuery{
+		LatestOnly:         true,
+		SerialNumberPrefix: serial,
+		CryptoStats:        service.CryptoStatsFilter{HasCertificates: &yes},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "1", page.Items[0].Version)
+	require.Equal(t, 1, page.Items[0].CryptoStats.CryptoAsset.Cert.Total)
+	require.Equal(t, 2, page.Items[0].VersionCount)
+
+	// soft deleted versions are not counted
+	_, err = svc.DeleteBOM(ctx, serial, "1")
+	require.NoError(t, err)
+	page, err = svc.Search(ctx, service.SearchQuery{LatestOnly: true, SerialNumberPrefix: serial})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "2", page.Items[0].Version)
+	require.Equal(t, 1, page.Items[0].VersionCount)
+
+	// version count is not reported outside of the latest-only mode
+	page, err = svc.Search(ctx, service.SearchQuery{})
+	require.NoError(t, err)
+	for _, item := range page.Items {
+		require.Zero(t, item.VersionCount)
+	}
+}
+
+func TestSearch_LatestOnlyOrdering(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	const (
+		first  = "urn:uuid:4d9d9e8b-ee6b-4e6f-8a7e-29f92be8e004"
+		second = "urn:uuid:5eaeaf9c-ff7c-4f7a-9b8f-3a0a3cf9f005"
+	)
+	upload := func(serial string, certificate bool) {
+		body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)
+		if certificate {
+			body = fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q,"components":[{"name":"cert","type":"cryptographic-asset","bom-ref":"cert","cryptoProperties":{"assetType":"certificate"}}]}`, serial)
+		}
+		_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+		require.NoError(t, err)
+	}
+	upload(first, true)
+	upload(second, true)
+	upload(first, false)
+
+	// the latest matching version of the first serial number precedes the
+	// second one, although its latest version does not
+	yes := true
+	query := service.SearchQuery{LatestOnly: true, Limit: 1, CryptoStats: service.CryptoStatsFilter{HasCertificates: &yes}}
+	page, err := svc.Search(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, first, page.Items[0].SerialNumber)
+	require.Equal(t, "1", page.Items[0].Version)
+	require.NotEmpty(t, page.Next)
+
+	query.Cursor = page.Next
+	page, err = svc.Search(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, second, page.Items[0].SerialNumber)
+	require.Empty(t, page.Next)
+}
+
+func TestCryptoStatsFilter_Match(t *testing.T) {
+	yes, no := true, false
+	stats := service.CryptoStats{CryptoAsset: service.CryptoAssetStats{
+		Total: 3,
+		Algo:  service.AlgorithmStats{Total: 2},
+		Cert:  service.TotalStats{Total: 1},
+	}}
+
+	require.True(t, service.CryptoStatsFilter{}.Match(stats))
+	require.True(t, service.CryptoStatsFilter{MinCryptoAssets: 3, MinAlgorithms: 2, MinCertificates: 1}.Match(stats))
+	require.False(t, service.CryptoStatsFilter{MinAlgorithms: 3}.Match(stats))
+	require.True(t, service.CryptoStatsFilter{HasAlgorithms: &yes, HasProtocols: &no}.Match(stats))
+	require.False(t, service.CryptoStatsFilter{HasProtocols: &yes}.Match(stats))
+	require.False(t, service.CryptoStatsFilter{HasRelatedCryptoMaterials: &yes}.Match(stats))
+}
+
+func TestSearch_InvalidCursor(t *testing.T) {
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err = svc.Search(context.Background(), service.SearchQuery{Cursor: cursor})
+		require.ErrorIs(t, err, service.ErrValidation, cursor)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+)
+
+// RuleMode is the action taken when a semantic validation rule is violated.
+type RuleMode string
+
+const (
+	// RuleReject rejects the BOM with ErrValidation.
+	RuleReject RuleMode = "reject"
+	// RuleWarn accepts the BOM and reports the violation in BOMCreated.
+	RuleWarn RuleMode = "warn"
+	// RuleOff disables the rule, as does an empty mode.
+	RuleOff RuleMode = "off"
+)
+
+// Names of the semantic validation rules.
+const (
+	RuleBOMRefUnique        = "bom-ref-unique"
+	RuleDependencyRefs      = "dependency-refs"
+	RuleOIDSyntax           = "oid-syntax"
+	RuleAssetTypeProperties = "asset-type-properties"
+)
+
+// SemanticConfig sets the mode of each semantic validation rule, the rules
+// check what the schema can't express and run after the schema validation.
+type SemanticConfig struct {
+	// BOMRefUnique requires the bom-refs of components and services to be unique.
+	BOMRefUnique RuleMode `envconfig:"APP_SEMANTIC_BOM_REF_UNIQUE" default:"warn"`
+	// DependencyRefs requires the refs of the dependency graph to resolve to
+	// a component or service of the BOM.
+	DependencyRefs RuleMode `envconfig:"APP_SEMANTIC_DEPENDENCY_REFS" default:"warn"`
+	// OIDSyntax requires the `cryptoProperties.oid` of crypto assets to be a
+	// dotted decimal object identifier.
+	OIDSyntax RuleMode `envconfig:"APP_SEMANTIC_OID_SYNTAX" default:"warn"`
+	// AssetTypeProperties requires the populated properties block of crypto
+	// assets to match their `cryptoProperties.assetType`.
+	AssetTypeProperties RuleMode `envconfig:"APP_SEMANTIC_ASSET_TYPE_PROPERTIES" default:"warn"`
+}
+
+// Modes returns the mode of every rule by its name.
+func (c SemanticConfig) Modes() map[string]RuleMode {
+	return map[string]RuleMode{
+		RuleBOMRefUnique:        c.BOMRefUnique,
+		RuleDependencyRefs:      c.DependencyRefs,
+		RuleOIDSyntax:           c.OIDSyntax,
+		RuleAssetTypeProperties: c.AssetTypeProperties,
+	}
+}
+
+// SemanticIssue is a single violation of a semantic validation rule.
+type SemanticIssue struct {
+	// Rule is the name of the violated rule.
+	Rule string `json:"rule"`
+	// Pointer is the JSON pointer of the offending part of the BOM.
+	Pointer string `json:"pointer"`
+	// Message is the human readable description of the violation.
+	Message string `json:"message"`
+}
+
+// SemanticError is returned when an uploaded BOM violates a semantic rule in
+// the reject mode. It wraps ErrValidation, so errors.Is(err, ErrValidation)
+// holds for it.
+type SemanticError struct {
+	// Issues of the rejecting rules, capped to Config.MaxValidationErrors.
+	Issues []SemanticIssue
+	// Total is the number of issues found, it exceeds the length of Issues
+	// if the list was capped.
+	Total int
+}
+
+func (e *SemanticError) Error() string {
+	return fmt.Sprintf("%s: violates semantic rules", ErrValidation)
+}
+
+func (e *SemanticError) Unwrap() error {
+	return ErrValidation
+}
+
+// oidPattern matches the dotted decimal notation of object identifiers.
+var oidPattern = regexp.MustCompile(`^[0-2](\.(0|[1-9][0-9]*))+$`)
+
+// assetTypeProperties lists the properties blocks of crypto assets along
+// with the asset type they belong to.
+var assetTypeProperties = []struct {
+	assetType cdx.CryptoAssetType
+	name      string
+	populated func(*cdx.CryptoProperties) bool
+}{
+	{cdx.CryptoAssetTypeAlgorithm, "algorithmProperties", func(p *cdx.CryptoProperties) bool { return p.AlgorithmProperties != nil }},
+	{cdx.CryptoAssetTypeCertificate, "certificateProperties", func(p *cdx.CryptoProperties) bool { return p.CertificateProperties != nil }},
+	{cdx.CryptoAssetTypeRelatedCryptoMaterial, "relatedCryptoMaterialProperties", func(p *cdx.CryptoProperties) bool { return p.RelatedCryptoMaterialProperties != nil }},
+	{cdx.CryptoAssetTypeProtocol, "protocolProperties", func(p *cdx.CryptoProperties) bool { return p.ProtocolProperties != nil }},
+}
+
+// semanticCheck collects the issues of a single BOM.
+type semanticCheck struct {
+	modes    map[string]RuleMode
+	refs     map[string]struct{}
+	rejected []SemanticIssue
+	warnings []SemanticIssue
+}
+
+// checkSemantics runs the enabled semantic rules on the BOM. It returns the
+// issues of the rules in the reject mode and of those in the warn mode.
+func checkSemantics(bom *cdx.BOM, cfg SemanticConfig) ([]SemanticIssue, []SemanticIssue) {
+	c := &semanticCheck{
+		modes: cfg.Modes(),
+		refs:  make(map[string]struct{}),
+	}
+
+	if bom.Metadata != nil && bom.Metadata.Component != nil {
+		c.component("/metadata/component", bom.Metadata.Component)
+	}
+	c.components("/components", bom.Components)
+	c.services("/services", bom.Services)
+	// dependencies may reference any component or service, so they are
+	// resolved once all bom-refs are known
+	if bom.Dependencies != nil {
+		for i, dep := range *bom.Dependencies {
+			c.dependencyRef(fmt.Sprintf("/dependencies/%d/ref", i), dep.Ref)
+			if dep.Dependencies == nil {
+				continue
+			}
+			for j, ref := range *dep.Dependencies {
+				c.dependencyRef(fmt.Sprintf("/dependencies/%d/dependsOn/%d", i, j), ref)
+			}
+		}
+	}
+
+	return c.rejected, c.warnings
+}
+
+func (c *semanticCheck) report(rule, pointer, format string, args ...any) {
+	issue := SemanticIssue{
+		Rule:    rule,
+		Pointer: pointer,
+		Message: fmt.Sprintf(format, args...),
+	}
+	switch c.modes[rule] {
+	case RuleReject:
+		c.rejected = append(c.rejected, issue)
+	case RuleWarn:
+		c.warnings = append(c.warnings, issue)
+	}
+}
+
+func (c *semanticCheck) components(pointer string, components *[]cdx.Component) {
+	if components == nil {
+		return
+	}
+	for i := range *components {
+		c.component(fmt.Sprintf("%s/%d", pointer, i), &(*components)[i])
+	}
+}
+
+func (c *semanticCheck) component(pointer string, component *cdx.Component) {
+	c.bomRef(pointer+"/bom-ref", component.BOMRef)
+	if props := component.CryptoProperties; props != nil {
+		if props.OID != "" && !oidPattern.MatchString(props.OID) {
+			c.report(RuleOIDSyntax, pointer+"/cryptoProperties/oid",
+				"%q is not an object identifier in dotted decimal notation", props.OID)
+		}
+		for _, p := range assetTypeProperties {
+			if p.populated(props) && p.assetType != props.AssetType {
+				c.report(RuleAssetTypeProperties, pointer+"/cryptoProperties/"+p.name,
+					"%s populated for assetType %q, expected for %q", p.name, props.AssetType, p.assetType)
+			}
+		}
+	}
+	c.components(pointer+"/components", component.Components)
+}
+
+func (c *semanticCheck) services(pointer string, services *[]cdx.Service) {
+	if services == nil {
+		return
+	}
+	for i := range *services {
+		p := fmt.Sprintf("%s/%d", pointer, i)
+		c.bomRef(p+"/bom-ref", (*services)[i].BOMRef)
+		c.services(p+"/services", (*services)[i].Services)
+	}
+}
+
+func (c *semanticCheck) bomRef(pointer, ref string) {
+	if ref == "" {
+		return
+	}
+	if _, ok := c.refs[ref]; ok {
+		c.report(RuleBOMRefUnique, pointer, "bom-ref %q is not unique", ref)
+		return
+	}
+	c.refs[ref] = struct{}{}
+}
+
+func (c *semanticCheck) dependencyRef(pointer, ref string) {
+	if _, ok := c.refs[ref]; !ok {
+		c.report(RuleDependencyRefs, pointer, "ref %q does not match a bom-ref of a component or service", ref)
+	}
+}
+
+// capIssues returns at most `limit` issues.
+func capIssues(issues []SemanticIssue, limit int) []SemanticIssue {
+	if len(issues) > limit {
+		return issues[:limit]
+	}
+	return issues
+}
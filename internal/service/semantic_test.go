@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSemantics(t *testing.T) {
+	all := SemanticConfig{
+		BOMRefUnique:        RuleReject,
+		DependencyRefs:      RuleReject,
+		OIDSyntax:           RuleReject,
+		AssetTypeProperties: RuleReject,
+	}
+
+	tests := map[string]struct {
+		bom  cdx.BOM
+		want []SemanticIssue
+	}{
+		"valid": {
+			bom: cdx.BOM{
+				Metadata: &cdx.Metadata{Component: &cdx.Component{BOMRef: "app"}},
+				Components: &[]cdx.Component{
+					{BOMRef: "aes", CryptoProperties: &cdx.CryptoProperties{
+						AssetType:           cdx.CryptoAssetTypeAlgorithm,
+						AlgorithmProperties: &cdx.CryptoAlgorithmProperties{},
+						OID:                 "2.16.840.1.101.3.4.1.6",
+					}},
+				},
+				Services:     &[]cdx.Service{{BOMRef: "tls-endpoint"}},
+				Dependencies: &[]cdx.Dependency{{Ref: "app", Dependencies: &[]string{"aes", "tls-endpoint"}}},
+			},
+		},
+		"duplicate bom-ref": {
+			bom: cdx.BOM{
+				Components: &[]cdx.Component{
+					{BOMRef: "aes"},
+					{BOMRef: "lib", Components: &[]cdx.Component{{BOMRef: "aes"}}},
+				},
+			},
+			want: []SemanticIssue{
+				{Rule: RuleBOMRefUnique, Pointer: "/components/1/components/0/bom-ref"},
+			},
+		},
+		"unresolved dependency": {
+			bom: cdx.BOM{
+				Components:   &[]cdx.Component{{BOMRef: "aes"}},
+				Dependencies: &[]cdx.Dependency{{Ref: "aes", Dependencies: &[]string{"rsa"}}, {Ref: "app"}},
+			},
+			want: []SemanticIssue{
+				{Rule: RuleDependencyRefs, Pointer: "/dependencies/0/dependsOn/0"},
+				{Rule: RuleDependencyRefs, Pointer: "/dependencies/1/ref"},
+			},
+		},
+		"invalid oid": {
+			bom: cdx.BOM{
+				Components: &[]cdx.Component{
+					{CryptoProperties: &cdx.CryptoProperties{AssetType: cdx.CryptoAssetTypeAlgorithm, OID: "2.16.840.01"}},
+					{CryptoProperties: &cdx.CryptoProperties{AssetType: cdx.CryptoAssetTypeAlgorithm, OID: "aes-128"}},
+				},
+			},
+			want: []SemanticIssue{
+				{Rule: RuleOIDSyntax, Pointer: "/components/0/cryptoProperties/oid"},
+				{Rule: RuleOIDSyntax, Pointer: "/components/1/cryptoProperties/oid"},
+			},
+		},
+		"properties of another asset type": {
+			bom: cdx.BOM{
+				Components: &[]cdx.Component{
+					{CryptoProperties: &cdx.CryptoProperties{
+						AssetType:             cdx.CryptoAssetTypeAlgorithm,
+						AlgorithmProperties:   &cdx.CryptoAlgorithmProperties{},
+						CertificateProperties: &cdx.CertificateProperties{},
+					}},
+				},
+			},
+			want: []SemanticIssue{
+				{Rule: RuleAssetTypeProperties, Pointer: "/components/0/cryptoProperties/certificateProperties"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rejected, warnings := checkSemantics(&tc.bom, all)
+			require.Empty(t, warnings)
+			require.Len(t, rejected, len(tc.want))
+			for i, want := range tc.want {
+				require.Equal(t, want.Rule, rejected[i].Rule)
+				require.Equal(t, want.Pointer, rejected[i].Pointer)
+				require.NotEmpty(t, rejected[i].Message)
+			}
+		})
+	}
+}
+
+func TestCheckSemantics_Modes(t *testing.T) {
+	bom := cdx.BOM{
+		Components:   &[]cdx.Component{{BOMRef: "aes"}, {BOMRef: "aes"}},
+		Dependencies: &[]cdx.Dependency{{Ref: "rsa"}},
+	}
+
+	rejected, warnings := checkSemantics(&bom, SemanticConfig{BOMRefUnique: RuleWarn, DependencyRefs: RuleReject})
+	require.Len(t, rejected, 1)
+	require.Equal(t, RuleDependencyRefs, rejected[0].Rule)
+	require.Len(t, warnings, 1)
+	require.Equal(t, RuleBOMRefUnique, warnings[0].Rule)
+
+	rejected, warnings = checkSemantics(&bom, SemanticConfig{BOMRefUnique: RuleOff})
+	require.Empty(t, rejected)
+	require.Empty(t, warnings)
+}
+
+func TestUploadBOM_Semantic(t *testing.T) {
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [
+			{"type": "library", "name": "first", "bom-ref": "lib"},
+			{"type": "library", "name": "second", "bom-ref": "lib"}
+		]
+	}`
+
+	// warnings are returned along with the created BOM
+	svc, err := New(store.NewMemory(), Config{MaxValidationErrors: 20, Semantic: SemanticConfig{BOMRefUnique: RuleWarn}})
+	require.NoError(t, err)
+	created, err := svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Len(t, created.Warnings, 1)
+	require.Equal(t, SemanticIssue{
+		Rule:    RuleBOMRefUnique,
+		Pointer: "/components/1/bom-ref",
+		Message: `bom-ref "lib" is not unique`,
+	}, created.Warnings[0])
+
+	svc, err = New(store.NewMemory(), Config{MaxValidationErrors: 20, Semantic: SemanticConfig{BOMRefUnique: RuleReject}})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorIs(t, err, ErrValidation)
+	var semanticErr *SemanticError
+	require.ErrorAs(t, err, &semanticErr)
+	require.Equal(t, 1, semanticErr.Total)
+	require.Len(t, semanticErr.Issues, 1)
+}
@@ -1,11 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"maps"
 	"slices"
@@ -16,6 +18,7 @@ import (
 	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
 	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
 
+	cdx "github.com/CycloneDX/cyclonedx-go"
 	"github.com/google/uuid"
 	jss "github.com/kaptinlin/jsonschema"
 )
@@ -33,19 +36,61 @@ var schemas embed.FS
 // add the schema file into the `schemas` subdirectory in `interna/service`
 // and then extend this variable with the mapping.
 var versionToEmbeddedFileMapping = map[string]string{
+	"1.4": "schemas/bom-1.4.schema.json",
+	"1.5": "schemas/bom-1.5.schema.json",
 	"1.6": "schemas/bom-1.6.schema.json",
 }
 
+// embeddedSubSchemaFiles are schemas referenced by the BOM schemas. They are
+// compiled ahead of the BOM schemas, so that the references resolve against
+// the bundled copies by their `$id` instead of being fetched.
+var embeddedSubSchemaFiles = []string{
+	"schemas/spdx.schema.json",
+	"schemas/jsf-0.82.schema.json",
+}
+
+// versionToEmbeddedXSDMapping are the XML schemas the XML documents of the
+// supported versions are validated against, the schemas they import are
+// listed in embeddedSubXSDFiles.
+var versionToEmbeddedXSDMapping = map[string]string{
+	"1.4": "schemas/bom-1.4.xsd",
+	"1.5": "schemas/bom-1.5.xsd",
+	"1.6": "schemas/bom-1.6.xsd",
+}
+
+// embeddedSubXSDFiles are XML schemas imported by the BOM XML schemas, they
+// are compiled ahead of them.
+var embeddedSubXSDFiles = []string{
+	"schemas/spdx.xsd",
+}
+
 type Config struct {
 	// CheckOnFetch controls whether service performs an unmarshal attempt on the array
 	// of bytes received from backend storage (minio/s3) for get operation.
 	CheckOnFetch bool `envconfig:"APP_CHECK_ON_FETCH" default:"false"`
+	// SearchMaxPageSize is the maximum number of results returned by a single
+	// search, it is also used when the caller does not ask for a page size.
+	// Zero means unbounded.
+	SearchMaxPageSize int `envconfig:"APP_SEARCH_MAX_PAGE_SIZE" default:"1000"`
+	// MaxValidationErrors is the maximum number of schema violations reported
+	// for a rejected BOM. Zero reports none of them.
+	MaxValidationErrors int `envconfig:"APP_MAX_VALIDATION_ERRORS" default:"20"`
+	// Semantic configures the validation rules run after the schema validation.
+	Semantic SemanticConfig
+	// Policy configures the policies evaluated after the validation.
+	Policy PolicyConfig
+	// Retention configures pruning of old BOM versions.
+	Retention RetentionConfig
+	// Upload configures buffering of uploaded BOMs.
+	Upload UploadConfig
 }
 
 type Service struct {
 	config      Config
-	store       store.Store
+	store       store.Backend
 	jsonSchemas map[string]*jss.Schema
+	xmlSchemas  map[string]*xsdSchema
+	policies    []Policy
 }
 
 // New creates and initializes a new Service instance with the provided store.
@@ -59,24 +104,41 @@ type Service struct {
 //
 // Supported schema versions are defined in the versionToEmbeddedFileMapping variable.
 // To add support for a new CycloneDX version, place the schema file in the schemas
-// subdirectory and update the mapping.
+// subdirectory and update the mapping. Schemas referenced by the BOM schemas
+// (spdx, jsf) are listed in embeddedSubSchemaFiles and share the compiler with
+// them. The XML schemas of the versions are mapped in versionToEmbeddedXSDMapping
+// the same way.
+//
+// The policy file of Config.Policy is loaded as well, if it is set. An
+// unreadable or invalid policy file fails the initialization.
 //
 // Parameters:
 //   - store: The storage backend used for persisting and retrieving BOM documents
 //
 // Returns:
 //   - Service: An initialized service ready to handle BOM operations
-//   - error: Non-nil if any schema file cannot be read or compiled, nil otherwise
-func New(store store.Store, config Config) (Service, error) {
+//   - error: Non-nil if any schema file cannot be read or compiled, or the policy
+//     file cannot be loaded, nil otherwise
+func New(store store.Backend, config Config) (Service, error) {
+
+	compiler := jss.NewCompiler()
+	for _, filename := range embeddedSubSchemaFiles {
+		b, err := readEmbeddedSchema(filename)
+		if err != nil {
+			return Service{}, err
+		}
+		if _, err := compiler.Compile(b); err != nil {
+			return Service{}, fmt.Errorf("failed to compile sub-schema %s: %w", filename, err)
+		}
+	}
 
 	jsonSchemas := make(map[string]*jss.Schema)
 	for version, filename := range versionToEmbeddedFileMapping {
-		b, err := schemas.ReadFile(filename)
+		b, err := readEmbeddedSchema(filename)
 		if err != nil {
-			return Service{}, fmt.Errorf("failed to read embedded file %s: %w", filename, err)
+			return Service{}, err
 		}
 
-		compiler := jss.NewCompiler()
 		schema, err := compiler.Compile(b)
 		if err != nil {
 			return Service{}, fmt.Errorf("failed to compile schema: %w", err)
@@ -84,100 +146,127 @@ func New(store store.Store, config Config) (Service, error) {
 		jsonSchemas[version] = schema
 	}
 
+	xmlSchemas, err := compileEmbeddedXSDs()
+	if err != nil {
+		return Service{}, err
+	}
+
+	var policies []Policy
+	if config.Policy.File != "" {
+		var err error
+		if policies, err = LoadPolicies(config.Policy.File); err != nil {
+			return Service{}, err
+		}
+	}
+
 	return Service{
 		jsonSchemas: jsonSchemas,
+		xmlSchemas:  xmlSchemas,
+		policies:    policies,
 		store:       store,
 		config:      config,
 	}, nil
 }
 
-func (s Service) SupportedVersion() []string {
-	return slices.Sorted(maps.Keys(s.jsonSchemas))
-}
+// compileEmbeddedXSDs compiles the embedded XML schemas by the version.
+func compileEmbeddedXSDs() (map[string]*xsdSchema, error) {
+	var imports []*xsdSchema
+	for _, filename := range embeddedSubXSDFiles {
+		b, err := schemas.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded file %s: %w", filename, err)
+		}
+		schema, err := compileXSD(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile XML schema %s: %w", filename, err)
+		}
+		imports = append(imports, schema)
+	}
 
-func (s Service) VersionSupported(version string) bool {
-	if _, ok := s.jsonSchemas[version]; ok {
-		return true
+	res := make(map[string]*xsdSchema)
+	for version, filename := range versionToEmbeddedXSDMapping {
+		b, err := schemas.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded file %s: %w", filename, err)
+		}
+		schema, err := compileXSD(b, imports...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile XML schema %s: %w", filename, err)
+		}
+		res[version] = schema
 	}
-	return false
+	return res, nil
 }
 
-type SearchRes struct {
-	SerialNumber string      `json:"serialNumber"`
-	Version      string      `json:"version"`
-	Timestamp    string      `json:"created_at"`
-	CryptoStats  CryptoStats `json:"cryptoStats"`
+// readEmbeddedSchema reads an embedded draft-07 schema and converts it for
+// the 2020-12 compiler, see convertDraft07.
+func readEmbeddedSchema(filename string) ([]byte, error) {
+	b, err := schemas.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded file %s: %w", filename, err)
+	}
+	b, err = convertDraft07(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert embedded file %s: %w", filename, err)
+	}
+	return b, nil
 }
 
-// Search retrieves all BOMs with a last modified timestamp greater than the specified value.
-// The function queries the underlying store for matching BOMs and enriches each result with
-// cryptographic asset statistics extracted from object metadata.
-//
-// Parameters:
-//   - ctx: Context for cancellation, deadlines, and additional slog fields.
-//   - ts: Unix timestamp (seconds since epoch); only BOMs modified after this time are returned
-//
-// Returns:
-//   - []SearchRes: Slice of search results containing serial number, version, timestamp, and crypto statistics
-//   - error: Non-nil if the store query fails, key format is invalid, or JSON unmarshaling fails
-func (s Service) Search(ctx context.Context, ts int64) ([]SearchRes, error) {
-	res := []SearchRes{}
-
-	ctx = log.ContextAttrs(ctx, slog.Int64("timestamp", ts))
-	slog.DebugContext(ctx, "Calling `store.Search()`.")
-
-	r, err := s.store.Search(ctx, ts)
-	if err != nil {
+// convertDraft07 converts the draft-07 keywords used by the CycloneDX schemas
+// to their 2020-12 counterparts understood by the compiler:
+//   - the root `definitions` are moved to `$defs` and the references to them
+//     rewritten, the compiler resolves JSON pointers through `$defs` only and
+//     would silently skip the subschemas behind unresolved references
+//   - tuple `items` become `prefixItems`, and `additionalItems` the `items`
+//     applied to the rest of the array
+func convertDraft07(b []byte) ([]byte, error) {
+	var schema map[string]any
+	if err := json.Unmarshal(b, &schema); err != nil {
 		return nil, err
 	}
+	if defs, ok := schema["definitions"]; ok {
+		schema["$defs"] = defs
+		delete(schema, "definitions")
+	}
 
-	slog.DebugContext(ctx, "`store.Search()` finished.",
-		slog.Int("count", len(r)),
-		slog.String("value", strings.Join(r, ",")),
-	)
-
-	for _, cpy := range r {
-		idx := strings.LastIndex(cpy, "-")
-		if idx == -1 {
-			slog.ErrorContext(ctx, "Key does NOT adhere to the naming invariant.",
-				slog.String("key", cpy), slog.String("expected-format", "urn:uuid:<uuid>-<version>"))
-			return nil, errors.New("unexpected key returned from store")
-		}
-
-		head, err := s.store.GetHeadObject(ctx, cpy)
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			slog.WarnContext(ctx, fmt.Sprintf("Fetching HeadObject for key %q failed although version was previously returned by `store.Search()`. Skipping from result set.", cpy))
-			continue
-
-		case err != nil:
-			return nil, err
-		}
-
-		cryptoStatsValue, ok := head.Metadata[store.MetaCryptoStatsKey]
-		if !ok {
-			slog.WarnContext(ctx,
-				fmt.Sprintf("There is no key %q in object metadata. Skipping from result set.", store.MetaCryptoStatsKey),
-				slog.String("object-key", cpy))
-			continue
+	var rewrite func(v any)
+	rewrite = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				v["$ref"] = strings.Replace(ref, "#/definitions/", "#/$defs/", 1)
+			}
+			if items, ok := v["items"].([]any); ok {
+				v["prefixItems"] = items
+				delete(v, "items")
+				if additional, ok := v["additionalItems"]; ok {
+					v["items"] = additional
+				}
+			}
+			// additionalItems has no effect without tuple items
+			delete(v, "additionalItems")
+			for _, value := range v {
+				rewrite(value)
+			}
+		case []any:
+			for _, value := range v {
+				rewrite(value)
+			}
 		}
+	}
+	rewrite(schema)
+	return json.Marshal(schema)
+}
 
-		var cryptoStats CryptoStats
-		if err := json.Unmarshal([]byte(cryptoStatsValue), &cryptoStats); err != nil {
-			slog.ErrorContext(ctx,
-				fmt.Sprintf("Unmarshaling metadata key %q value failed.", store.MetaCryptoStatsKey),
-				slog.String("error", err.Error()), slog.String("object-key", cpy))
-			return res, errors.New("unmarshaling json failed")
-		}
+func (s Service) SupportedVersion() []string {
+	return slices.Sorted(maps.Keys(s.jsonSchemas))
+}
 
-		res = append(res, SearchRes{
-			SerialNumber: cpy[:idx],
-			Version:      cpy[idx+1:],
-			Timestamp:    head.LastModified.Format(time.RFC3339),
-			CryptoStats:  cryptoStats,
-		})
+func (s Service) VersionSupported(version string) bool {
+	if _, ok := s.jsonSchemas[version]; ok {
+		return true
 	}
-	return res, nil
+	return false
 }
 
 // GetBOMByUrn retrieves a BOM document by its URN and version.
@@ -185,11 +274,12 @@ func (s Service) Search(ctx context.Context, ts int64) ([]SearchRes, error) {
 // The function returns the BOM as a byte slice to preserve the original JSON structure
 // and allow flexible handling of different CycloneDX schema versions as well as allowing
 // callers to decide, through service configuration, whether check the contents received
-// from backend storage or not.
+// from backend storage or not. See OpenBOMByUrn for the streaming variant.
 //
 // Version Selection:
 //   - If version is specified: Retrieves that specific version
 //   - If version is empty: Automatically selects and retrieves the latest version
+//     which is not soft deleted
 //
 // Parameters:
 //   - ctx: Context for cancellation, deadlines, and additional slog fields
@@ -198,57 +288,140 @@ func (s Service) Search(ctx context.Context, ts int64) ([]SearchRes, error) {
 //
 // Returns:
 //   - []byte: The BOM document as a byte slice
-//   - error: Returns ErrNotFound if the URN or version doesn't exist,
-//     or other errors from the store or JSON unmarshaling
+//   - error: Returns ErrNotFound if the URN or version doesn't exist or is soft
+//     deleted, or other errors from the store or JSON unmarshaling
 func (s Service) GetBOMByUrn(ctx context.Context, urn, version string) ([]byte, error) {
+	obj, err := s.OpenBOMByUrn(ctx, urn, version)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = obj.Close()
+	}()
+
+	b, err := io.ReadAll(obj)
+	if err != nil {
+		slog.ErrorContext(ctx, "`io.ReadAll()` failed.", slog.String("error", err.Error()))
+		return nil, err
+	}
+	return b, nil
+}
+
+// OpenBOMByUrn opens a BOM document identified by its URN and version for
+// reading, see GetBOMByUrn for the version selection. The document is streamed
+// from the backend storage as it is read, so that serving it does not hold the
+// whole document in memory. The only exception is the `CheckOnFetch` service
+// configuration, which needs the whole document to check it before it is
+// returned.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines, and additional slog fields
+//   - urn: The URN identifier of the BOM (format: urn:uuid:<uuid>)
+//   - version: The specific version to retrieve, or empty string for latest version
+//
+// Returns:
+//   - store.ObjectReader: The BOM document reader along with its length and entity tag,
+//     the caller must close it
+//   - error: Returns ErrNotFound if the URN or version doesn't exist or is soft
+//     deleted, or other errors from the store or JSON unmarshaling
+func (s Service) OpenBOMByUrn(ctx context.Context, urn, version string) (store.ObjectReader, error) {
+	obj, _, err := s.openBOMByUrn(ctx, urn, version)
+	return obj, err
+}
+
+// openBOMByUrn is OpenBOMByUrn returning also the version opened, which is
+// the selected one if `version` is empty.
+func (s Service) openBOMByUrn(ctx context.Context, urn, version string) (store.ObjectReader, string, error) {
 	ctx = log.ContextAttrs(ctx,
 		slog.String("urn", urn),
 		slog.String("version", version),
 	)
 
+	var obj store.ObjectReader
 	if strings.TrimSpace(version) == "" {
 		slog.DebugContext(ctx, "Version is empty, calling `store.GetObjectVersions()` to obtain the latest BOM version stored.")
 		versions, hasOriginal, err := s.store.GetObjectVersions(ctx, urn)
 		switch {
 		case errors.Is(err, store.ErrNotFound):
-			return nil, ErrNotFound
+			return store.ObjectReader{}, "", ErrNotFound
 
 		case err != nil:
-			return nil, err
+			return store.ObjectReader{}, "", err
 		}
-
-		version = fmt.Sprintf("%d", versions[len(versions)-1])
-		slog.DebugContext(ctx, "Latest version selected.", slog.Group("getObjectVersionsResult",
+		slog.DebugContext(ctx, "Versions found.", slog.Group("getObjectVersionsResult",
 			slog.Any("all-versions", versions),
-			slog.String("selected-version", version),
 			slog.Bool("has-original", hasOriginal),
-		),
-		)
+		))
+
+		// soft deleted versions are reported as not found, fall back to the
+		// newest version which is not
+		found := false
+		for i := len(versions) - 1; i >= 0 && !found; i-- {
+			version = strconv.Itoa(versions[i])
+			slog.DebugContext(ctx, "Calling `store.OpenObject()`.", slog.String("selected-version", version))
+			obj, err = s.store.OpenObject(ctx, fmt.Sprintf("%s-%s", urn, version))
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				continue
+
+			case err != nil:
+				return store.ObjectReader{}, "", err
+			}
+			found = true
+		}
+		if !found {
+			return store.ObjectReader{}, "", ErrNotFound
+		}
 		ctx = log.ContextAttrs(ctx, slog.String("selected-version", version))
+	} else {
+		slog.DebugContext(ctx, "Calling `store.OpenObject()`.")
+		var err error
+		obj, err = s.store.OpenObject(ctx, fmt.Sprintf("%s-%s", urn, version))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return store.ObjectReader{}, "", ErrNotFound
+
+		case err != nil:
+			return store.ObjectReader{}, "", err
+		}
 	}
+	slog.DebugContext(ctx, "`store.OpenObject()` finished.", slog.Int64("size", obj.ContentLength))
 
-	slog.DebugContext(ctx, "Calling `store.GetObject()`.")
-	b, err := s.store.GetObject(ctx, fmt.Sprintf("%s-%s", urn, version))
-	switch {
-	case errors.Is(err, store.ErrNotFound):
-		return nil, ErrNotFound
+	if !s.config.CheckOnFetch {
+		return obj, version, nil
+	}
 
-	case err != nil:
-		return nil, err
+	body := obj.ReadCloser
+	defer func() {
+		_ = body.Close()
+	}()
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		slog.ErrorContext(ctx, "`io.ReadAll()` failed.", slog.String("error", err.Error()))
+		return store.ObjectReader{}, "", err
 	}
-	slog.DebugContext(ctx, "`store.GetObject()` finished.", slog.Int64("size", int64(len(b))))
 
-	if s.config.CheckOnFetch {
+	switch storedMediaType(obj.ContentType) {
+	case MediaTypeXML:
+		_, err = decodeBOM(bytes.NewReader(b), cdx.BOMFileFormatXML)
+	case MediaTypeProtobuf:
+		_, err = decodeBOM(bytes.NewReader(b), bomFileFormatProtobuf)
+	default:
 		var bomMap map[string]interface{}
-		if err := json.Unmarshal(b, &bomMap); err != nil {
-			slog.ErrorContext(
-				ctx,
-				"`json.Unmarshal()` failed while checking the contents returned form the backend storage.", slog.String("error", err.Error()))
-			return nil, errors.New("BOM fetched from backend storage is malformed")
-		}
+		err = json.Unmarshal(b, &bomMap)
+	}
+	if err != nil {
+		slog.ErrorContext(
+			ctx,
+			"Decoding failed while checking the contents returned form the backend storage.", slog.String("error", err.Error()))
+		return store.ObjectReader{}, "", errors.New("BOM fetched from backend storage is malformed")
 	}
 
-	return b, nil
+	obj.ReadCloser = io.NopCloser(bytes.NewReader(b))
+	obj.ContentLength = int64(len(b))
+	return obj, version, nil
 }
 
 type VersionRes struct {
@@ -264,7 +437,8 @@ type VersionRes struct {
 // The returned slice includes all numbered versions (e.g., "1", "2", "3") and
 // may also include an "original" version if one exists in the store. Versions
 // that exist in the store but are missing required metadata (such as crypto
-// statistics) are logged as warnings and excluded from the results.
+// statistics) are logged as warnings and excluded from the results, soft
+// deleted versions are excluded as well.
 //
 // Parameters:
 //   - ctx: Context for cancellation, deadlines, and additional slog fields
@@ -272,8 +446,8 @@ type VersionRes struct {
 //
 // Returns:
 //   - []VersionRes: Slice of versions, some metadata and crypto statistics
-//   - error: Returns ErrNotFound if the URN doesn't exist, or other errors
-//     from the store or JSON unmarshaling
+//   - error: Returns ErrNotFound if the URN doesn't exist or all of its versions
+//     are soft deleted, or other errors from the store or JSON unmarshaling
 func (s Service) UrnVersions(ctx context.Context, urn string) ([]VersionRes, error) {
 	ctx = log.ContextAttrs(ctx,
 		slog.String("urn", urn),
@@ -288,16 +462,9 @@ func (s Service) UrnVersions(ctx context.Context, urn string) ([]VersionRes, err
 		return nil, err
 	}
 
-	var toProcess []string
-	for _, cpy := range versions {
-		toProcess = append(toProcess, strconv.Itoa(cpy))
-	}
-	if hasOriginal {
-		toProcess = append(toProcess, "original")
-	}
-
 	res := []VersionRes{}
-	for _, cpy := range toProcess {
+	deleted := 0
+	for _, cpy := range versionNames(versions, hasOriginal) {
 		key := fmt.Sprintf("%s-%s", urn, cpy)
 		head, err := s.store.GetHeadObject(ctx, key)
 		switch {
@@ -309,6 +476,10 @@ func (s Service) UrnVersions(ctx context.Context, urn string) ([]VersionRes, err
 		case err != nil:
 			return nil, err
 		}
+		if store.Deleted(head.Metadata) {
+			deleted++
+			continue
+		}
 
 		cryptoStats, ok := head.Metadata[store.MetaCryptoStatsKey]
 		if !ok {
@@ -328,6 +499,11 @@ func (s Service) UrnVersions(ctx context.Context, urn string) ([]VersionRes, err
 		}
 		res = append(res, item)
 	}
+
+	if len(res) == 0 && deleted > 0 {
+		// every version is soft deleted
+		return nil, ErrNotFound
+	}
 	return res, nil
 }
 
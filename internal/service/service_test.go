@@ -2,8 +2,10 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,8 +32,9 @@ func TestNewFunc(t *testing.T) {
 	)
 	require.NoError(t, err)
 	require.True(t, svc.VersionSupported("1.6"))
-	require.False(t, svc.VersionSupported("1.4"))
-	require.Equal(t, []string{"1.6"}, svc.SupportedVersion())
+	require.True(t, svc.VersionSupported("1.4"))
+	require.False(t, svc.VersionSupported("1.3"))
+	require.Equal(t, []string{"1.4", "1.5", "1.6"}, svc.SupportedVersion())
 }
 
 func TestSearch_Success(t *testing.T) {
@@ -74,11 +77,12 @@ func TestSearch_Success(t *testing.T) {
 	svc, err := service.New(st, service.Config{CheckOnFetch: false})
 	require.NoError(t, err)
 
-	res, err := svc.Search(context.Background(), now.Unix()-1)
+	res, err := svc.Search(context.Background(), service.SearchQuery{After: now.Unix() - 1})
 	require.NoError(t, err)
-	require.Len(t, res, 2)
-	require.Equal(t, "urn:uuid:1", res[0].SerialNumber)
-	require.Equal(t, "1", res[0].Version)
+	require.Len(t, res.Items, 2)
+	require.Empty(t, res.Next)
+	require.Equal(t, "urn:uuid:1", res.Items[0].SerialNumber)
+	require.Equal(t, "1", res.Items[0].Version)
 }
 
 func TestSearch_BadKey(t *testing.T) {
@@ -95,7 +99,7 @@ func TestSearch_BadKey(t *testing.T) {
 	svc, err := service.New(st, service.Config{CheckOnFetch: false})
 	require.NoError(t, err)
 
-	_, err = svc.Search(context.Background(), now.Unix()-1)
+	_, err = svc.Search(context.Background(), service.SearchQuery{After: now.Unix() - 1})
 	require.Error(t, err)
 }
 
@@ -202,5 +206,124 @@ func TestGetBOMByUrn_Success_CheckOnFetchTrue(t *testing.T) {
 	require.IsType(t, []byte{}, res)
 }
 
+func TestOpenBOMByUrn(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+	serial := "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
+	contents := `{"bomFormat":"CycloneDX","specVersion":"1.6"}`
+	require.NoError(t, backend.Upload(ctx, serial+"-1", store.Metadata{Version: "1"}, []byte(contents)))
+	require.NoError(t, backend.Upload(ctx, serial+"-2", store.Metadata{Version: "2"}, []byte("not json")))
+
+	for _, checkOnFetch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("checkOnFetch=%t", checkOnFetch), func(t *testing.T) {
+			svc, err := service.New(backend, service.Config{CheckOnFetch: checkOnFetch})
+			require.NoError(t, err)
+
+			obj, err := svc.OpenBOMByUrn(ctx, serial, "1")
+			require.NoError(t, err)
+			require.Equal(t, int64(len(contents)), obj.ContentLength)
+			require.NotEmpty(t, obj.ETag)
+			b, err := io.ReadAll(obj)
+			require.NoError(t, err)
+			require.NoError(t, obj.Close())
+			require.Equal(t, contents, string(b))
+
+			// malformed contents is detected only when checked on fetch
+			obj, err = svc.OpenBOMByUrn(ctx, serial, "")
+			if checkOnFetch {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, obj.Close())
+		})
+	}
+}
+
+func TestMemoryBackend_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{CheckOnFetch: true})
+	require.NoError(t, err)
+
+	// no serial number - a new one is generated and the original is kept
+	created, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(`{"bomFormat":"CycloneDX","specVersion":"1.6"}`)), "1.6")
+	require.NoError(t, err)
+	require.True(t, service.URNValid(created.SerialNumber))
+	require.Equal(t, 1, created.Version)
+
+	// serial number without version - next version is assigned
+	body := fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, created.SerialNumber)
+	next, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Equal(t, created.SerialNumber, next.SerialNumber)
+	require.Equal(t, 2, next.Version)
+
+	// serial number and version of an existing BOM
+	body = fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q,"version":2}`, created.SerialNumber)
+	_, err = svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorIs(t, err, service.ErrAlreadyExists)
+
+	versions, err := svc.UrnVersions(ctx, created.SerialNumber)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.Equal(t, "1", versions[0].Version)
+	require.Equal(t, "2", versions[1].Version)
+	require.Equal(t, "original", versions[2].Version)
+
+	res, err := svc.Search(ctx, service.SearchQuery{})
+	require.NoError(t, err)
+	require.Len(t, res.Items, 3)
+
+	b, err := svc.GetBOMByUrn(ctx, created.SerialNumber, "")
+	require.NoError(t, err)
+	require.Contains(t, string(b), created.SerialNumber)
+}
+
+func TestMemoryBackend_ConcurrentUploads(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.New(store.NewMemory(), service.Config{})
+	require.NoError(t, err)
+
+	serial := "urn:uuid:0d4e5c5e-9c4f-4d7b-8f0e-4b7e0b6f1a2c"
+	upload := func(body string) chan error {
+		errs := make(chan error, maxConcurrent)
+		var wg sync.WaitGroup
+		for range maxConcurrent {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		return errs
+	}
+
+	// serial number without version - every upload gets its own version
+	for err := range upload(fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q}`, serial)) {
+		require.NoError(t, err)
+	}
+	versions, err := svc.UrnVersions(ctx, serial)
+	require.NoError(t, err)
+	require.Len(t, versions, maxConcurrent)
+
+	// serial number and version - exactly one upload wins
+	succeeded := 0
+	for err := range upload(fmt.Sprintf(`{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":%q,"version":100}`, serial)) {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, service.ErrAlreadyExists)
+	}
+	require.Equal(t, 1, succeeded)
+}
+
+// maxConcurrent is the number of concurrent uploads, it must not exceed the
+// number of versions the service tries before giving up.
+const maxConcurrent = 8
+
 // helper to create *string for aws types
 func awsString(s string) *string { return &s }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// spoolPattern is the name pattern of temporary files holding spooled bodies.
+const spoolPattern = "cbom-upload-*"
+
+// UploadConfig controls how uploaded BOMs are buffered while being validated.
+type UploadConfig struct {
+	// SpoolThreshold is the size in bytes up to which an uploaded body is kept
+	// in memory, larger bodies are spooled to a temporary file. Zero keeps
+	// all bodies in memory.
+	SpoolThreshold int64 `envconfig:"APP_UPLOAD_SPOOL_THRESHOLD" default:"4194304"`
+	// SpoolDir is the directory of the temporary files, the default
+	// directory for temporary files is used if empty.
+	SpoolDir string `envconfig:"APP_UPLOAD_SPOOL_DIR"`
+}
+
+// spool is a write-once buffer, which keeps its contents in memory up to
+// the configured threshold and moves it into a temporary file once the
+// threshold is exceeded. The contents can be read any number of times. The
+// spool must be closed to remove the temporary file.
+type spool struct {
+	cfg  UploadConfig
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func newSpool(cfg UploadConfig) *spool {
+	return &spool{cfg: cfg}
+}
+
+// Write appends p to the spool, the contents are moved into a temporary file
+// as soon as they would exceed the threshold.
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.cfg.SpoolThreshold > 0 && int64(s.buf.Len()+len(p)) > s.cfg.SpoolThreshold {
+		f, err := os.CreateTemp(s.cfg.SpoolDir, spoolPattern)
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// Reader returns a new reader of the whole contents written so far.
+func (s *spool) Reader() io.Reader {
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, s.size)
+	}
+	return bytes.NewReader(s.buf.Bytes())
+}
+
+// Size returns the number of bytes written.
+func (s *spool) Size() int64 {
+	return s.size
+}
+
+// OnDisk reports whether the contents were moved into a temporary file.
+func (s *spool) OnDisk() bool {
+	return s.file != nil
+}
+
+// Close releases the memory buffer and removes the temporary file, if any.
+func (s *spool) Close() error {
+	s.buf = bytes.Buffer{}
+	if s.file == nil {
+		return nil
+	}
+
+	name := s.file.Name()
+	err := s.file.Close()
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	s.file = nil
+	return err
+}
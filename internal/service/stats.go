@@ -1,35 +1,167 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
+	"maps"
+	"slices"
 
 	cdx "github.com/CycloneDX/cyclonedx-go"
 )
 
 type CryptoStats struct {
 	CryptoAsset CryptoAssetStats `json:"cryptoAssets"`
+	// Quantum describes the quantum safety of the algorithms, certificates and
+	// protocols.
+	Quantum QuantumStats `json:"quantum"`
 }
 
 type CryptoAssetStats struct {
-	Total    int        `json:"total"`
-	Algo     TotalStats `json:"algorithms"`
-	Cert     TotalStats `json:"certificates"`
-	Protocol TotalStats `json:"protocols"`
-	Related  TotalStats `json:"relatedCryptoMaterials"`
+	Total    int            `json:"total"`
+	Algo     AlgorithmStats `json:"algorithms"`
+	Cert     TotalStats     `json:"certificates"`
+	Protocol ProtocolStats  `json:"protocols"`
+	Related  TotalStats     `json:"relatedCryptoMaterials"`
+	// Nested counts the crypto assets found in the components of other
+	// components, they are included in the totals.
+	Nested int `json:"nested"`
 }
 
 type TotalStats struct {
 	Total int `json:"total"`
 }
 
+// AlgorithmStats breaks the algorithms down by their properties, algorithms
+// missing a property are not counted in the respective breakdown.
+type AlgorithmStats struct {
+	Total int `json:"total"`
+	// ByPrimitive counts algorithms by `algorithmProperties.primitive`.
+	ByPrimitive Breakdown `json:"byPrimitive,omitempty"`
+	// ByName counts algorithms by the component name, CycloneDX 1.6 has no
+	// algorithm family, the name (e.g. AES-128-GCM) stands in for it.
+	ByName Breakdown `json:"byName,omitempty"`
+	// ByParameterSet counts algorithms by `algorithmProperties.parameterSetIdentifier`,
+	// which is the key size for most algorithms.
+	ByParameterSet Breakdown `json:"byParameterSet,omitempty"`
+}
+
+// ProtocolStats breaks the protocols down by their properties, protocols
+// missing a property are not counted in the respective breakdown.
+type ProtocolStats struct {
+	Total int `json:"total"`
+	// ByType counts protocols by `protocolProperties.type`.
+	ByType Breakdown `json:"byType,omitempty"`
+	// ByVersion counts protocols by `protocolProperties.type` and `protocolProperties.version`,
+	// the key is both separated by a space, e.g. "tls 1.3".
+	ByVersion Breakdown `json:"byVersion,omitempty"`
+}
+
+// add counts a crypto asset, `algorithms` are the quantum safety classes of
+// the algorithms of the BOM by their bom-ref, see classifyCertificate and
+// classifyProtocol.
+func (cryptoStats *CryptoStats) add(component cdx.Component, algorithms map[cdx.BOMReference]string) {
+	cryptoStats.CryptoAsset.Total += 1
+
+	switch component.CryptoProperties.AssetType {
+	case cdx.CryptoAssetTypeAlgorithm:
+		algo := &cryptoStats.CryptoAsset.Algo
+		algo.Total += 1
+		algo.ByName.add(component.Name)
+		if props := component.CryptoProperties.AlgorithmProperties; props != nil {
+			algo.ByPrimitive.add(string(props.Primitive))
+			algo.ByParameterSet.add(props.ParameterSetIdentifier)
+		}
+		cryptoStats.Quantum.addAlgorithm(component)
+
+	case cdx.CryptoAssetTypeCertificate:
+		cryptoStats.CryptoAsset.Cert.Total += 1
+		cryptoStats.Quantum.count(classifyCertificate(component.CryptoProperties.CertificateProperties, algorithms))
+
+	case cdx.CryptoAssetTypeProtocol:
+		protocol := &cryptoStats.CryptoAsset.Protocol
+		protocol.Total += 1
+		if props := component.CryptoProperties.ProtocolProperties; props != nil && props.Type != "" {
+			protocol.ByType.add(string(props.Type))
+			if props.Version != "" {
+				protocol.ByVersion.add(string(props.Type) + " " + props.Version)
+			}
+		}
+		cryptoStats.Quantum.count(classifyProtocol(component.CryptoProperties.ProtocolProperties, algorithms))
+
+	case cdx.CryptoAssetTypeRelatedCryptoMaterial:
+		cryptoStats.CryptoAsset.Related.Total += 1
+	}
+}
+
+// BreakdownOther is the key counting the entries left out of a breakdown.
+const BreakdownOther = "other"
+
+// maxBreakdownEntries caps the number of keys of a breakdown, the statistics
+// are kept in the object metadata, which is limited to 2 KiB by S3. The
+// breakdowns are folded further if the metadata do not fit, see fitCryptoStats.
+const maxBreakdownEntries = 8
+
+// maxBreakdownKeyLength caps the length of the keys of a breakdown in runes,
+// longer values are truncated.
+const maxBreakdownKeyLength = 32
+
+// Breakdown counts crypto assets by the value of a property. Only the most
+// frequent values are kept, the others are counted under BreakdownOther.
+type Breakdown map[string]int
+
+// add counts a crypto asset with the value `key`, empty values are skipped.
+// Keys are truncated to maxBreakdownKeyLength runes, values sharing the
+// truncated prefix are counted together.
+func (b *Breakdown) add(key string) {
+	if key == "" {
+		return
+	}
+	if runes := []rune(key); len(runes) > maxBreakdownKeyLength {
+		key = string(runes[:maxBreakdownKeyLength])
+	}
+	if *b == nil {
+		*b = make(Breakdown)
+	}
+	(*b)[key]++
+}
+
+// fold keeps the `limit` most frequent values and counts the others under
+// BreakdownOther. Ties are broken by the value, so that the result does not
+// depend on the order of the components.
+func (b Breakdown) fold(limit int) {
+	if len(b) <= limit {
+		return
+	}
+	keys := slices.Collect(maps.Keys(b))
+	slices.SortFunc(keys, func(x, y string) int {
+		if c := cmp.Compare(b[y], b[x]); c != 0 {
+			return c
+		}
+		return cmp.Compare(x, y)
+	})
+	other := 0
+	for _, k := range keys[limit-1:] {
+		other += b[k]
+		delete(b, k)
+	}
+	b[BreakdownOther] += other
+}
+
 // CalculateCryptoStats analyzes a CycloneDX BOM and returns statistics about
-// cryptographic assets contained within it. The function iterates through all
-// components in the BOM and counts cryptographic assets by their type
-// (algorithm, certificate, protocol, or related crypto material).
+// cryptographic assets contained within it. The function walks the whole
+// component tree of the BOM, including components nested in the `components`
+// of other components, and counts cryptographic assets by their type
+// (algorithm, certificate, protocol, or related crypto material). Algorithms
+// are further broken down by primitive, name and parameter set, protocols by
+// type and version. Algorithms, certificates and protocols are classified by
+// their quantum safety, see QuantumStats.
 //
-// Components that are not of type ComponentTypeCryptographicAsset are skipped.
-// Components missing CryptoProperties are logged as warnings and skipped.
+// Components that are not of type ComponentTypeCryptographicAsset are skipped,
+// but their nested components are walked. Components missing CryptoProperties
+// are logged as warnings and skipped. A bom-ref seen before is counted once
+// only, at the shallowest depth it appears at, components without a bom-ref
+// are always counted.
 //
 // Parameters:
 //   - ctx: Context for cancellation and logging
@@ -45,30 +177,67 @@ func CalculateCryptoStats(ctx context.Context, bom *cdx.BOM) CryptoStats {
 		return cryptoStats
 	}
 
-	for _, component := range *bom.Components {
-		if component.Type != cdx.ComponentTypeCryptographicAsset {
-			continue
-		}
+	// the tree is walked level by level, so that an asset referenced at
+	// several depths is counted at the shallowest one
+	seen := make(map[string]struct{})
+	var assets []cdx.Component
+	level := *bom.Components
+	for depth := 0; len(level) > 0; depth++ {
+		var next []cdx.Component
+		for _, component := range level {
+			if component.Components != nil {
+				next = append(next, *component.Components...)
+			}
 
-		if component.CryptoProperties == nil {
-			slog.WarnContext(ctx, "Component is a crypto asset but has a nil CryptoProperties field. Skipping.", slog.String("bom-ref", component.BOMRef))
-			continue
+			if component.Type != cdx.ComponentTypeCryptographicAsset {
+				continue
+			}
+			if component.CryptoProperties == nil {
+				slog.WarnContext(ctx, "Component is a crypto asset but has a nil CryptoProperties field. Skipping.", slog.String("bom-ref", component.BOMRef))
+				continue
+			}
+			if component.BOMRef != "" {
+				if _, ok := seen[component.BOMRef]; ok {
+					slog.DebugContext(ctx, "Crypto asset counted already. Skipping.", slog.String("bom-ref", component.BOMRef))
+					continue
+				}
+				seen[component.BOMRef] = struct{}{}
+			}
+			if depth > 0 {
+				cryptoStats.CryptoAsset.Nested += 1
+			}
+			assets = append(assets, component)
 		}
-		cryptoStats.CryptoAsset.Total += 1
-
-		switch component.CryptoProperties.AssetType {
-		case cdx.CryptoAssetTypeAlgorithm:
-			cryptoStats.CryptoAsset.Algo.Total += 1
-
-		case cdx.CryptoAssetTypeCertificate:
-			cryptoStats.CryptoAsset.Cert.Total += 1
-
-		case cdx.CryptoAssetTypeProtocol:
-			cryptoStats.CryptoAsset.Protocol.Total += 1
+		level = next
+	}
 
-		case cdx.CryptoAssetTypeRelatedCryptoMaterial:
-			cryptoStats.CryptoAsset.Related.Total += 1
+	// certificates and protocols are classified by the algorithms they
+	// reference, which may be anywhere in the tree
+	algorithms := make(map[cdx.BOMReference]string)
+	for _, component := range assets {
+		if component.BOMRef != "" && component.CryptoProperties.AssetType == cdx.CryptoAssetTypeAlgorithm {
+			algorithms[cdx.BOMReference(component.BOMRef)] = classifyQuantum(component.Name, component.CryptoProperties.AlgorithmProperties)
 		}
 	}
+	for _, component := range assets {
+		cryptoStats.add(component, algorithms)
+	}
+
+	for _, b := range cryptoStats.breakdowns() {
+		b.fold(maxBreakdownEntries)
+	}
+	cryptoStats.Quantum.score()
 	return cryptoStats
 }
+
+// breakdowns returns all the breakdowns of the statistics.
+func (cryptoStats *CryptoStats) breakdowns() []*Breakdown {
+	return []*Breakdown{
+		&cryptoStats.CryptoAsset.Algo.ByPrimitive,
+		&cryptoStats.CryptoAsset.Algo.ByName,
+		&cryptoStats.CryptoAsset.Algo.ByParameterSet,
+		&cryptoStats.CryptoAsset.Protocol.ByType,
+		&cryptoStats.CryptoAsset.Protocol.ByVersion,
+		&cryptoStats.Quantum.ByNISTLevel,
+	}
+}
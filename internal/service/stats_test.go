@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -136,6 +137,55 @@ func TestStats1(t *testing.T) {
 	require.Equal(t, 1, bomStats.CryptoAsset.Cert.Total)
 	require.Equal(t, 1, bomStats.CryptoAsset.Protocol.Total)
 	require.Equal(t, 1, bomStats.CryptoAsset.Related.Total)
+
+	require.Equal(t, service.Breakdown{"ae": 1}, bomStats.CryptoAsset.Algo.ByPrimitive)
+	require.Equal(t, service.Breakdown{"RSA-2048": 1, "AES-128-GCM": 1}, bomStats.CryptoAsset.Algo.ByName)
+	require.Equal(t, service.Breakdown{"2048": 1, "128": 1}, bomStats.CryptoAsset.Algo.ByParameterSet)
+	require.Equal(t, service.Breakdown{"tls": 1}, bomStats.CryptoAsset.Protocol.ByType)
+	require.Equal(t, service.Breakdown{"tls v1": 1}, bomStats.CryptoAsset.Protocol.ByVersion)
+
+	// AES-128-GCM declares NIST level 1, RSA-2048 is classified by its name,
+	// the signature algorithm of the certificate is not in the BOM and the
+	// protocol lists no cipher suites
+	require.Equal(t, service.Breakdown{"1": 1}, bomStats.Quantum.ByNISTLevel)
+	require.Equal(t, 1, bomStats.Quantum.Safe)
+	require.Equal(t, 1, bomStats.Quantum.Vulnerable)
+	require.Equal(t, 2, bomStats.Quantum.Unknown)
+	require.NotNil(t, bomStats.Quantum.ReadinessScore)
+	require.Equal(t, 50, *bomStats.Quantum.ReadinessScore)
+}
+
+func TestStatsBreakdownCapped(t *testing.T) {
+	var components []cdx.Component
+	for i := range 12 {
+		// algorithm-0 is the most frequent one, the rest are ordered by name
+		for range max(1, 3-i) {
+			components = append(components, cdx.Component{
+				Type: cdx.ComponentTypeCryptographicAsset,
+				Name: fmt.Sprintf("algorithm-%02d", i),
+				CryptoProperties: &cdx.CryptoProperties{
+					AssetType:           cdx.CryptoAssetTypeAlgorithm,
+					AlgorithmProperties: &cdx.CryptoAlgorithmProperties{Primitive: cdx.CryptoPrimitiveHash},
+				},
+			})
+		}
+	}
+	bom := cdx.BOM{Components: &components}
+
+	bomStats := service.CalculateCryptoStats(context.Background(), &bom)
+
+	require.Equal(t, 15, bomStats.CryptoAsset.Algo.Total)
+	require.Equal(t, service.Breakdown{"hash": 15}, bomStats.CryptoAsset.Algo.ByPrimitive)
+	require.Equal(t, service.Breakdown{
+		"algorithm-00":         3,
+		"algorithm-01":         2,
+		"algorithm-02":         1,
+		"algorithm-03":         1,
+		"algorithm-04":         1,
+		"algorithm-05":         1,
+		"algorithm-06":         1,
+		service.BreakdownOther: 5,
+	}, bomStats.CryptoAsset.Algo.ByName)
 }
 
 func TestStatsComponentsNil(t *testing.T) {
@@ -165,5 +215,138 @@ func TestStatsComponentsNil(t *testing.T) {
 	require.Equal(t, 0, bomStats.CryptoAsset.Cert.Total)
 	require.Equal(t, 0, bomStats.CryptoAsset.Protocol.Total)
 	require.Equal(t, 0, bomStats.CryptoAsset.Related.Total)
+	require.Nil(t, bomStats.Quantum.ReadinessScore)
+}
+
+func TestStatsNestedComponents(t *testing.T) {
+	jsonBom := `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.6",
+  "version": 1,
+  "components": [
+    {
+      "type": "library",
+      "name": "openssl",
+      "components": [
+        {
+          "type": "cryptographic-asset",
+          "name": "AES-128-GCM",
+          "bom-ref": "crypto/algorithm/aes-128-gcm",
+          "cryptoProperties": {"assetType": "algorithm"}
+        },
+        {
+          "type": "library",
+          "name": "libcrypto",
+          "components": [
+            {
+              "type": "cryptographic-asset",
+              "name": "TLS",
+              "cryptoProperties": {"assetType": "protocol", "protocolProperties": {"type": "tls", "version": "1.3"}}
+            },
+            {
+              "type": "cryptographic-asset",
+              "name": "TLS",
+              "cryptoProperties": {"assetType": "protocol", "protocolProperties": {"type": "tls", "version": "1.2"}}
+            }
+          ]
+        }
+      ]
+    },
+    {
+      "type": "cryptographic-asset",
+      "name": "AES-128-GCM",
+      "bom-ref": "crypto/algorithm/aes-128-gcm",
+      "cryptoProperties": {"assetType": "algorithm"}
+    },
+    {
+      "type": "cryptographic-asset",
+      "name": "google.com",
+      "bom-ref": "crypto/certificate/google.com",
+      "cryptoProperties": {"assetType": "certificate"},
+      "components": [
+        {
+          "type": "cryptographic-asset",
+          "name": "google.com",
+          "bom-ref": "crypto/certificate/google.com",
+          "cryptoProperties": {"assetType": "certificate"}
+        }
+      ]
+    }
+  ]
+}`
+	var bom cdx.BOM
+	decoder := cdx.NewBOMDecoder(strings.NewReader(jsonBom), cdx.BOMFileFormatJSON)
+	require.NoError(t, decoder.Decode(&bom))
+
+	bomStats := service.CalculateCryptoStats(context.Background(), &bom)
+
+	// the algorithm is counted at the root level, the protocols lack a bom-ref
+	require.Equal(t, 4, bomStats.CryptoAsset.Total)
+	require.Equal(t, 2, bomStats.CryptoAsset.Nested)
+	require.Equal(t, 1, bomStats.CryptoAsset.Algo.Total)
+	require.Equal(t, 1, bomStats.CryptoAsset.Cert.Total)
+	require.Equal(t, 2, bomStats.CryptoAsset.Protocol.Total)
+	require.Equal(t, service.Breakdown{"tls 1.3": 1, "tls 1.2": 1}, bomStats.CryptoAsset.Protocol.ByVersion)
+}
+
+func TestStatsQuantumReferences(t *testing.T) {
+	jsonBom := `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.6",
+  "version": 1,
+  "components": [
+    {
+      "type": "cryptographic-asset",
+      "name": "www.example.com",
+      "bom-ref": "crypto/certificate/example",
+      "cryptoProperties": {
+        "assetType": "certificate",
+        "certificateProperties": {"signatureAlgorithmRef": "crypto/algorithm/sha256-rsa"}
+      }
+    },
+    {
+      "type": "cryptographic-asset",
+      "name": "TLS",
+      "bom-ref": "crypto/protocol/tls",
+      "cryptoProperties": {
+        "assetType": "protocol",
+        "protocolProperties": {
+          "type": "tls",
+          "version": "1.3",
+          "cipherSuites": [{"name": "TLS_AES_256_GCM_SHA384", "algorithms": ["crypto/algorithm/mlkem"]}]
+        }
+      }
+    },
+    {
+      "type": "library",
+      "name": "crypto",
+      "components": [
+        {
+          "type": "cryptographic-asset",
+          "name": "sha256WithRSAEncryption",
+          "bom-ref": "crypto/algorithm/sha256-rsa",
+          "cryptoProperties": {"assetType": "algorithm"}
+        },
+        {
+          "type": "cryptographic-asset",
+          "name": "ML-KEM-768",
+          "bom-ref": "crypto/algorithm/mlkem",
+          "cryptoProperties": {"assetType": "algorithm"}
+        }
+      ]
+    }
+  ]
+}`
+	var bom cdx.BOM
+	decoder := cdx.NewBOMDecoder(strings.NewReader(jsonBom), cdx.BOMFileFormatJSON)
+	require.NoError(t, decoder.Decode(&bom))
+
+	bomStats := service.CalculateCryptoStats(context.Background(), &bom)
 
+	// the certificate and the protocol resolve the nested algorithms
+	require.Equal(t, 2, bomStats.Quantum.Safe)
+	require.Equal(t, 2, bomStats.Quantum.Vulnerable)
+	require.Equal(t, 0, bomStats.Quantum.Unknown)
+	require.NotNil(t, bomStats.Quantum.ReadinessScore)
+	require.Equal(t, 50, *bomStats.Quantum.ReadinessScore)
 }
@@ -1,7 +1,6 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -16,10 +15,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// maxVersionAttempts limits how many consecutive versions are tried when the
+// assigned version gets taken by concurrent uploads of the same serial number.
+const maxVersionAttempts = 10
+
 type BOMCreated struct {
 	SerialNumber string      `json:"serialNumber"`
 	Version      int         `json:"version"`
 	CryptoStats  CryptoStats `json:"cryptoStats"`
+	// Warnings lists the violations of semantic rules in the warn mode.
+	Warnings []SemanticIssue `json:"warnings,omitempty"`
+	// Findings lists the violations of policies in the warn mode.
+	Findings []PolicyFinding `json:"findings,omitempty"`
 }
 
 // UploadBOM processes and stores a CycloneDX BOM (Bill of Materials) document.
@@ -31,18 +38,27 @@ type BOMCreated struct {
 //  2. Valid serial number with invalid version (< 1): If a BOM with this serial number already
 //     exists, fetches existing versions for the serial number and assigns the next sequential
 //     version number, otherwise assigns version 1. Stores the modified BOM with the updated
-//     version field.
+//     version field. If the version gets taken by a concurrent upload in the meantime,
+//     the following version is tried.
 //
 //  3. Valid serial number and version: Verifies the BOM doesn't already exist and stores
 //     it as-is. Returns ErrAlreadyExists if a BOM with the same serial number and version
-//     already exists.
+//     already exists, including when it was stored by a concurrent upload.
+//
+// Objects are never overwritten, the store rejects an upload of an existing key.
 //
 // Cryptographic asset statistics are calculated for all uploaded BOMs and stored
-// as metadata alongside the BOM document.
+// as metadata alongside the BOM document, so are the findings of the policies
+// in the warn and record modes.
+//
+// The body is spooled first, bodies larger than the configured threshold go
+// to a temporary file instead of memory. The spooled body is read again for
+// the schema validation and for storing the BOM as-is, modified BOMs are
+// encoded straight into the store upload.
 //
 // Parameters:
 //   - ctx: Context for cancellation, deadlines and additional slog fields.
-//   - rc: Reader containing the BOM document (will be closed by this function)
+//   - rc: Reader containing the BOM document in JSON (will be closed by this function)
 //   - schemaVersion: Expected CycloneDX schema version (e.g., "1.6")
 //
 // Returns:
@@ -50,40 +66,54 @@ type BOMCreated struct {
 //   - error: ErrValidation if validation fails, ErrAlreadyExists if the BOM already exists,
 //     or other errors from decoding, encoding, or storage operations
 func (s Service) UploadBOM(ctx context.Context, rc io.ReadCloser, schemaVersion string) (BOMCreated, error) {
+	return s.UploadBOMOfType(ctx, rc, MediaTypeJSON, schemaVersion)
+}
 
-	var buf bytes.Buffer
-	tee := io.TeeReader(rc, &buf)
+// UploadBOMOfType is UploadBOM of a BOM document serialized as `mediaType`,
+// one of MediaTypeJSON, MediaTypeXML and MediaTypeProtobuf. The media type is
+// kept in the metadata of the stored objects, modified BOMs are encoded in the
+// same media type.
+//
+// JSON documents are validated against the JSON schema of the declared
+// version, XML documents against the XML schema of the declared version as
+// uploaded, before they are decoded. So the elements and attributes unknown to
+// cyclonedx-go are reported instead of being dropped by decoding. Protobuf
+// documents are decoded first, fields the protobuf codec doesn't cover fail
+// the decoding, and the decoded BOM is validated against the JSON schema.
+func (s Service) UploadBOMOfType(ctx context.Context, rc io.ReadCloser, mediaType, schemaVersion string) (BOMCreated, error) {
 	defer func() {
 		_ = rc.Close()
 	}()
 
-	ctx = log.ContextAttrs(ctx, slog.String("declared-bom-schema-version", schemaVersion))
-
-	var bom cdx.BOM
-	decoder := cdx.NewBOMDecoder(tee, cdx.BOMFileFormatJSON)
-	if err := decoder.Decode(&bom); err != nil {
-		slog.ErrorContext(ctx, "`cdx.Decode()` failed.", slog.String("error", err.Error()))
-		return BOMCreated{}, err
-	}
+	ctx = log.ContextAttrs(ctx,
+		slog.String("declared-bom-schema-version", schemaVersion),
+		slog.String("media-type", mediaType),
+	)
 
-	if err := uploadInputChecks(bom, schemaVersion); err != nil {
-		return BOMCreated{}, fmt.Errorf("%w: %s", ErrValidation, err)
+	format, ok := bomFileFormat(mediaType)
+	if !ok {
+		// this shouldn't happen, if http handler correctly checks the content type
+		return BOMCreated{}, fmt.Errorf("unsupported media type %s", mediaType)
 	}
 
-	jsonSchema, ok := s.jsonSchemas[schemaVersion]
-	if !ok {
-		// this shouldn't happen, if http handler correctly checks against `VersionSupported()`
-		slog.ErrorContext(ctx, "Missing schema validator!!!", slog.String("version", schemaVersion))
-		return BOMCreated{}, fmt.Errorf("schema validator missing for version %s", schemaVersion)
+	body, err := s.spoolBOM(ctx, rc)
+	if err != nil {
+		return BOMCreated{}, err
 	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			slog.WarnContext(ctx, "Removing spooled BOM failed.", slog.String("error", err.Error()))
+		}
+	}()
 
-	res := jsonSchema.Validate(buf.Bytes())
-	if !res.IsValid() {
-		return BOMCreated{}, fmt.Errorf("%w: does not conform to the declared schema", ErrValidation)
+	bom, warnings, findings, err := s.checkBOM(ctx, body, format, schemaVersion)
+	if err != nil {
+		return BOMCreated{}, err
 	}
 
 	cryptoStats := CalculateCryptoStats(ctx, &bom)
-	b, err := json.Marshal(cryptoStats)
+	// metadata shared by all objects stored, the version is set by the cases
+	meta, err := storedMetadata(cryptoStats, findings, mediaType)
 	if err != nil {
 		return BOMCreated{}, fmt.Errorf("`json.Marshal()` failed: %w", err)
 	}
@@ -92,27 +122,138 @@ func (s Service) UploadBOM(ctx context.Context, rc io.ReadCloser, schemaVersion
 	var retErr error
 	switch {
 	case bom.SerialNumber == "":
-		retVal, retErr = s.uploadCaseSNInvalid(ctx, bom, buf, string(b))
+		retVal, retErr = s.uploadCaseSNInvalid(ctx, bom, body, meta)
 
 	case bom.Version < 1:
-		retVal, retErr = s.uploadCaseSNValidVersionInvalid(ctx, bom, string(b))
+		retVal, retErr = s.uploadCaseSNValidVersionInvalid(ctx, bom, meta)
 
 	default:
 		// serial number of the BOM is valid, version is set
-		retVal, retErr = s.uploadCaseSNValidVersionValid(ctx, bom, buf, string(b))
+		retVal, retErr = s.uploadCaseSNValidVersionValid(ctx, bom, body, meta)
 	}
 	if retErr == nil {
 		retVal.CryptoStats = cryptoStats
+		retVal.Warnings = capIssues(warnings, s.config.MaxValidationErrors)
+		retVal.Findings = warnFindings(findings, s.config.MaxValidationErrors)
 	}
 	return retVal, retErr
 }
 
-func (s Service) uploadCaseSNInvalid(ctx context.Context, bom cdx.BOM, orig bytes.Buffer, cryptoStats string) (BOMCreated, error) {
+// spoolBOM spools the uploaded body, the caller must close the returned
+// spool.
+func (s Service) spoolBOM(ctx context.Context, rc io.Reader) (*spool, error) {
+	body := newSpool(s.config.Upload)
+	if _, err := io.Copy(body, rc); err != nil {
+		slog.ErrorContext(ctx, "Spooling BOM failed.", slog.String("error", err.Error()))
+		if err := body.Close(); err != nil {
+			slog.WarnContext(ctx, "Removing spooled BOM failed.", slog.String("error", err.Error()))
+		}
+		return nil, err
+	}
+	slog.DebugContext(ctx, "BOM spooled.", slog.Int64("size", body.Size()), slog.Bool("on-disk", body.OnDisk()))
+	return body, nil
+}
+
+// checkBOM decodes the spooled BOM and runs the validation stages: the input
+// checks, the schema validation, the semantic rules and the policies. It
+// returns the decoded BOM, the violations of the semantic rules in the warn
+// mode and the findings of the policies in the warn and record modes.
+func (s Service) checkBOM(ctx context.Context, body *spool, format cdx.BOMFileFormat, schemaVersion string) (cdx.BOM, []SemanticIssue, []PolicyFinding, error) {
+	bom, err := decodeBOM(body.Reader(), format)
+	if err != nil {
+		slog.ErrorContext(ctx, "`cdx.Decode()` failed.", slog.String("error", err.Error()))
+		if format == bomFileFormatProtobuf {
+			// the decoder rejects the fields it doesn't cover
+			return cdx.BOM{}, nil, nil, fmt.Errorf("%w: %s", ErrValidation, err)
+		}
+		return cdx.BOM{}, nil, nil, err
+	}
+
+	if err := uploadInputChecks(bom, schemaVersion); err != nil {
+		return cdx.BOM{}, nil, nil, fmt.Errorf("%w: %s", ErrValidation, err)
+	}
+
+	jsonSchema, ok := s.jsonSchemas[schemaVersion]
+	xmlSchema, xmlOK := s.xmlSchemas[schemaVersion]
+	if !ok || !xmlOK {
+		// this shouldn't happen, if http handler correctly checks against `VersionSupported()`
+		slog.ErrorContext(ctx, "Missing schema validator!!!", slog.String("version", schemaVersion))
+		return cdx.BOM{}, nil, nil, fmt.Errorf("schema validator missing for version %s", schemaVersion)
+	}
+
+	switch format {
+	case cdx.BOMFileFormatXML:
+		// XML documents are validated as uploaded against the XML schema
+		schemaErr, err := xmlSchema.validate(body.Reader(), s.config.MaxValidationErrors)
+		if err != nil {
+			return cdx.BOM{}, nil, nil, fmt.Errorf("`xml.Decode()` failed: %w", err)
+		}
+		if schemaErr != nil {
+			return cdx.BOM{}, nil, nil, schemaErr
+		}
+
+	case bomFileFormatProtobuf:
+		// protobuf documents have no schema of their own, the decoded BOM is
+		// validated against the JSON schema
+		doc, err := jsonDocument(&bom)
+		if err != nil {
+			return cdx.BOM{}, nil, nil, fmt.Errorf("`json.Decode()` failed: %w", err)
+		}
+		res := jsonSchema.Validate(doc)
+		if !res.IsValid() {
+			return cdx.BOM{}, nil, nil, newSchemaError(res, s.config.MaxValidationErrors)
+		}
+
+	default:
+		// the document is decoded once more for the validator, which would
+		// otherwise need the raw bytes in memory
+		var doc any
+		if err := json.NewDecoder(body.Reader()).Decode(&doc); err != nil {
+			return cdx.BOM{}, nil, nil, fmt.Errorf("`json.Decode()` failed: %w", err)
+		}
+		res := jsonSchema.Validate(doc)
+		if !res.IsValid() {
+			return cdx.BOM{}, nil, nil, newSchemaError(res, s.config.MaxValidationErrors)
+		}
+	}
+
+	rejected, warnings := checkSemantics(&bom, s.config.Semantic)
+	for _, issue := range warnings {
+		slog.WarnContext(ctx, "Semantic validation rule violated.",
+			slog.String("rule", issue.Rule), slog.String("pointer", issue.Pointer), slog.String("message", issue.Message))
+	}
+	if len(rejected) > 0 {
+		return cdx.BOM{}, nil, nil, &SemanticError{
+			Issues: capIssues(rejected, s.config.MaxValidationErrors),
+			Total:  len(rejected),
+		}
+	}
+
+	rejectedFindings, findings := splitFindings(evaluatePolicies(&bom, s.policies))
+	for _, finding := range findings {
+		slog.WarnContext(ctx, "Policy violated.",
+			slog.String("policy", finding.Policy), slog.String("mode", string(finding.Mode)),
+			slog.String("pointer", finding.Pointer), slog.String("message", finding.Message))
+	}
+	if len(rejectedFindings) > 0 {
+		return cdx.BOM{}, nil, nil, &PolicyError{
+			Findings: capFindings(rejectedFindings, s.config.MaxValidationErrors),
+			Total:    len(rejectedFindings),
+		}
+	}
+
+	return bom, warnings, findings, nil
+}
+
+func (s Service) uploadCaseSNInvalid(ctx context.Context, bom cdx.BOM, orig *spool, meta store.Metadata) (BOMCreated, error) {
 	slog.DebugContext(ctx, "BOM does not have serial number specified - generating a new one.")
 	// serial number is missing, so we're going to generate a unique new one,
 	// that means this will be version 1, even if something else was set
 	bom.Version = 1
 
+	metaOriginal := meta
+	metaOriginal.Version = "original"
+
 	for {
 		// generate a new urn and make sure we don't conflict with an existing one
 		bom.SerialNumber = fmt.Sprintf("urn:uuid:%s", uuid.NewString())
@@ -120,37 +261,27 @@ func (s Service) uploadCaseSNInvalid(ctx context.Context, bom cdx.BOM, orig byte
 		if err != nil {
 			return BOMCreated{}, err
 		}
-		if !exists {
-			break
+		if exists {
+			continue
 		}
-	}
-	ctx = log.ContextAttrs(ctx, slog.String("new-serial-number", bom.SerialNumber))
-	slog.DebugContext(ctx, "New serial number generated.")
 
-	// store the original unchanged BOM
-	metaOriginal := store.Metadata{
-		Version:     "original",
-		CryptoStats: cryptoStats,
-	}
-	if err := s.store.Upload(ctx, uploadKeyOriginal(bom.SerialNumber), metaOriginal, orig.Bytes()); err != nil {
-		return BOMCreated{}, err
+		// store the original unchanged BOM, the upload claims the new serial number
+		err = s.store.UploadStream(ctx, uploadKeyOriginal(bom.SerialNumber), metaOriginal, orig.Reader())
+		if errors.Is(err, store.ErrAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return BOMCreated{}, err
+		}
+		break
 	}
-	slog.DebugContext(ctx, "Stored original BOM.")
+	ctx = log.ContextAttrs(ctx, slog.String("new-serial-number", bom.SerialNumber))
+	slog.DebugContext(ctx, "New serial number generated, stored original BOM.")
 
 	// store the modified BOM with serialNumber and version set
-	meta := store.Metadata{
-		Version:     fmt.Sprintf("%d", bom.Version),
-		CryptoStats: cryptoStats,
-	}
+	meta.Version = fmt.Sprintf("%d", bom.Version)
 
-	var modifiedBuf bytes.Buffer
-	encoder := cdx.NewBOMEncoder(&modifiedBuf, cdx.BOMFileFormatJSON)
-	if err := encoder.Encode(&bom); err != nil {
-		slog.ErrorContext(ctx, "`cdx.Encode()` failed.", slog.String("error", err.Error()))
-		return BOMCreated{}, err
-	}
-
-	if err := s.store.Upload(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, modifiedBuf.Bytes()); err != nil {
+	if err := s.uploadEncoded(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, &bom); err != nil {
 		return BOMCreated{}, err
 	}
 	slog.DebugContext(ctx, "Stored modified version.")
@@ -161,46 +292,74 @@ func (s Service) uploadCaseSNInvalid(ctx context.Context, bom cdx.BOM, orig byte
 	}, nil
 }
 
-func (s Service) uploadCaseSNValidVersionInvalid(ctx context.Context, bom cdx.BOM, cryptoStats string) (BOMCreated, error) {
+func (s Service) uploadCaseSNValidVersionInvalid(ctx context.Context, bom cdx.BOM, meta store.Metadata) (BOMCreated, error) {
 	slog.DebugContext(ctx, "BOM has only serial number specified - fetching the latest version")
-	versions, hasOriginal, err := s.store.GetObjectVersions(ctx, bom.SerialNumber)
-	switch {
-	case errors.Is(err, store.ErrNotFound):
-		bom.Version = 1
-		slog.DebugContext(ctx, "First BOM with this SN, assigning Version '1'.")
-	case err != nil:
+	var err error
+	if bom.Version, err = s.nextVersion(ctx, bom.SerialNumber); err != nil {
 		return BOMCreated{}, err
-	default:
-		bom.Version = versions[len(versions)-1] + 1
-		slog.DebugContext(ctx, "New version assigned to BOM.",
-			slog.Int("new-version", bom.Version),
-			slog.Any("all-versions", versions),
-			slog.Bool("has-original", hasOriginal),
-		)
 	}
 
-	meta := store.Metadata{
-		Version:     fmt.Sprintf("%d", bom.Version),
-		CryptoStats: cryptoStats,
-	}
+	for attempt := 1; ; attempt++ {
+		meta.Version = fmt.Sprintf("%d", bom.Version)
+
+		err = s.uploadEncoded(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, &bom)
+		switch {
+		case errors.Is(err, store.ErrAlreadyExists) && attempt < maxVersionAttempts:
+			slog.DebugContext(ctx, "Version taken by a concurrent upload, trying the next one.",
+				slog.Int("taken-version", bom.Version),
+				slog.Int("attempt", attempt),
+			)
+			bom.Version++
+			continue
+
+		case errors.Is(err, store.ErrAlreadyExists):
+			slog.WarnContext(ctx, "No free version found, giving up.",
+				slog.Int("last-version", bom.Version),
+				slog.Int("attempts", attempt),
+			)
+			return BOMCreated{
+				SerialNumber: bom.SerialNumber,
+				Version:      bom.Version,
+			}, fmt.Errorf("%w: no free version found after %d attempts", ErrAlreadyExists, attempt)
+
+		case err != nil:
+			return BOMCreated{}, err
+		}
 
-	var modifiedBuf bytes.Buffer
-	encoder := cdx.NewBOMEncoder(&modifiedBuf, cdx.BOMFileFormatJSON)
-	if err = encoder.Encode(&bom); err != nil {
-		return BOMCreated{}, err
+		slog.DebugContext(ctx, "Stored modified BOM.", slog.Int("version", bom.Version))
+		return BOMCreated{
+			SerialNumber: bom.SerialNumber,
+			Version:      bom.Version,
+		}, nil
 	}
+}
 
-	if err := s.store.Upload(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, modifiedBuf.Bytes()); err != nil {
-		return BOMCreated{}, err
+// nextVersion returns the version following the newest stored version of
+// the serial number, or 1 if no numeric version is stored.
+func (s Service) nextVersion(ctx context.Context, serialNumber string) (int, error) {
+	versions, hasOriginal, err := s.store.GetObjectVersions(ctx, serialNumber)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		slog.DebugContext(ctx, "First BOM with this SN, assigning Version '1'.")
+		return 1, nil
+	case err != nil:
+		return 0, err
+	case len(versions) == 0:
+		// only the original is left, the numeric versions were purged
+		slog.DebugContext(ctx, "No numeric version of this SN stored, assigning Version '1'.")
+		return 1, nil
 	}
-	slog.DebugContext(ctx, "Stored modified BOM.")
-	return BOMCreated{
-		SerialNumber: bom.SerialNumber,
-		Version:      bom.Version,
-	}, nil
+
+	next := versions[len(versions)-1] + 1
+	slog.DebugContext(ctx, "New version assigned to BOM.",
+		slog.Int("new-version", next),
+		slog.Any("all-versions", versions),
+		slog.Bool("has-original", hasOriginal),
+	)
+	return next, nil
 }
 
-func (s Service) uploadCaseSNValidVersionValid(ctx context.Context, bom cdx.BOM, orig bytes.Buffer, cryptoStats string) (BOMCreated, error) {
+func (s Service) uploadCaseSNValidVersionValid(ctx context.Context, bom cdx.BOM, orig *spool, meta store.Metadata) (BOMCreated, error) {
 	slog.DebugContext(ctx, "BOM has serial number and version specified.")
 	// let's make sure it doesn't exist already
 	exists, err := s.store.KeyExists(ctx, uploadKey(bom.SerialNumber, bom.Version))
@@ -214,12 +373,19 @@ func (s Service) uploadCaseSNValidVersionValid(ctx context.Context, bom cdx.BOM,
 		}, ErrAlreadyExists
 	}
 
-	meta := store.Metadata{
-		Version:     fmt.Sprintf("%d", bom.Version),
-		CryptoStats: cryptoStats,
-	}
+	meta.Version = fmt.Sprintf("%d", bom.Version)
 
-	if err := s.store.Upload(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, orig.Bytes()); err != nil {
+	// the check above is a shortcut only, the store rejects the upload if the
+	// same version was stored by a concurrent upload in the meantime
+	err = s.store.UploadStream(ctx, uploadKey(bom.SerialNumber, bom.Version), meta, orig.Reader())
+	switch {
+	case errors.Is(err, store.ErrAlreadyExists):
+		return BOMCreated{
+			SerialNumber: bom.SerialNumber,
+			Version:      bom.Version,
+		}, ErrAlreadyExists
+
+	case err != nil:
 		return BOMCreated{}, err
 	}
 	slog.DebugContext(ctx, "Stored original BOM")
@@ -230,6 +396,56 @@ func (s Service) uploadCaseSNValidVersionValid(ctx context.Context, bom cdx.BOM,
 	}, nil
 }
 
+// uploadEncoded encodes the BOM in the media type of `meta` straight into the
+// store upload through a pipe, so that the encoded document is never held in
+// memory as a whole. Encoding errors reach the store through the pipe and fail
+// the upload.
+func (s Service) uploadEncoded(ctx context.Context, key string, meta store.Metadata, bom *cdx.BOM) error {
+	format, ok := bomFileFormat(meta.ContentType)
+	if !ok {
+		format = cdx.BOMFileFormatJSON
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := encodeBOM(pw, bom, format, bom.SpecVersion)
+		if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			slog.ErrorContext(ctx, "`encodeBOM()` failed.", slog.String("error", err.Error()))
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	err := s.store.UploadStream(ctx, key, meta, pr)
+	// unblocks the encoder if the upload gave up before reading everything
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	return err
+}
+
+// jsonDocument returns the BOM as decoded from its JSON encoding, it is the
+// input of the JSON schema validation of BOMs uploaded as protobuf.
+func jsonDocument(bom *cdx.BOM) (any, error) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = pw.CloseWithError(cdx.NewBOMEncoder(pw, cdx.BOMFileFormatJSON).Encode(bom))
+	}()
+	defer func() {
+		// unblocks the encoder if decoding gave up before reading everything
+		_ = pr.CloseWithError(io.ErrClosedPipe)
+		<-done
+	}()
+
+	var doc any
+	if err := json.NewDecoder(pr).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
 // uploadInputChecks returns error in case BOM fails any of the input checks,
 // nil otherwise.
 func uploadInputChecks(bom cdx.BOM, expectedVersion string) error {
@@ -2,8 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -251,6 +257,90 @@ func TestUploadBOM_InvalidJSONAndSchemaMismatch(t *testing.T) {
 	require.ErrorIs(t, err, ErrValidation)
 }
 
+func TestUploadBOM_SchemaViolations(t *testing.T) {
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [
+			{"type": "not-a-type", "name": "first"},
+			{"type": "library", "name": "second", "scope": "not-a-scope"}
+		]
+	}`
+
+	svc, err := New(store.NewMemory(), Config{MaxValidationErrors: 1000})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorIs(t, err, ErrValidation)
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	require.Equal(t, "validation failed: does not conform to the declared schema", schemaErr.Error())
+	require.Len(t, schemaErr.Violations, schemaErr.Total)
+
+	pointers := make([]string, 0, len(schemaErr.Violations))
+	for _, v := range schemaErr.Violations {
+		require.NotEmpty(t, v.Keyword)
+		require.NotEmpty(t, v.Message)
+		pointers = append(pointers, v.Pointer)
+	}
+	require.Contains(t, pointers, "/components/0/type")
+	require.Contains(t, pointers, "/components/1/scope")
+
+	// the list is capped, the total is kept
+	svc, err = New(store.NewMemory(), Config{MaxValidationErrors: 1})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorAs(t, err, &schemaErr)
+	require.Len(t, schemaErr.Violations, 1)
+	require.Greater(t, schemaErr.Total, 1)
+
+	svc, err = New(store.NewMemory(), Config{})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorAs(t, err, &schemaErr)
+	require.Empty(t, schemaErr.Violations)
+	require.Greater(t, schemaErr.Total, 1)
+}
+
+// TestUploadBOM_ReferencedSubschema guards the conversion of the draft-07
+// definitions: the invalid value is only caught by a subschema reached via
+// `$ref`, which the unconverted schema does not apply.
+func TestUploadBOM_ReferencedSubschema(t *testing.T) {
+	body := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"version": 1,
+		"components": [
+			{
+				"type": "cryptographic-asset",
+				"name": "AES-128-GCM",
+				"cryptoProperties": {"assetType": "not-an-asset-type"}
+			}
+		]
+	}`
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(body), &doc))
+
+	raw, err := schemas.ReadFile(versionToEmbeddedFileMapping["1.6"])
+	require.NoError(t, err)
+	unconverted, err := jsonschema.NewCompiler().Compile(raw)
+	require.NoError(t, err)
+	require.True(t, unconverted.Validate(doc).IsValid())
+
+	svc, err := New(store.NewMemory(), Config{MaxValidationErrors: 1000})
+	require.NoError(t, err)
+	_, err = svc.UploadBOM(context.Background(), io.NopCloser(strings.NewReader(body)), "1.6")
+	require.ErrorIs(t, err, ErrValidation)
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+
+	pointers := make([]string, 0, len(schemaErr.Violations))
+	for _, v := range schemaErr.Violations {
+		pointers = append(pointers, v.Pointer)
+	}
+	require.Contains(t, pointers, "/components/0/cryptoProperties/assetType")
+}
+
 func TestUploadBOM_VersionIncrementHasOriginal(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -284,6 +374,72 @@ func TestUploadBOM_VersionIncrementHasOriginal(t *testing.T) {
 	require.Equal(t, 2, res.Version)
 }
 
+func TestUploadBOM_VersionTakenConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Manager := mockS3.NewMockS3Manager(ctrl)
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, s3Manager)
+	svc, err := New(st, Config{CheckOnFetch: false})
+	require.NoError(t, err)
+
+	now := time.Now()
+	serial := "urn:uuid:550e8400-e29b-11d4-a716-446655440000"
+	s3Mock.EXPECT().ListObjectsV2(gomock.Any(), gomock.Any(), gomock.Any()).Return(&s3.ListObjectsV2Output{
+		Contents: []types.Object{
+			{Key: awsString(serial + "-1"), LastModified: &now},
+		},
+	}, nil)
+
+	// version 2 is stored by a concurrent upload in the meantime, so version 3 is expected
+	gomock.InOrder(
+		s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *manager.UploadObjectInput, _ ...func(*manager.Options)) (*manager.UploadObjectOutput, error) {
+				require.Equal(t, serial+"-2", *in.Key)
+				return nil, statusError(http.StatusPreconditionFailed)
+			}),
+		s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *manager.UploadObjectInput, _ ...func(*manager.Options)) (*manager.UploadObjectOutput, error) {
+				require.Equal(t, serial+"-3", *in.Key)
+				require.Equal(t, "3", in.Metadata[store.MetaVersionKey])
+				return &manager.UploadObjectOutput{}, nil
+			}),
+	)
+
+	rc := io.NopCloser(strings.NewReader("{\n  \"bomFormat\": \"CycloneDX\",\n  \"specVersion\": \"1.6\",\n  \"serialNumber\": \"" + serial + "\"\n}"))
+
+	res, err := svc.UploadBOM(context.Background(), rc, "1.6")
+	require.NoError(t, err)
+	require.Equal(t, serial, res.SerialNumber)
+	require.Equal(t, 3, res.Version)
+}
+
+func TestUploadBOM_SerialVersionTakenConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Manager := mockS3.NewMockS3Manager(ctrl)
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, s3Manager)
+	svc, err := New(st, Config{CheckOnFetch: false})
+	require.NoError(t, err)
+
+	// key does not exist yet when checked, but a concurrent upload stores it before us
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return((*s3.HeadObjectOutput)(nil), &types.NotFound{})
+	s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).Return(nil, statusError(http.StatusPreconditionFailed))
+
+	serial := "urn:uuid:550e8400-e29b-11d4-a716-446655440000"
+	rc := io.NopCloser(strings.NewReader(minimalBOMJSON(true, serial, 3, false)))
+
+	res, err := svc.UploadBOM(context.Background(), rc, "1.6")
+	require.ErrorIs(t, err, ErrAlreadyExists)
+	require.Equal(t, serial, res.SerialNumber)
+	require.Equal(t, 3, res.Version)
+}
+
 func TestUploadBOM_SerialVersionSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -371,5 +527,134 @@ func TestSupportedVersionAndVersionSupported(t *testing.T) {
 	require.False(t, s.VersionSupported("1.5"))
 }
 
+func TestConvertDraft07(t *testing.T) {
+	b, err := convertDraft07([]byte(`{
+		"definitions": {
+			"a": {"type": "string"},
+			"tuple": {"type": "array", "items": [{"$ref": "#/definitions/a"}], "additionalItems": false},
+			"list": {"type": "array", "items": {"$ref": "other.json#/definitions/b"}, "additionalItems": false}
+		},
+		"properties": {
+			"definitions": {"$ref": "#/definitions/a"}
+		}
+	}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"$defs": {
+			"a": {"type": "string"},
+			"tuple": {"type": "array", "prefixItems": [{"$ref": "#/$defs/a"}], "items": false},
+			"list": {"type": "array", "items": {"$ref": "other.json#/$defs/b"}}
+		},
+		"properties": {
+			"definitions": {"$ref": "#/$defs/a"}
+		}
+	}`, string(b))
+}
+
+func TestNew_MissingSubSchema(t *testing.T) {
+	files := embeddedSubSchemaFiles
+	t.Cleanup(func() {
+		embeddedSubSchemaFiles = files
+	})
+	embeddedSubSchemaFiles = append(slices.Clone(files), "schemas/missing.schema.json")
+
+	_, err := New(store.NewMemory(), Config{})
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	sp := newSpool(UploadConfig{SpoolThreshold: 8, SpoolDir: dir})
+
+	_, err := sp.Write([]byte("0123"))
+	require.NoError(t, err)
+	require.False(t, sp.OnDisk())
+
+	// the threshold is exceeded, the contents are moved into a file
+	_, err = sp.Write([]byte("456789"))
+	require.NoError(t, err)
+	require.True(t, sp.OnDisk())
+	require.Equal(t, int64(10), sp.Size())
+
+	// the contents can be read repeatedly
+	for range 2 {
+		b, err := io.ReadAll(sp.Reader())
+		require.NoError(t, err)
+		require.Equal(t, "0123456789", string(b))
+	}
+
+	require.NoError(t, sp.Close())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestUploadBOM_SpooledToDisk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend := store.NewMemory()
+	svc, err := New(backend, Config{Upload: UploadConfig{SpoolThreshold: 16, SpoolDir: dir}})
+	require.NoError(t, err)
+
+	body := minimalBOMJSON(false, "", 0, false)
+	res, err := svc.UploadBOM(ctx, io.NopCloser(strings.NewReader(body)), "1.6")
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Version)
+
+	// the original is stored as uploaded, the modified version is encoded
+	original, err := backend.GetObject(ctx, uploadKeyOriginal(res.SerialNumber))
+	require.NoError(t, err)
+	require.Equal(t, body, string(original))
+	modified, err := backend.GetObject(ctx, uploadKey(res.SerialNumber, 1))
+	require.NoError(t, err)
+	require.Contains(t, string(modified), res.SerialNumber)
+
+	// the spooled body is removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestUploadBOM_EncodedUploadFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Manager := mockS3.NewMockS3Manager(ctrl)
+
+	st := store.New(store.Config{Bucket: "bucket"}, s3Mock, s3Manager)
+	svc, err := New(st, Config{})
+	require.NoError(t, err)
+
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return((*s3.HeadObjectOutput)(nil), &types.NotFound{})
+	gomock.InOrder(
+		// the original is read from the spool
+		s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *manager.UploadObjectInput, _ ...func(*manager.Options)) (*manager.UploadObjectOutput, error) {
+				b, err := io.ReadAll(in.Body)
+				require.NoError(t, err)
+				require.Equal(t, minimalBOMJSON(false, "", 0, false), string(b))
+				return &manager.UploadObjectOutput{}, nil
+			}),
+		// the modified BOM upload gives up before reading the whole body
+		s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, in *manager.UploadObjectInput, _ ...func(*manager.Options)) (*manager.UploadObjectOutput, error) {
+				_, err := in.Body.Read(make([]byte, 1))
+				require.NoError(t, err)
+				return nil, errors.New("connection reset")
+			}),
+	)
+
+	rc := io.NopCloser(strings.NewReader(minimalBOMJSON(false, "", 0, false)))
+	_, err = svc.UploadBOM(context.Background(), rc, "1.6")
+	require.ErrorContains(t, err, "connection reset")
+}
+
 // helper to create *string for aws types
 func awsString(s string) *string { return &s }
+
+// statusError mimics http response errors returned by the aws sdk.
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("http response error StatusCode: %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
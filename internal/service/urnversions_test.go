@@ -25,7 +25,7 @@ func TestService_UrnVersions(t *testing.T) {
 	cryptoStats := service.CryptoStats{
 		CryptoAsset: service.CryptoAssetStats{
 			Total: 10,
-			Algo:  service.TotalStats{Total: 3},
+			Algo:  service.AlgorithmStats{Total: 3},
 			Cert:  service.TotalStats{Total: 2},
 		},
 	}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/log"
+)
+
+// BOMValidated is the outcome of a BOM which passed ValidateBOM, it describes
+// what an upload of the BOM would store.
+type BOMValidated struct {
+	// SerialNumber of the BOM, empty if the upload would generate a new one.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// Version the upload would assign to the BOM.
+	Version     int         `json:"version"`
+	CryptoStats CryptoStats `json:"cryptoStats"`
+	// Warnings lists the violations of semantic rules in the warn mode.
+	Warnings []SemanticIssue `json:"warnings,omitempty"`
+	// Findings lists the violations of policies in the warn and record modes,
+	// those the upload would store along with the BOM.
+	Findings []PolicyFinding `json:"findings,omitempty"`
+}
+
+// ValidateBOM runs the same validation stages as UploadBOMOfType and
+// computes the crypto statistics, without writing anything to the store.
+//
+// The version is assigned the way the upload would assign it at the time of
+// the call, a concurrent upload may take it before the BOM is uploaded:
+//
+//  1. Missing serial number: the upload generates a new serial number, version 1.
+//  2. Serial number with invalid version (< 1): the version following the newest stored one.
+//  3. Serial number and version: the version as-is, ErrAlreadyExists if it is stored already.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines and additional slog fields.
+//   - rc: Reader containing the BOM document (will be closed by this function)
+//   - mediaType: MediaTypeJSON, MediaTypeXML or MediaTypeProtobuf
+//   - schemaVersion: Expected CycloneDX schema version (e.g., "1.6")
+//
+// Returns:
+//   - BOMValidated: The would-be serial number and version, crypto statistics, warnings and findings
+//   - error: ErrValidation if validation fails, ErrAlreadyExists if the BOM already exists,
+//     or other errors from decoding or storage operations
+func (s Service) ValidateBOM(ctx context.Context, rc io.ReadCloser, mediaType, schemaVersion string) (BOMValidated, error) {
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	ctx = log.ContextAttrs(ctx,
+		slog.String("declared-bom-schema-version", schemaVersion),
+		slog.String("media-type", mediaType),
+	)
+
+	format, ok := bomFileFormat(mediaType)
+	if !ok {
+		// this shouldn't happen, if http handler correctly checks the content type
+		return BOMValidated{}, fmt.Errorf("unsupported media type %s", mediaType)
+	}
+
+	body, err := s.spoolBOM(ctx, rc)
+	if err != nil {
+		return BOMValidated{}, err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			slog.WarnContext(ctx, "Removing spooled BOM failed.", slog.String("error", err.Error()))
+		}
+	}()
+
+	bom, warnings, findings, err := s.checkBOM(ctx, body, format, schemaVersion)
+	if err != nil {
+		return BOMValidated{}, err
+	}
+
+	retVal := BOMValidated{
+		SerialNumber: bom.SerialNumber,
+		Version:      bom.Version,
+		CryptoStats:  CalculateCryptoStats(ctx, &bom),
+		Warnings:     capIssues(warnings, s.config.MaxValidationErrors),
+		Findings:     capFindings(findings, s.config.MaxValidationErrors),
+	}
+	switch {
+	case bom.SerialNumber == "":
+		retVal.Version = 1
+
+	case bom.Version < 1:
+		if retVal.Version, err = s.nextVersion(ctx, bom.SerialNumber); err != nil {
+			return BOMValidated{}, err
+		}
+
+	default:
+		exists, err := s.store.KeyExists(ctx, uploadKey(bom.SerialNumber, bom.Version))
+		if err != nil {
+			return BOMValidated{}, err
+		}
+		if exists {
+			return retVal, ErrAlreadyExists
+		}
+	}
+	slog.DebugContext(ctx, "BOM validated.", slog.String("serial-number", retVal.SerialNumber), slog.Int("version", retVal.Version))
+
+	return retVal, nil
+}
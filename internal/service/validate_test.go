@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/service"
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBOM(t *testing.T) {
+	ctx := context.Background()
+	urn := "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79"
+	backend := store.NewMemory()
+	require.NoError(t, backend.Upload(ctx, urn+"-1", store.Metadata{Version: "1"}, []byte(`{}`)))
+	require.NoError(t, backend.Upload(ctx, urn+"-2", store.Metadata{Version: "2"}, []byte(`{}`)))
+	svc, err := service.New(backend, service.Config{MaxValidationErrors: 20})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		body       string
+		wantSerial string
+		wantVer    int
+		wantErr    error
+	}{
+		"serial number generated": {
+			body:    `{"bomFormat":"CycloneDX","specVersion":"1.6","version":3}`,
+			wantVer: 1,
+		},
+		"next version": {
+			body:       `{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":"` + urn + `"}`,
+			wantSerial: urn,
+			wantVer:    3,
+		},
+		"version as-is": {
+			body:       `{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":"` + urn + `","version":5}`,
+			wantSerial: urn,
+			wantVer:    5,
+		},
+		"version exists": {
+			body:    `{"bomFormat":"CycloneDX","specVersion":"1.6","serialNumber":"` + urn + `","version":2}`,
+			wantErr: service.ErrAlreadyExists,
+		},
+		"schema violation": {
+			body:    `{"bomFormat":"CycloneDX","specVersion":"1.6","components":[{"type":"not-a-type","name":"x"}]}`,
+			wantErr: service.ErrValidation,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := svc.ValidateBOM(ctx, io.NopCloser(strings.NewReader(tc.body)), service.MediaTypeJSON, "1.6")
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantSerial, got.SerialNumber)
+			require.Equal(t, tc.wantVer, got.Version)
+		})
+	}
+
+	// nothing was written
+	versions, hasOriginal, err := backend.GetObjectVersions(ctx, urn)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, versions)
+	require.False(t, hasOriginal)
+	entries, err := backend.Search(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
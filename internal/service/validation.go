@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	jss "github.com/kaptinlin/jsonschema"
+)
+
+// SchemaViolation is a single failure reported by the schema validator.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer of the failing part of the BOM, empty for the whole document.
+	// The violations of XML documents are pointed to by element paths, e.g.
+	// `/bom/components/component[1]/@bom-ref`, see xsdSchema.
+	Pointer string
+	// Keyword is the schema keyword which failed, e.g. `required` or `enum`.
+	Keyword string
+	// Message is the human readable description of the failure.
+	Message string
+}
+
+// SchemaError is returned when an uploaded BOM does not conform to its
+// declared schema. It wraps ErrValidation, so errors.Is(err, ErrValidation)
+// holds for it.
+type SchemaError struct {
+	// Violations found by the validator, capped to Config.MaxValidationErrors.
+	Violations []SchemaViolation
+	// Total is the number of violations found, it exceeds the length of
+	// Violations if the list was capped.
+	Total int
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: does not conform to the declared schema", ErrValidation)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrValidation
+}
+
+// aggregateKeywords fail only because of a failure of their subschemas, which
+// is reported by the nested results with a more specific pointer.
+var aggregateKeywords = map[string]struct{}{
+	"$ref":              {},
+	"allOf":             {},
+	"items":             {},
+	"patternProperties": {},
+	"prefixItems":       {},
+	"properties":        {},
+}
+
+// newSchemaError collects the violations of a failed validation, in the
+// order they were evaluated, keeping at most `limit` of them.
+func newSchemaError(res *jss.EvaluationResult, limit int) *SchemaError {
+	e := &SchemaError{}
+	// instance locations of the nested results are relative to their parent
+	var walk func(r *jss.EvaluationResult, base string)
+	walk = func(r *jss.EvaluationResult, base string) {
+		if r == nil || r.Valid {
+			return
+		}
+		pointer := base + r.InstanceLocation
+		for _, keyword := range slices.Sorted(maps.Keys(r.Errors)) {
+			if _, ok := aggregateKeywords[keyword]; ok {
+				continue
+			}
+			e.Total++
+			if len(e.Violations) >= limit {
+				continue
+			}
+			e.Violations = append(e.Violations, SchemaViolation{
+				Pointer: pointer,
+				Keyword: keyword,
+				Message: r.Errors[keyword].Error(),
+			})
+		}
+		for _, d := range r.Details {
+			walk(d, pointer)
+		}
+	}
+	walk(res, "")
+	return e
+}
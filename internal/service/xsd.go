@@ -0,0 +1,889 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// xsdSchema is a compiled XML schema. It supports the subset of XML Schema
+// the CycloneDX schemas are written in: named and anonymous simple and complex
+// types, sequences and choices of elements, `##other` wildcards, attributes,
+// simple content, restrictions by enumeration, pattern, length and inclusive
+// bounds, and unions. Identity constraints (`xs:unique`) are not checked.
+type xsdSchema struct {
+	namespace    string
+	elements     map[string]*xsdElement
+	complexTypes map[string]*xsdComplexType
+	simpleTypes  map[string]*xsdSimpleType
+	// imported schemas by their namespace
+	imports map[string]*xsdSchema
+}
+
+// xsdElement is an element declaration, an element of neither a complex nor
+// a simple type is of any type, its content is not validated.
+type xsdElement struct {
+	name    string
+	complex *xsdComplexType
+	simple  *xsdSimpleType
+	// repeated is true if the element may occur more than once in its
+	// parent, its position is part of the paths of the violations then.
+	repeated bool
+}
+
+// xsdParticle is a part of a content model: an element, a wildcard, or a
+// sequence or a choice of other particles.
+type xsdParticle struct {
+	element   *xsdElement
+	wildcard  bool
+	choice    bool
+	particles []*xsdParticle
+	min       int
+	// max is negative if unbounded
+	max int
+}
+
+type xsdComplexType struct {
+	// content is nil for empty and simple content
+	content *xsdParticle
+	// elements are the elements of the content model by their name
+	elements map[string]*xsdElement
+	// wildcard is true if the content model accepts elements of other
+	// namespaces
+	wildcard bool
+	// text is the type of simple content, nil for element-only content
+	text       *xsdSimpleType
+	attributes map[string]*xsdAttribute
+	// otherAttributes accepts undeclared attributes of other namespaces than
+	// the target one, anyAttributes of any namespace or none
+	otherAttributes bool
+	anyAttributes   bool
+}
+
+type xsdAttribute struct {
+	name     string
+	required bool
+	// typ is nil for attributes of any simple type
+	typ *xsdSimpleType
+}
+
+// xsdSimpleType is either a built-in type, a restriction of its base type or
+// a union of its member types.
+type xsdSimpleType struct {
+	name    string
+	builtin string
+	base    *xsdSimpleType
+	union   []*xsdSimpleType
+
+	enumeration  []string
+	patterns     []*regexp.Regexp
+	minLength    *int
+	maxLength    *int
+	minInclusive *float64
+	maxInclusive *float64
+}
+
+// xsdBuiltinPatterns are the lexical spaces of the built-in types used by the
+// CycloneDX schemas, the string types and anyURI accept any value.
+var xsdBuiltinPatterns = map[string]*regexp.Regexp{
+	"string":             nil,
+	"normalizedString":   nil,
+	"token":              nil,
+	"anyURI":             nil,
+	"boolean":            regexp.MustCompile(`^(true|false|1|0)$`),
+	"integer":            regexp.MustCompile(`^[+-]?[0-9]+$`),
+	"positiveInteger":    regexp.MustCompile(`^\+?0*[1-9][0-9]*$`),
+	"nonNegativeInteger": regexp.MustCompile(`^(\+?[0-9]+|-0+)$`),
+	"decimal":            regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`),
+	"dateTime":           regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`),
+}
+
+// xsdNode is an element of a schema document, annotations are left out.
+type xsdNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xsdNode
+}
+
+// parseXSD reads the elements of a schema document into a tree. The
+// namespace prefixes declared by the root element are returned as well.
+func parseXSD(b []byte) (*xsdNode, map[string]string, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	prefixes := make(map[string]string)
+	var (
+		root  *xsdNode
+		stack []*xsdNode
+	)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Space != xsdNamespace || tok.Name.Local == "annotation" {
+				if err := d.Skip(); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			node := &xsdNode{name: tok.Name.Local, attrs: make(map[string]string)}
+			for _, a := range tok.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					if root == nil {
+						prefixes[a.Name.Local] = a.Value
+					}
+				case a.Name.Space == "":
+					node.attrs[a.Name.Local] = a.Value
+				}
+			}
+			if root == nil {
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			}
+			stack = append(stack, node)
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if root == nil || root.name != "schema" {
+		return nil, nil, errors.New("not an XML schema")
+	}
+	return root, prefixes, nil
+}
+
+// xsdCompiler compiles a schema document, `prefixes` are the namespace
+// prefixes of its root element.
+type xsdCompiler struct {
+	schema   *xsdSchema
+	prefixes map[string]string
+}
+
+// compileXSD compiles a schema document. The schemas it imports must be
+// compiled first and passed in `imports`.
+func compileXSD(b []byte, imports ...*xsdSchema) (*xsdSchema, error) {
+	root, prefixes, err := parseXSD(b)
+	if err != nil {
+		return nil, err
+	}
+
+	c := xsdCompiler{
+		schema: &xsdSchema{
+			namespace:    root.attrs["targetNamespace"],
+			elements:     make(map[string]*xsdElement),
+			complexTypes: make(map[string]*xsdComplexType),
+			simpleTypes:  make(map[string]*xsdSimpleType),
+			imports:      make(map[string]*xsdSchema),
+		},
+		prefixes: prefixes,
+	}
+	for _, imported := range imports {
+		c.schema.imports[imported.namespace] = imported
+	}
+
+	// the named types are declared ahead, so that they can be referenced
+	// before their definition and recursively
+	for _, n := range root.children {
+		switch n.name {
+		case "complexType":
+			c.schema.complexTypes[n.attrs["name"]] = &xsdComplexType{}
+		case "simpleType":
+			c.schema.simpleTypes[n.attrs["name"]] = &xsdSimpleType{name: n.attrs["name"]}
+		}
+	}
+
+	for _, n := range root.children {
+		switch n.name {
+		case "complexType":
+			if err := c.complexType(n, c.schema.complexTypes[n.attrs["name"]]); err != nil {
+				return nil, fmt.Errorf("complex type %s: %w", n.attrs["name"], err)
+			}
+		case "simpleType":
+			if err := c.simpleType(n, c.schema.simpleTypes[n.attrs["name"]]); err != nil {
+				return nil, fmt.Errorf("simple type %s: %w", n.attrs["name"], err)
+			}
+		case "element":
+			element, err := c.element(n, false)
+			if err != nil {
+				return nil, fmt.Errorf("element %s: %w", n.attrs["name"], err)
+			}
+			c.schema.elements[element.name] = element
+		case "import":
+			if _, ok := c.schema.imports[n.attrs["namespace"]]; !ok {
+				return nil, fmt.Errorf("imported schema %s missing", n.attrs["namespace"])
+			}
+		}
+	}
+	return c.schema, nil
+}
+
+// resolve splits a qualified name into the schema it belongs to, nil for
+// the XML Schema namespace, and the local name.
+func (c xsdCompiler) resolve(qname string) (*xsdSchema, string, error) {
+	prefix, local, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix, local = "", qname
+	}
+	namespace, ok := c.prefixes[prefix]
+	switch {
+	case !ok:
+		return nil, "", fmt.Errorf("undeclared prefix of %s", qname)
+	case namespace == xsdNamespace:
+		return nil, local, nil
+	case namespace == c.schema.namespace:
+		return c.schema, local, nil
+	}
+	if imported, ok := c.schema.imports[namespace]; ok {
+		return imported, local, nil
+	}
+	return nil, "", fmt.Errorf("namespace of %s not imported", qname)
+}
+
+// typeRef resolves a reference to a named type, exactly one of the results
+// is set.
+func (c xsdCompiler) typeRef(qname string) (*xsdComplexType, *xsdSimpleType, error) {
+	schema, local, err := c.resolve(qname)
+	if err != nil {
+		return nil, nil, err
+	}
+	if schema == nil {
+		if _, ok := xsdBuiltinPatterns[local]; !ok {
+			return nil, nil, fmt.Errorf("unsupported built-in type %s", qname)
+		}
+		return nil, &xsdSimpleType{name: local, builtin: local}, nil
+	}
+	if t, ok := schema.complexTypes[local]; ok {
+		return t, nil, nil
+	}
+	if t, ok := schema.simpleTypes[local]; ok {
+		return nil, t, nil
+	}
+	return nil, nil, fmt.Errorf("undefined type %s", qname)
+}
+
+// simpleTypeRef resolves a reference to a named simple type.
+func (c xsdCompiler) simpleTypeRef(qname string) (*xsdSimpleType, error) {
+	_, t, err := c.typeRef(qname)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%s is not a simple type", qname)
+	}
+	return t, nil
+}
+
+func (c xsdCompiler) element(n *xsdNode, repeated bool) (*xsdElement, error) {
+	element := &xsdElement{name: n.attrs["name"], repeated: repeated}
+	if qname, ok := n.attrs["type"]; ok {
+		var err error
+		if element.complex, element.simple, err = c.typeRef(qname); err != nil {
+			return nil, err
+		}
+	}
+	for _, child := range n.children {
+		switch child.name {
+		case "complexType":
+			element.complex = &xsdComplexType{}
+			if err := c.complexType(child, element.complex); err != nil {
+				return nil, err
+			}
+		case "simpleType":
+			element.simple = &xsdSimpleType{name: element.name}
+			if err := c.simpleType(child, element.simple); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return element, nil
+}
+
+func (c xsdCompiler) complexType(n *xsdNode, t *xsdComplexType) error {
+	t.elements = make(map[string]*xsdElement)
+	t.attributes = make(map[string]*xsdAttribute)
+	for _, child := range n.children {
+		switch child.name {
+		case "sequence", "choice":
+			p, err := c.particle(t, child, false)
+			if err != nil {
+				return err
+			}
+			t.content = p
+		case "simpleContent":
+			for _, ext := range child.children {
+				if ext.name != "extension" {
+					return fmt.Errorf("unsupported simple content %s", ext.name)
+				}
+				text, err := c.simpleTypeRef(ext.attrs["base"])
+				if err != nil {
+					return err
+				}
+				t.text = text
+				if err := c.attributes(t, ext); err != nil {
+					return err
+				}
+			}
+		case "complexContent":
+			return errors.New("complex content is not supported")
+		}
+	}
+	return c.attributes(t, n)
+}
+
+// attributes adds the attributes declared among the children of `n`.
+func (c xsdCompiler) attributes(t *xsdComplexType, n *xsdNode) error {
+	for _, child := range n.children {
+		switch child.name {
+		case "attribute":
+			attr := &xsdAttribute{name: child.attrs["name"], required: child.attrs["use"] == "required"}
+			if qname, ok := child.attrs["type"]; ok {
+				var err error
+				if attr.typ, err = c.simpleTypeRef(qname); err != nil {
+					return fmt.Errorf("attribute %s: %w", attr.name, err)
+				}
+			}
+			for _, st := range child.children {
+				if st.name == "simpleType" {
+					attr.typ = &xsdSimpleType{name: attr.name}
+					if err := c.simpleType(st, attr.typ); err != nil {
+						return fmt.Errorf("attribute %s: %w", attr.name, err)
+					}
+				}
+			}
+			t.attributes[attr.name] = attr
+		case "anyAttribute":
+			switch ns := child.attrs["namespace"]; ns {
+			case "", "##any":
+				t.anyAttributes = true
+			case "##other":
+				t.otherAttributes = true
+			default:
+				return fmt.Errorf("unsupported attribute wildcard namespace %q", ns)
+			}
+		}
+	}
+	return nil
+}
+
+// particle compiles a content model particle, `repeated` is true if an
+// enclosing particle may occur more than once.
+func (c xsdCompiler) particle(t *xsdComplexType, n *xsdNode, repeated bool) (*xsdParticle, error) {
+	p := &xsdParticle{min: 1, max: 1}
+	if v, ok := n.attrs["minOccurs"]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("minOccurs %q: %w", v, err)
+		}
+		p.min = i
+	}
+	if v, ok := n.attrs["maxOccurs"]; ok {
+		if v == "unbounded" {
+			p.max = -1
+		} else {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("maxOccurs %q: %w", v, err)
+			}
+			p.max = i
+		}
+	}
+	repeated = repeated || p.max != 1
+
+	switch n.name {
+	case "element":
+		element, err := c.element(n, repeated)
+		if err != nil {
+			return nil, fmt.Errorf("element %s: %w", n.attrs["name"], err)
+		}
+		if prev, ok := t.elements[element.name]; ok {
+			// declarations of the same name must have the same type, see
+			// the "Element Declarations Consistent" constraint
+			element = prev
+			element.repeated = true
+		}
+		t.elements[element.name] = element
+		p.element = element
+
+	case "any":
+		if ns := n.attrs["namespace"]; ns != "##other" {
+			return nil, fmt.Errorf("unsupported wildcard namespace %q", ns)
+		}
+		t.wildcard = true
+		p.wildcard = true
+
+	case "sequence", "choice":
+		p.choice = n.name == "choice"
+		for _, child := range n.children {
+			switch child.name {
+			case "element", "any", "sequence", "choice":
+				sub, err := c.particle(t, child, repeated)
+				if err != nil {
+					return nil, err
+				}
+				p.particles = append(p.particles, sub)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported particle %s", n.name)
+	}
+	return p, nil
+}
+
+func (c xsdCompiler) simpleType(n *xsdNode, t *xsdSimpleType) error {
+	for _, child := range n.children {
+		switch child.name {
+		case "restriction":
+			base, err := c.simpleTypeRef(child.attrs["base"])
+			if err != nil {
+				return err
+			}
+			t.base = base
+			if err := c.facets(t, child); err != nil {
+				return err
+			}
+		case "union":
+			for _, qname := range strings.Fields(child.attrs["memberTypes"]) {
+				member, err := c.simpleTypeRef(qname)
+				if err != nil {
+					return err
+				}
+				t.union = append(t.union, member)
+			}
+		default:
+			return fmt.Errorf("unsupported simple type %s", child.name)
+		}
+	}
+	return nil
+}
+
+// facets sets the facets of a restriction.
+func (c xsdCompiler) facets(t *xsdSimpleType, n *xsdNode) error {
+	for _, facet := range n.children {
+		value := facet.attrs["value"]
+		switch facet.name {
+		case "enumeration":
+			t.enumeration = append(t.enumeration, value)
+		case "pattern":
+			// XML Schema patterns match the whole value
+			re, err := regexp.Compile(`^(?:` + value + `)$`)
+			if err != nil {
+				return fmt.Errorf("pattern %q: %w", value, err)
+			}
+			t.patterns = append(t.patterns, re)
+		case "minLength", "maxLength":
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", facet.name, value, err)
+			}
+			if facet.name == "minLength" {
+				t.minLength = &i
+			} else {
+				t.maxLength = &i
+			}
+		case "minInclusive", "maxInclusive":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", facet.name, value, err)
+			}
+			if facet.name == "minInclusive" {
+				t.minInclusive = &f
+			} else {
+				t.maxInclusive = &f
+			}
+		default:
+			return fmt.Errorf("unsupported facet %s", facet.name)
+		}
+	}
+	return nil
+}
+
+// whitespace returns the built-in type a simple type is derived from, which
+// decides how the white space of its values is normalized.
+func (t *xsdSimpleType) whitespace() string {
+	for ; t != nil; t = t.base {
+		if t.builtin != "" {
+			return t.builtin
+		}
+	}
+	return ""
+}
+
+// check validates a value, it returns the failing facet and the reason if
+// the value is invalid.
+func (t *xsdSimpleType) check(value string) (string, string, bool) {
+	if len(t.union) > 0 {
+		for _, member := range t.union {
+			if _, _, ok := member.check(value); ok {
+				return "", "", true
+			}
+		}
+		return "union", fmt.Sprintf("value %q matches none of the member types of %s", value, t.name), false
+	}
+
+	switch t.whitespace() {
+	case "string":
+	case "normalizedString":
+		value = strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, value)
+	default:
+		value = strings.Join(strings.Fields(value), " ")
+	}
+
+	if t.builtin != "" {
+		if re := xsdBuiltinPatterns[t.builtin]; re != nil && !re.MatchString(value) {
+			return "type", fmt.Sprintf("value %q is not a valid %s", value, t.builtin), false
+		}
+		return "", "", true
+	}
+	if keyword, reason, ok := t.base.check(value); !ok {
+		return keyword, reason, false
+	}
+
+	if len(t.enumeration) > 0 && !slices.Contains(t.enumeration, value) {
+		return "enumeration", fmt.Sprintf("value %q is not one of %s", value, strings.Join(t.enumeration, ", ")), false
+	}
+	if len(t.patterns) > 0 && !slices.ContainsFunc(t.patterns, func(re *regexp.Regexp) bool {
+		return re.MatchString(value)
+	}) {
+		return "pattern", fmt.Sprintf("value %q does not match the pattern of %s", value, t.name), false
+	}
+	if length := utf8.RuneCountInString(value); t.minLength != nil && length < *t.minLength {
+		return "minLength", fmt.Sprintf("value %q is shorter than %d", value, *t.minLength), false
+	} else if t.maxLength != nil && length > *t.maxLength {
+		return "maxLength", fmt.Sprintf("value %q is longer than %d", value, *t.maxLength), false
+	}
+	if t.minInclusive != nil || t.maxInclusive != nil {
+		f, err := strconv.ParseFloat(value, 64)
+		switch {
+		case err != nil:
+			return "type", fmt.Sprintf("value %q is not a number", value), false
+		case t.minInclusive != nil && f < *t.minInclusive:
+			return "minInclusive", fmt.Sprintf("value %s is less than %v", value, *t.minInclusive), false
+		case t.maxInclusive != nil && f > *t.maxInclusive:
+			return "maxInclusive", fmt.Sprintf("value %s is greater than %v", value, *t.maxInclusive), false
+		}
+	}
+	return "", "", true
+}
+
+// xsdFrame is an element open during the validation.
+type xsdFrame struct {
+	path     string
+	element  *xsdElement
+	children []xml.Name
+	paths    []string
+	// counts are the numbers of the children by their name
+	counts map[string]int
+	text   strings.Builder
+}
+
+// xsdValidator collects the violations of a document.
+type xsdValidator struct {
+	schema *xsdSchema
+	limit  int
+	err    SchemaError
+}
+
+func (v *xsdValidator) report(path, keyword, message string) {
+	v.err.Total++
+	if len(v.err.Violations) < v.limit {
+		v.err.Violations = append(v.err.Violations, SchemaViolation{Pointer: path, Keyword: keyword, Message: message})
+	}
+}
+
+// validate validates an XML document against the schema. Violations are
+// reported by their path, e.g. "/bom/components/component[2]/name", the
+// position is given for elements which may occur more than once. At most
+// `limit` violations are kept, all of them are counted. The returned
+// SchemaError is nil for a valid document, the error is set for a malformed
+// one.
+func (s *xsdSchema) validate(r io.Reader, limit int) (*SchemaError, error) {
+	v := xsdValidator{schema: s, limit: limit}
+	d := xml.NewDecoder(r)
+	var stack []*xsdFrame
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				element, ok := s.elements[tok.Name.Local]
+				if !ok || tok.Name.Space != s.namespace {
+					v.report("/"+tok.Name.Local, "element", fmt.Sprintf("root element {%s}%s is not declared", tok.Name.Space, tok.Name.Local))
+					return &v.err, nil
+				}
+				stack = append(stack, v.open(element, "/"+element.name, tok))
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			parent.counts[tok.Name.Local]++
+			path := fmt.Sprintf("%s/%s[%d]", parent.path, tok.Name.Local, parent.counts[tok.Name.Local])
+
+			var element *xsdElement
+			if t := parent.element.complex; t != nil && tok.Name.Space == s.namespace {
+				element = t.elements[tok.Name.Local]
+			}
+			switch {
+			case element != nil:
+				if !element.repeated {
+					path = parent.path + "/" + tok.Name.Local
+				}
+				parent.children = append(parent.children, tok.Name)
+				parent.paths = append(parent.paths, path)
+				stack = append(stack, v.open(element, path, tok))
+
+			case parent.element.complex != nil && parent.element.complex.wildcard && tok.Name.Space != s.namespace && tok.Name.Space != "":
+				// elements of other namespaces are processed laxly, there
+				// is no schema of them to validate against
+				parent.children = append(parent.children, tok.Name)
+				parent.paths = append(parent.paths, path)
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+
+			default:
+				v.report(path, "element", fmt.Sprintf("element {%s}%s is not allowed in %s", tok.Name.Space, tok.Name.Local, parent.element.name))
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+
+		case xml.EndElement:
+			v.close(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if v.err.Total == 0 {
+		return nil, nil
+	}
+	return &v.err, nil
+}
+
+// open starts the validation of an element, its attributes are checked.
+func (v *xsdValidator) open(element *xsdElement, path string, start xml.StartElement) *xsdFrame {
+	frame := &xsdFrame{path: path, element: element, counts: make(map[string]int)}
+	anyType := element.complex == nil && element.simple == nil
+
+	seen := make(map[string]struct{})
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Space == "xmlns", a.Name.Space == "" && a.Name.Local == "xmlns", a.Name.Space == xsiNamespace:
+			continue
+		case anyType:
+			continue
+		}
+
+		t := element.complex
+		attrPath := path + "/@" + a.Name.Local
+		if a.Name.Space != "" {
+			if t == nil || !t.anyAttributes && (!t.otherAttributes || a.Name.Space == v.schema.namespace) {
+				v.report(attrPath, "attribute", fmt.Sprintf("attribute {%s}%s is not allowed", a.Name.Space, a.Name.Local))
+			}
+			continue
+		}
+
+		var attr *xsdAttribute
+		if t != nil {
+			attr = t.attributes[a.Name.Local]
+		}
+		if attr == nil {
+			if t == nil || !t.anyAttributes {
+				v.report(attrPath, "attribute", fmt.Sprintf("attribute %s is not allowed", a.Name.Local))
+			}
+			continue
+		}
+		seen[a.Name.Local] = struct{}{}
+		if attr.typ == nil {
+			continue
+		}
+		if keyword, reason, ok := attr.typ.check(a.Value); !ok {
+			v.report(attrPath, keyword, reason)
+		}
+	}
+
+	if element.complex != nil {
+		for _, name := range slices.Sorted(maps.Keys(element.complex.attributes)) {
+			if _, ok := seen[name]; !ok && element.complex.attributes[name].required {
+				v.report(path, "required", fmt.Sprintf("attribute %s is required", name))
+			}
+		}
+	}
+	return frame
+}
+
+// close finishes the validation of an element, its text and its content
+// model are checked.
+func (v *xsdValidator) close(frame *xsdFrame) {
+	element := frame.element
+	text := frame.text.String()
+
+	var textType *xsdSimpleType
+	switch {
+	case element.simple != nil:
+		textType = element.simple
+	case element.complex != nil:
+		textType = element.complex.text
+	default:
+		// any type
+		return
+	}
+
+	if textType != nil {
+		if keyword, reason, ok := textType.check(text); !ok {
+			v.report(frame.path, keyword, reason)
+		}
+	} else if strings.TrimSpace(text) != "" {
+		v.report(frame.path, "content", fmt.Sprintf("text is not allowed in %s", element.name))
+	}
+
+	if element.complex == nil {
+		return
+	}
+	m := xsdMatcher{namespace: v.schema.namespace, children: frame.children, expected: make(map[int][]string)}
+	var end []int
+	if p := element.complex.content; p != nil {
+		end = m.particle(p, []int{0})
+	} else {
+		end = []int{0}
+	}
+	if slices.Contains(end, len(frame.children)) {
+		return
+	}
+
+	expected := "no more elements"
+	if names := m.expected[m.furthest]; len(names) > 0 {
+		slices.Sort(names)
+		expected = "one of " + strings.Join(slices.Compact(names), ", ")
+	}
+	if m.furthest < len(frame.children) {
+		v.report(frame.paths[m.furthest], "content", fmt.Sprintf("element %s is not expected here, expected %s", frame.children[m.furthest].Local, expected))
+	} else {
+		v.report(frame.path, "content", fmt.Sprintf("content of %s is incomplete, expected %s", element.name, expected))
+	}
+}
+
+// xsdMatcher matches the children of an element against a content model.
+// The positions reachable in the children are tracked as sets, so that every
+// alternative of the content model is tried at once.
+type xsdMatcher struct {
+	namespace string
+	children  []xml.Name
+	// furthest is the furthest position reached, expected are the names of
+	// the elements tried at each position
+	furthest int
+	expected map[int][]string
+}
+
+// particle returns the positions reached by matching the particle, with its
+// occurrences, from the positions `from`.
+func (m *xsdMatcher) particle(p *xsdParticle, from []int) []int {
+	var res []int
+	if p.min == 0 {
+		res = from
+	}
+	cur := from
+	for i := 1; p.max < 0 || i <= p.max; i++ {
+		cur = m.once(p, cur)
+		if len(cur) == 0 {
+			break
+		}
+		if i >= p.min {
+			merged := unionPositions(res, cur)
+			if i > p.min && len(merged) == len(res) {
+				// nothing new is reached
+				break
+			}
+			res = merged
+		}
+	}
+	return res
+}
+
+// once returns the positions reached by matching a single occurrence of the
+// particle.
+func (m *xsdMatcher) once(p *xsdParticle, from []int) []int {
+	var res []int
+	switch {
+	case p.element != nil:
+		for _, pos := range from {
+			m.furthest = max(m.furthest, pos)
+			if pos < len(m.children) && m.children[pos] == (xml.Name{Space: m.namespace, Local: p.element.name}) {
+				res = append(res, pos+1)
+				m.furthest = max(m.furthest, pos+1)
+				continue
+			}
+			m.expected[pos] = append(m.expected[pos], p.element.name)
+		}
+
+	case p.wildcard:
+		for _, pos := range from {
+			m.furthest = max(m.furthest, pos)
+			if pos < len(m.children) && m.children[pos].Space != m.namespace && m.children[pos].Space != "" {
+				res = append(res, pos+1)
+				m.furthest = max(m.furthest, pos+1)
+			}
+		}
+
+	case p.choice:
+		for _, sub := range p.particles {
+			res = unionPositions(res, m.particle(sub, from))
+		}
+
+	default:
+		res = from
+		for _, sub := range p.particles {
+			if res = m.particle(sub, res); len(res) == 0 {
+				break
+			}
+		}
+	}
+	return res
+}
+
+// unionPositions merges two sorted sets of positions.
+func unionPositions(a, b []int) []int {
+	res := make([]int, 0, len(a)+len(b))
+	res = append(res, a...)
+	res = append(res, b...)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
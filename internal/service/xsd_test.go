@@ -0,0 +1,193 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testXSD = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/test"
+           elementFormDefault="qualified"
+           targetNamespace="http://example.com/test">
+    <xs:annotation><xs:documentation><name>test</name></xs:documentation></xs:annotation>
+
+    <xs:simpleType name="color">
+        <xs:restriction base="xs:string">
+            <xs:enumeration value="red"/>
+            <xs:enumeration value="blue"/>
+        </xs:restriction>
+    </xs:simpleType>
+    <xs:simpleType name="code">
+        <xs:restriction base="xs:token">
+            <xs:pattern value="[A-Z]{2}"/>
+        </xs:restriction>
+    </xs:simpleType>
+    <xs:simpleType name="colorOrCode">
+        <xs:union memberTypes="t:color t:code"/>
+    </xs:simpleType>
+
+    <xs:complexType name="item">
+        <xs:sequence>
+            <xs:element name="name" type="xs:normalizedString"/>
+            <xs:choice minOccurs="0">
+                <xs:element name="color" type="t:color"/>
+                <xs:element name="code" type="t:code"/>
+            </xs:choice>
+            <xs:element name="size" minOccurs="0">
+                <xs:simpleType>
+                    <xs:restriction base="xs:integer">
+                        <xs:minInclusive value="1"/>
+                        <xs:maxInclusive value="10"/>
+                    </xs:restriction>
+                </xs:simpleType>
+            </xs:element>
+            <xs:element name="items" minOccurs="0">
+                <xs:complexType>
+                    <xs:sequence>
+                        <xs:element name="item" type="t:item" maxOccurs="unbounded"/>
+                    </xs:sequence>
+                </xs:complexType>
+            </xs:element>
+            <xs:any namespace="##other" processContents="lax" minOccurs="0" maxOccurs="unbounded"/>
+        </xs:sequence>
+        <xs:attribute name="id" type="xs:positiveInteger" use="required"/>
+        <xs:attribute name="tag" type="t:colorOrCode"/>
+        <xs:anyAttribute namespace="##other" processContents="lax"/>
+    </xs:complexType>
+
+    <xs:element name="root">
+        <xs:complexType>
+            <xs:sequence>
+                <xs:element name="item" type="t:item" maxOccurs="unbounded"/>
+            </xs:sequence>
+        </xs:complexType>
+    </xs:element>
+</xs:schema>`
+
+func TestXSDSchema_Validate(t *testing.T) {
+	schema, err := compileXSD([]byte(testXSD))
+	require.NoError(t, err)
+
+	type violation struct {
+		pointer string
+		keyword string
+	}
+	tests := map[string]struct {
+		doc  string
+		want []violation
+	}{
+		"valid": {
+			doc: `<root xmlns="http://example.com/test" xmlns:x="http://example.com/other">
+  <item id="1" tag="red" x:note="extension">
+    <name>first</name>
+    <code> AB </code>
+    <size>10</size>
+    <items>
+      <item id="2" tag="CD"><name>nested</name></item>
+      <item id="3"><name>nested</name><color>blue</color></item>
+    </items>
+    <x:extension><anything/></x:extension>
+  </item>
+</root>`,
+		},
+		"root element": {
+			doc:  `<root xmlns="http://example.com/other"/>`,
+			want: []violation{{"/root", "element"}},
+		},
+		"unknown element": {
+			doc:  `<root xmlns="http://example.com/test"><item id="1"><name>a</name><weight>1</weight></item></root>`,
+			want: []violation{{"/root/item[1]/weight[1]", "element"}},
+		},
+		"out of order": {
+			doc:  `<root xmlns="http://example.com/test"><item id="1"><color>red</color><name>a</name></item></root>`,
+			want: []violation{{"/root/item[1]/color", "content"}},
+		},
+		"both of a choice": {
+			doc:  `<root xmlns="http://example.com/test"><item id="1"><name>a</name><color>red</color><code>AB</code></item></root>`,
+			want: []violation{{"/root/item[1]/code", "content"}},
+		},
+		"missing element": {
+			doc:  `<root xmlns="http://example.com/test"><item id="1"/></root>`,
+			want: []violation{{"/root/item[1]", "content"}},
+		},
+		"incomplete content": {
+			doc:  `<root xmlns="http://example.com/test"><item id="1"><name>a</name><items></items></item></root>`,
+			want: []violation{{"/root/item[1]/items", "content"}},
+		},
+		"text in element content": {
+			doc:  `<root xmlns="http://example.com/test">text<item id="1"><name>a</name></item></root>`,
+			want: []violation{{"/root", "content"}},
+		},
+		"attributes": {
+			doc: `<root xmlns="http://example.com/test" xmlns:x="http://example.com/test">
+  <item tag="green" weight="1" x:id="2"><name>a</name></item>
+</root>`,
+			want: []violation{
+				{"/root/item[1]/@tag", "union"},
+				{"/root/item[1]/@weight", "attribute"},
+				{"/root/item[1]/@id", "attribute"},
+				{"/root/item[1]", "required"},
+			},
+		},
+		"values": {
+			doc: `<root xmlns="http://example.com/test">
+  <item id="0"><name>a</name><color>green</color><size>11</size></item>
+  <item id="1"><name>b</name><code>abc</code><size>x</size></item>
+</root>`,
+			want: []violation{
+				{"/root/item[1]/@id", "type"},
+				{"/root/item[1]/color", "enumeration"},
+				{"/root/item[1]/size", "maxInclusive"},
+				{"/root/item[2]/code", "pattern"},
+				{"/root/item[2]/size", "type"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			schemaErr, err := schema.validate(strings.NewReader(tc.doc), 100)
+			require.NoError(t, err)
+			if len(tc.want) == 0 {
+				require.Nil(t, schemaErr)
+				return
+			}
+			require.NotNil(t, schemaErr)
+			var got []violation
+			for _, v := range schemaErr.Violations {
+				require.NotEmpty(t, v.Message)
+				got = append(got, violation{v.Pointer, v.Keyword})
+			}
+			require.Equal(t, tc.want, got)
+			require.Equal(t, len(tc.want), schemaErr.Total)
+		})
+	}
+
+	// the list is capped, the total is kept
+	schemaErr, err := schema.validate(strings.NewReader(tests["values"].doc), 1)
+	require.NoError(t, err)
+	require.Len(t, schemaErr.Violations, 1)
+	require.Equal(t, 5, schemaErr.Total)
+
+	_, err = schema.validate(strings.NewReader(`<root xmlns="http://example.com/test">`), 100)
+	require.Error(t, err)
+}
+
+func TestCompileXSD_Unsupported(t *testing.T) {
+	_, err := compileXSD([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test">
+    <xs:element name="root" type="xs:duration"/>
+</xs:schema>`))
+	require.ErrorContains(t, err, "unsupported built-in type xs:duration")
+
+	_, err = compileXSD([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:o="urn:other" targetNamespace="urn:test">
+    <xs:import namespace="urn:other" schemaLocation="other.xsd"/>
+    <xs:element name="root" type="o:type"/>
+</xs:schema>`))
+	require.Error(t, err)
+
+	_, err = compileXSD([]byte(`<root/>`))
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Supported values of the `APP_STORE_BACKEND` environment variable.
+const (
+	BackendS3         = "s3"
+	BackendFilesystem = "filesystem"
+	BackendMemory     = "memory"
+)
+
+// Backend is the contract between the service layer and the storage holding
+// the BOM documents. Objects are addressed by keys following the invariant
+// "urn:uuid:<uuid>-<version>" where version is either a number or the literal
+// string "original".
+//
+// Store (backed by an S3-compatible object storage) is one implementation,
+// others can be plugged in without touching the service layer as long as they
+// keep the semantics documented on Store, most notably returning ErrNotFound
+// for missing objects and ErrAlreadyExists instead of overwriting an existing
+// one.
+type Backend interface {
+	// Search returns keys of all objects modified after the unix timestamp `ts`.
+	Search(ctx context.Context, ts int64) ([]string, error)
+	// List is like Search, it returns the objects along with their last
+	// modified time, so that callers can narrow them down before reading
+	// their heads.
+	List(ctx context.Context, ts int64) ([]ObjectInfo, error)
+	// GetObjectVersions returns sorted numeric versions stored for `urn` and
+	// whether an "original" version exists.
+	GetObjectVersions(ctx context.Context, urn string) ([]int, bool, error)
+	// GetHeadObject returns the metadata of an object without its contents.
+	GetHeadObject(ctx context.Context, key string) (HeadObject, error)
+	// GetObject returns the complete contents of an object, soft deleted
+	// objects are reported as not found.
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// OpenObject opens the contents of an object for streaming, the caller
+	// must close the returned reader. Soft deleted objects are reported as
+	// not found.
+	OpenObject(ctx context.Context, key string) (ObjectReader, error)
+	// KeyExists reports whether an object with the key exists.
+	KeyExists(ctx context.Context, key string) (bool, error)
+	// Upload stores the contents along with metadata under the key. Upload
+	// is create-only, it returns ErrAlreadyExists if an object with the key
+	// exists, even when racing with a concurrent upload of the same key.
+	Upload(ctx context.Context, key string, meta Metadata, contents []byte) error
+	// UploadStream is like Upload, but it reads the contents from `body`
+	// until EOF, so that large objects need not be held in memory.
+	UploadStream(ctx context.Context, key string, meta Metadata, body io.Reader) error
+	// UpdateMetadata replaces metadata of an existing object, it is used to
+	// soft delete objects by setting Metadata.DeletedAt.
+	UpdateMetadata(ctx context.Context, key string, meta Metadata) error
+	// Delete permanently removes an object, removing a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// HealthCheck returns non-nil error if the backend is not usable.
+	HealthCheck(ctx context.Context) error
+}
+
+// ObjectInfo is an object returned by Backend.List.
+type ObjectInfo struct {
+	Key          string
+	LastModified time.Time
+	// Head is the head of the object if the backend has it at hand, e.g. in
+	// memory or in the metadata index, nil if it must be read by
+	// GetHeadObject.
+	Head *HeadObject
+}
+
+// objectKeys returns the keys of the objects.
+func objectKeys(objects []ObjectInfo) []string {
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+var (
+	_ Backend = Store{}
+	_ Backend = Filesystem{}
+	_ Backend = Memory{}
+	_ Backend = Indexed{}
+)
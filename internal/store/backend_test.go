@@ -0,0 +1,346 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testURN = "urn:uuid:5bd5a7c5-f5f0-40db-a216-d242abba1185"
+
+// backends returns constructors of all backends which can be tested without
+// external dependencies; each of them must pass the same behavioral tests.
+func backends() map[string]func(t *testing.T) store.Backend {
+	return map[string]func(t *testing.T) store.Backend{
+		"filesystem": func(t *testing.T) store.Backend {
+			fs, err := store.NewFilesystem(store.FilesystemConfig{Dir: t.TempDir()})
+			require.NoError(t, err)
+			return fs
+		},
+		"memory": func(t *testing.T) store.Backend {
+			return store.NewMemory()
+		},
+		"indexed": func(t *testing.T) store.Backend {
+			idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = idx.Close()
+			})
+			return store.NewIndexed(store.NewMemory(), idx)
+		},
+	}
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, b store.Backend)) {
+	for name, newBackend := range backends() {
+		t.Run(name, func(t *testing.T) {
+			test(t, newBackend(t))
+		})
+	}
+}
+
+func TestBackend_UploadAndGet(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+		meta := store.Metadata{Version: "1", CryptoStats: `{"cryptoAssets":{"total":1}}`}
+
+		exists, err := b.KeyExists(ctx, key)
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		require.NoError(t, b.Upload(ctx, key, meta, []byte(`{"a":"b"}`)))
+
+		exists, err = b.KeyExists(ctx, key)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		contents, err := b.GetObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{"a":"b"}`), contents)
+
+		head, err := b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, int64(9), head.ContentLength)
+		require.Equal(t, "application/json", head.ContentType)
+		require.Equal(t, meta.Map(), head.Metadata)
+		require.WithinDuration(t, time.Now(), head.LastModified, time.Minute)
+
+		// updating metadata leaves contents and content type untouched
+		meta.CryptoStats = "{}"
+		require.NoError(t, b.UpdateMetadata(ctx, key, meta))
+		contents, err = b.GetObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{"a":"b"}`), contents)
+		head, err = b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "application/json", head.ContentType)
+		require.Equal(t, "{}", head.Metadata[store.MetaCryptoStatsKey])
+	})
+}
+
+func TestBackend_SoftDeleteAndDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+		meta := store.Metadata{Version: "1", CryptoStats: "{}"}
+
+		require.ErrorIs(t, b.UpdateMetadata(ctx, key, meta), store.ErrNotFound)
+		require.NoError(t, b.Upload(ctx, key, meta, []byte(`{}`)))
+
+		// tombstone hides the contents, but the object still exists
+		meta.DeletedAt = "2025-01-01T00:00:00Z"
+		require.NoError(t, b.UpdateMetadata(ctx, key, meta))
+		_, err := b.GetObject(ctx, key)
+		require.ErrorIs(t, err, store.ErrNotFound)
+		head, err := b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.True(t, store.Deleted(head.Metadata))
+		require.Equal(t, meta, store.ParseMetadata(head.Metadata))
+		exists, err := b.KeyExists(ctx, key)
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.ErrorIs(t, b.Upload(ctx, key, meta, []byte(`{}`)), store.ErrAlreadyExists)
+
+		require.NoError(t, b.Delete(ctx, key))
+		exists, err = b.KeyExists(ctx, key)
+		require.NoError(t, err)
+		require.False(t, exists)
+		_, _, err = b.GetObjectVersions(ctx, testURN)
+		require.ErrorIs(t, err, store.ErrNotFound)
+
+		// deleting a missing object is not an error
+		require.NoError(t, b.Delete(ctx, key))
+
+		// purged key can be used again
+		require.NoError(t, b.Upload(ctx, key, store.Metadata{Version: "1"}, []byte(`{}`)))
+	})
+}
+
+func TestBackend_OpenObject(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+		meta := store.Metadata{Version: "1", CryptoStats: "{}"}
+
+		_, err := b.OpenObject(ctx, key)
+		require.ErrorIs(t, err, store.ErrNotFound)
+
+		require.NoError(t, b.Upload(ctx, key, meta, []byte(`{"a":"b"}`)))
+		obj, err := b.OpenObject(ctx, key)
+		require.NoError(t, err)
+		contents, err := io.ReadAll(obj)
+		require.NoError(t, err)
+		require.NoError(t, obj.Close())
+		require.Equal(t, []byte(`{"a":"b"}`), contents)
+		require.Equal(t, int64(9), obj.ContentLength)
+		require.Equal(t, "application/json", obj.ContentType)
+		require.NotEmpty(t, obj.ETag)
+
+		// entity tag of other contents differs
+		require.NoError(t, b.Upload(ctx, testURN+"-2", meta, []byte(`{"a":"cd"}`)))
+		other, err := b.OpenObject(ctx, testURN+"-2")
+		require.NoError(t, err)
+		require.NoError(t, other.Close())
+		require.NotEqual(t, obj.ETag, other.ETag)
+
+		meta.DeletedAt = "2025-01-01T00:00:00Z"
+		require.NoError(t, b.UpdateMetadata(ctx, key, meta))
+		_, err = b.OpenObject(ctx, key)
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestBackend_UploadCreateOnly(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+
+		require.NoError(t, b.Upload(ctx, key, store.Metadata{Version: "1", CryptoStats: "first"}, []byte(`{"a":"b"}`)))
+		err := b.Upload(ctx, key, store.Metadata{Version: "1", CryptoStats: "second"}, []byte(`{}`))
+		require.ErrorIs(t, err, store.ErrAlreadyExists)
+
+		// the existing object is left untouched
+		contents, err := b.GetObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{"a":"b"}`), contents)
+		head, err := b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "first", head.Metadata[store.MetaCryptoStatsKey])
+	})
+}
+
+func TestBackend_UploadStream(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+		contents := strings.Repeat(`{"a":"b"}`, 1000)
+
+		require.NoError(t, b.UploadStream(ctx, key, store.Metadata{Version: "1"}, strings.NewReader(contents)))
+		got, err := b.GetObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, contents, string(got))
+
+		err = b.UploadStream(ctx, key, store.Metadata{Version: "1"}, strings.NewReader(`{}`))
+		require.ErrorIs(t, err, store.ErrAlreadyExists)
+
+		// failing body does not claim the key
+		key = testURN + "-2"
+		err = b.UploadStream(ctx, key, store.Metadata{Version: "2"}, iotest.ErrReader(errors.New("connection reset")))
+		require.Error(t, err)
+		exists, err := b.KeyExists(ctx, key)
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
+
+func TestBackend_ContentType(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+		meta := store.Metadata{Version: "1", ContentType: "application/vnd.cyclonedx+xml"}
+
+		require.NoError(t, b.Upload(ctx, key, meta, []byte(`<bom/>`)))
+		head, err := b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "application/vnd.cyclonedx+xml", head.ContentType)
+		require.Equal(t, meta, store.ParseMetadata(head.Metadata))
+
+		// soft delete keeps the content type
+		meta.DeletedAt = "2025-01-01T00:00:00Z"
+		require.NoError(t, b.UpdateMetadata(ctx, key, meta))
+		head, err = b.GetHeadObject(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, "application/vnd.cyclonedx+xml", head.ContentType)
+	})
+}
+
+func TestBackend_UploadCreateOnlyConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		key := testURN + "-1"
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- b.Upload(ctx, key, store.Metadata{Version: "1"}, []byte(`{}`))
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+				continue
+			}
+			require.ErrorIs(t, err, store.ErrAlreadyExists)
+		}
+		require.Equal(t, 1, succeeded)
+	})
+}
+
+func TestBackend_NotFound(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+
+		_, err := b.GetObject(ctx, testURN+"-1")
+		require.ErrorIs(t, err, store.ErrNotFound)
+
+		_, err = b.GetHeadObject(ctx, testURN+"-1")
+		require.ErrorIs(t, err, store.ErrNotFound)
+
+		_, _, err = b.GetObjectVersions(ctx, testURN)
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
+
+func TestBackend_Search(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		now := time.Now()
+
+		res, err := b.Search(ctx, now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+		require.Empty(t, res)
+
+		require.NoError(t, b.Upload(ctx, testURN+"-1", store.Metadata{Version: "1"}, []byte("{}")))
+		require.NoError(t, b.Upload(ctx, testURN+"-original", store.Metadata{Version: "original"}, []byte("{}")))
+
+		res, err = b.Search(ctx, now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{testURN + "-1", testURN + "-original"}, res)
+
+		res, err = b.Search(ctx, now.Add(time.Hour).Unix())
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
+
+func TestBackend_List(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+		now := time.Now()
+
+		require.NoError(t, b.Upload(ctx, testURN+"-1", store.Metadata{Version: "1"}, []byte("{}")))
+		require.NoError(t, b.Upload(ctx, testURN+"-original", store.Metadata{Version: "original"}, []byte("{}")))
+
+		objects, err := b.List(ctx, now.Add(-time.Hour).Unix())
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		for _, obj := range objects {
+			head, err := b.GetHeadObject(ctx, obj.Key)
+			require.NoError(t, err)
+			require.True(t, head.LastModified.Equal(obj.LastModified), obj.Key)
+			// the head is optional, it must be the actual one if listed
+			if obj.Head != nil {
+				require.Equal(t, head, *obj.Head)
+			}
+		}
+
+		objects, err = b.List(ctx, now.Add(time.Hour).Unix())
+		require.NoError(t, err)
+		require.Empty(t, objects)
+	})
+}
+
+func TestBackend_GetObjectVersions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		ctx := context.Background()
+
+		for _, v := range []string{"10", "2", "original", "1"} {
+			require.NoError(t, b.Upload(ctx, testURN+"-"+v, store.Metadata{Version: v}, []byte("{}")))
+		}
+		// object of another serial number must not be returned
+		require.NoError(t, b.Upload(ctx, "urn:uuid:0b8a2a4e-0d45-4f5e-9c59-1d2b1d3c4e5f-3", store.Metadata{Version: "3"}, []byte("{}")))
+
+		versions, hasOriginal, err := b.GetObjectVersions(ctx, testURN)
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2, 10}, versions)
+		require.True(t, hasOriginal)
+
+		require.NoError(t, b.Upload(ctx, testURN+"-abc", store.Metadata{Version: "abc"}, []byte("{}")))
+		_, _, err = b.GetObjectVersions(ctx, testURN)
+		require.Error(t, err)
+	})
+}
+
+func TestBackend_HealthCheck(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b store.Backend) {
+		require.NoError(t, b.HealthCheck(context.Background()))
+	})
+}
@@ -0,0 +1,141 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+	mockS3 "github.com/CZERTAINLY/CBOM-Repository/internal/store/mock"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStore_UpdateMetadata(t *testing.T) {
+	key := testURN + "-1"
+	meta := store.Metadata{Version: "1", CryptoStats: "{}", DeletedAt: "2025-01-01T00:00:00Z"}
+
+	tests := map[string]struct {
+		setupMock func(*mockS3.MockS3Contract)
+		wantErr   error
+	}{
+		"success": {
+			setupMock: func(m *mockS3.MockS3Contract) {
+				m.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return(&s3.HeadObjectOutput{
+					ContentLength: aws.Int64(2),
+					ContentType:   aws.String("application/vnd.cyclonedx+json"),
+					LastModified:  aws.Time(time.Now()),
+				}, nil)
+				m.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+						require.Equal(t, "bucket", *in.Bucket)
+						require.Equal(t, key, *in.Key)
+						require.Equal(t, "bucket/"+key, *in.CopySource)
+						require.Equal(t, types.MetadataDirectiveReplace, in.MetadataDirective)
+						require.Equal(t, "application/vnd.cyclonedx+json", *in.ContentType)
+						require.Equal(t, meta.Map(), in.Metadata)
+						return &s3.CopyObjectOutput{}, nil
+					})
+			},
+		},
+		"object does not exist": {
+			setupMock: func(m *mockS3.MockS3Contract) {
+				m.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return(nil, &types.NotFound{})
+			},
+			wantErr: store.ErrNotFound,
+		},
+		"object removed before copy": {
+			setupMock: func(m *mockS3.MockS3Contract) {
+				m.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return(&s3.HeadObjectOutput{
+					ContentLength: aws.Int64(2),
+					ContentType:   aws.String("application/json"),
+					LastModified:  aws.Time(time.Now()),
+				}, nil)
+				m.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+			},
+			wantErr: store.ErrNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s3Mock := mockS3.NewMockS3Contract(ctrl)
+			tc.setupMock(s3Mock)
+
+			err := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil).UpdateMetadata(context.Background(), key, meta)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStore_GetObjectSoftDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(&s3.GetObjectOutput{
+		Body:     io.NopCloser(strings.NewReader("{}")),
+		Metadata: store.Metadata{Version: "1", DeletedAt: "2025-01-01T00:00:00Z"}.Map(),
+	}, nil)
+
+	_, err := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil).GetObject(context.Background(), testURN+"-1")
+	require.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestStore_OpenObject(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(&s3.GetObjectOutput{
+		Body:          io.NopCloser(strings.NewReader("{}")),
+		ContentLength: aws.Int64(2),
+		ContentType:   aws.String("application/json"),
+		ETag:          aws.String(`"99914b932bd37a50b983c5e7c90ae93b"`),
+		Metadata:      store.Metadata{Version: "1"}.Map(),
+	}, nil)
+
+	obj, err := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil).OpenObject(context.Background(), testURN+"-1")
+	require.NoError(t, err)
+	defer func() {
+		_ = obj.Close()
+	}()
+	require.Equal(t, int64(2), obj.ContentLength)
+	require.Equal(t, "application/json", obj.ContentType)
+	require.Equal(t, `"99914b932bd37a50b983c5e7c90ae93b"`, obj.ETag)
+	b, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(b))
+}
+
+func TestStore_Delete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key := testURN + "-1"
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Mock.EXPECT().DeleteObject(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			require.Equal(t, "bucket", *in.Bucket)
+			require.Equal(t, key, *in.Key)
+			return &s3.DeleteObjectOutput{}, nil
+		})
+	s3Mock.EXPECT().DeleteObject(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+
+	s := store.New(store.Config{Bucket: "bucket"}, s3Mock, nil)
+	require.NoError(t, s.Delete(context.Background(), key))
+	require.Error(t, s.Delete(context.Background(), key))
+}
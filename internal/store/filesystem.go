@@ -0,0 +1,480 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	fsObjectsDir  = "objects"
+	fsMetadataDir = "metadata"
+	fsTempPattern = ".tmp-*"
+)
+
+type FilesystemConfig struct {
+	Dir string `envconfig:"APP_FS_DIR"`
+}
+
+// Filesystem is a Backend persisting objects as plain files under a local
+// directory, so that the repository can run without an s3-compatible storage.
+//
+// Contents of an object is stored in `<dir>/objects/<name>`, its content type
+// and metadata (version, crypto stats) in a json sidecar file
+// `<dir>/metadata/<name>.json`, where the name is the key encoded by
+// fsFileName. Every file is written into a temporary file first and renamed
+// or hard linked afterwards, so readers never observe a partially written
+// object. LastModified of an object is the modification time of its contents
+// file.
+type Filesystem struct {
+	cfg FilesystemConfig
+}
+
+type fsSidecar struct {
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// NewFilesystem returns a Filesystem backend rooted at `cfg.Dir`, creating
+// the directory layout if it does not exist yet.
+func NewFilesystem(cfg FilesystemConfig) (Filesystem, error) {
+	for _, dir := range []string{fsObjectsDir, fsMetadataDir} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, dir), 0o750); err != nil {
+			return Filesystem{}, fmt.Errorf("creating directory %q failed: %w", dir, err)
+		}
+	}
+	return Filesystem{cfg: cfg}, nil
+}
+
+// Search returns a list of all object keys whose contents file was modified
+// after the specified Unix timestamp.
+func (f Filesystem) Search(ctx context.Context, ts int64) ([]string, error) {
+	objects, err := f.List(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	return objectKeys(objects), nil
+}
+
+// List is like Search, the keys are returned along with the modification
+// time of the contents files, the sidecar files are not read.
+func (f Filesystem) List(ctx context.Context, ts int64) ([]ObjectInfo, error) {
+	keys, err := f.listKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unixTimestamp := time.Unix(ts, 0)
+
+	res := []ObjectInfo{}
+	for _, key := range keys {
+		info, err := os.Stat(f.objectPath(key))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// removed in the meantime
+			continue
+
+		case err != nil:
+			slog.ErrorContext(ctx, "`os.Stat()` failed.", slog.String("error", err.Error()), slog.String("key", key))
+			return nil, errors.New("reading object info failed")
+		}
+		if unixTimestamp.Before(info.ModTime()) {
+			res = append(res, ObjectInfo{Key: key, LastModified: info.ModTime()})
+		}
+	}
+	return res, nil
+}
+
+// GetObjectVersions retrieves all version numbers for a given object URN and
+// indicates whether an original version exists. See Store.GetObjectVersions
+// for the details of the key format invariant.
+func (f Filesystem) GetObjectVersions(ctx context.Context, urn string) ([]int, bool, error) {
+	keys, err := f.listKeys(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var matching []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, urn) {
+			matching = append(matching, key)
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil, false, ErrNotFound
+	}
+	return parseVersions(ctx, urn, matching)
+}
+
+// GetHeadObject retrieves metadata of an object without reading its
+// contents. Returns ErrNotFound if the object does not exist.
+func (f Filesystem) GetHeadObject(ctx context.Context, key string) (HeadObject, error) {
+	if err := fsCheckKey(key); err != nil {
+		return HeadObject{}, err
+	}
+
+	info, err := os.Stat(f.objectPath(key))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return HeadObject{}, ErrNotFound
+
+	case err != nil:
+		slog.ErrorContext(ctx, "`os.Stat()` failed.", slog.String("error", err.Error()))
+		return HeadObject{}, errors.New("`os.Stat()` failed")
+	}
+
+	sidecar, err := f.readSidecar(ctx, key)
+	if err != nil {
+		return HeadObject{}, err
+	}
+
+	return HeadObject{
+		ContentLength: info.Size(),
+		ContentType:   sidecar.ContentType,
+		LastModified:  info.ModTime(),
+		Metadata:      sidecar.Metadata,
+	}, nil
+}
+
+// GetObject retrieves the complete contents of an object. Returns ErrNotFound
+// if the object does not exist or if it is soft deleted.
+func (f Filesystem) GetObject(ctx context.Context, key string) ([]byte, error) {
+	if err := fsCheckKey(key); err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(f.objectPath(key))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, ErrNotFound
+
+	case err != nil:
+		slog.ErrorContext(ctx, "`os.ReadFile()` failed.", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	sidecar, err := f.readSidecar(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if Deleted(sidecar.Metadata) {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// OpenObject opens the contents file of an object for reading, the entity
+// tag is derived from the size and modification time of the file. Returns
+// ErrNotFound if the object does not exist or if it is soft deleted.
+func (f Filesystem) OpenObject(ctx context.Context, key string) (ObjectReader, error) {
+	if err := fsCheckKey(key); err != nil {
+		return ObjectReader{}, err
+	}
+
+	file, err := os.Open(f.objectPath(key))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ObjectReader{}, ErrNotFound
+
+	case err != nil:
+		slog.ErrorContext(ctx, "`os.Open()` failed.", slog.String("error", err.Error()))
+		return ObjectReader{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		slog.ErrorContext(ctx, "`os.File.Stat()` failed.", slog.String("error", err.Error()))
+		return ObjectReader{}, err
+	}
+
+	sidecar, err := f.readSidecar(ctx, key)
+	if err != nil {
+		_ = file.Close()
+		return ObjectReader{}, err
+	}
+	if Deleted(sidecar.Metadata) {
+		_ = file.Close()
+		return ObjectReader{}, ErrNotFound
+	}
+
+	return ObjectReader{
+		ReadCloser:    file,
+		ContentLength: info.Size(),
+		ContentType:   sidecar.ContentType,
+		ETag:          fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// KeyExists checks whether an object with the specified key exists.
+func (f Filesystem) KeyExists(ctx context.Context, key string) (bool, error) {
+	if err := fsCheckKey(key); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(f.objectPath(key))
+	switch {
+	case err == nil:
+		return true, nil
+
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	}
+
+	slog.ErrorContext(ctx, "`os.Stat()` failed.", slog.String("error", err.Error()))
+	return false, err
+}
+
+// Upload stores the contents and metadata of an object under the specified
+// key. It is a shorthand for UploadStream of a byte slice.
+func (f Filesystem) Upload(ctx context.Context, key string, meta Metadata, contents []byte) error {
+	return f.UploadStream(ctx, key, meta, bytes.NewReader(contents))
+}
+
+// UploadStream stores the contents read from `body` and metadata of an object
+// under the specified key. Returns ErrAlreadyExists if the object already
+// exists.
+//
+// The body is copied into a temporary file, the contents file is created by
+// hard linking it once synced, which atomically fails if the file exists, so
+// concurrent uploads of the same key never overwrite each other. The sidecar
+// file is written once the key is claimed, until then GetHeadObject reports
+// the object as not found. If writing the sidecar fails, the contents file is
+// removed again, so that the key can be uploaded later.
+func (f Filesystem) UploadStream(ctx context.Context, key string, meta Metadata, body io.Reader) error {
+	if err := fsCheckKey(key); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(fsSidecar{
+		ContentType: meta.objectContentType(),
+		Metadata:    meta.Map(),
+	})
+	if err != nil {
+		return fmt.Errorf("`json.Marshal()` failed: %w", err)
+	}
+
+	err = createFileAtomic(f.objectPath(key), body)
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		return ErrAlreadyExists
+
+	case err != nil:
+		slog.ErrorContext(ctx, "Writing object file failed.", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := writeFileAtomic(f.metadataPath(key), b); err != nil {
+		slog.ErrorContext(ctx, "Writing metadata sidecar file failed.", slog.String("error", err.Error()))
+		// release the key, an object without sidecar would exist for
+		// KeyExists, but not for GetHeadObject
+		if err := os.Remove(f.objectPath(key)); err != nil {
+			slog.ErrorContext(ctx, "Removing object file without sidecar failed.", slog.String("error", err.Error()))
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateMetadata replaces metadata of an existing object by rewriting its
+// sidecar file, the contents file and its modification time are left
+// untouched. Returns ErrNotFound if the object does not exist.
+func (f Filesystem) UpdateMetadata(ctx context.Context, key string, meta Metadata) error {
+	if err := fsCheckKey(key); err != nil {
+		return err
+	}
+
+	exists, err := f.KeyExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	sidecar, err := f.readSidecar(ctx, key)
+	if err != nil {
+		return err
+	}
+	sidecar.Metadata = meta.Map()
+
+	b, err := json.Marshal(sidecar)
+	if err != nil {
+		return fmt.Errorf("`json.Marshal()` failed: %w", err)
+	}
+
+	if err := writeFileAtomic(f.metadataPath(key), b); err != nil {
+		slog.ErrorContext(ctx, "Writing metadata sidecar file failed.", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// Delete removes the contents file of an object followed by its sidecar file,
+// removing a missing object is not an error.
+func (f Filesystem) Delete(ctx context.Context, key string) error {
+	if err := fsCheckKey(key); err != nil {
+		return err
+	}
+
+	for _, name := range []string{f.objectPath(key), f.metadataPath(key)} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.ErrorContext(ctx, "`os.Remove()` failed.", slog.String("error", err.Error()))
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Filesystem) HealthCheck(ctx context.Context) error {
+	info, err := os.Stat(filepath.Join(f.cfg.Dir, fsObjectsDir))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", info.Name())
+	}
+	return nil
+}
+
+func (f Filesystem) objectPath(key string) string {
+	return filepath.Join(f.cfg.Dir, fsObjectsDir, fsFileName(key))
+}
+
+func (f Filesystem) metadataPath(key string) string {
+	return filepath.Join(f.cfg.Dir, fsMetadataDir, fsFileName(key)+".json")
+}
+
+// fsFileName encodes a key into a file name valid on all platforms, the `:`
+// of `urn:uuid:` keys is not allowed on Windows. Bytes other than ASCII
+// letters, digits, `-`, `_` and `.` are percent-encoded as in URLs.
+func fsFileName(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// listKeys returns keys of all objects, temporary files and files whose name
+// is not an encoded key are skipped.
+func (f Filesystem) listKeys(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(f.cfg.Dir, fsObjectsDir))
+	if err != nil {
+		slog.ErrorContext(ctx, "`os.ReadDir()` failed.", slog.String("error", err.Error()))
+		return nil, errors.New("listing objects failed")
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		key, err := url.PathUnescape(entry.Name())
+		if err != nil || fsFileName(key) != entry.Name() {
+			slog.WarnContext(ctx, "Skipping a file which is not an object.", slog.String("name", entry.Name()))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (f Filesystem) readSidecar(ctx context.Context, key string) (fsSidecar, error) {
+	b, err := os.ReadFile(f.metadataPath(key))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		slog.WarnContext(ctx, "Object exists, but its metadata sidecar file does not.", slog.String("key", key))
+		return fsSidecar{}, ErrNotFound
+
+	case err != nil:
+		slog.ErrorContext(ctx, "`os.ReadFile()` failed.", slog.String("error", err.Error()))
+		return fsSidecar{}, err
+	}
+
+	var sidecar fsSidecar
+	if err := json.Unmarshal(b, &sidecar); err != nil {
+		slog.ErrorContext(ctx, "Unmarshaling metadata sidecar file failed.", slog.String("error", err.Error()), slog.String("key", key))
+		return fsSidecar{}, errors.New("unmarshaling json failed")
+	}
+	return sidecar, nil
+}
+
+// fsCheckKey returns error if the key could escape the objects directory or
+// clash with temporary files.
+func fsCheckKey(key string) error {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data into a temporary file in the directory of
+// `name` and renames it to `name` once the data is synced to disk.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := writeTempFile(filepath.Dir(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// createFileAtomic is like writeFileAtomic, but it never replaces an existing
+// file and the data is read from `r`. The returned error matches fs.ErrExist
+// if `name` already exists.
+func createFileAtomic(name string, r io.Reader) error {
+	tmp, err := writeTempFile(filepath.Dir(name), r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+	return os.Link(tmp, name)
+}
+
+// writeTempFile copies data from `r` into a new temporary file in `dir` and
+// returns its name once the data is synced to disk.
+func writeTempFile(dir string, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(dir, fsTempPattern)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
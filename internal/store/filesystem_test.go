@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystem_InvalidKey(t *testing.T) {
+	ctx := context.Background()
+	fs, err := store.NewFilesystem(store.FilesystemConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../escape", "a/b", ".tmp-123"} {
+		require.Error(t, fs.Upload(ctx, key, store.Metadata{}, []byte("{}")), key)
+		_, err := fs.GetObject(ctx, key)
+		require.Error(t, err, key)
+		require.NotErrorIs(t, err, store.ErrNotFound, key)
+	}
+}
+
+func TestFilesystem_UploadSidecarFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs, err := store.NewFilesystem(store.FilesystemConfig{Dir: dir})
+	require.NoError(t, err)
+
+	// a non-empty directory in place of the sidecar makes writing it fail
+	sidecar := filepath.Join(dir, "metadata", strings.ReplaceAll(testURN, ":", "%3A")+"-1.json")
+	require.NoError(t, os.MkdirAll(filepath.Join(sidecar, "blocker"), 0o700))
+
+	require.Error(t, fs.Upload(ctx, testURN+"-1", store.Metadata{Version: "1"}, []byte("{}")))
+	exists, err := fs.KeyExists(ctx, testURN+"-1")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// the key is free once the sidecar can be written
+	require.NoError(t, os.RemoveAll(sidecar))
+	require.NoError(t, fs.Upload(ctx, testURN+"-1", store.Metadata{Version: "1"}, []byte("{}")))
+	head, err := fs.GetHeadObject(ctx, testURN+"-1")
+	require.NoError(t, err)
+	require.Equal(t, "1", head.Metadata[store.MetaVersionKey])
+}
+
+func TestFilesystem_FileNames(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs, err := store.NewFilesystem(store.FilesystemConfig{Dir: dir})
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Upload(ctx, testURN+"-1", store.Metadata{Version: "1"}, []byte("{}")))
+	require.NoError(t, fs.Upload(ctx, testURN+"-original", store.Metadata{Version: "1"}, []byte("{}")))
+
+	// file names are valid on Windows, the keys are decoded when listed
+	for _, sub := range []string{"objects", "metadata"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		for _, entry := range entries {
+			require.NotContains(t, entry.Name(), ":")
+		}
+	}
+	versions, original, err := fs.GetObjectVersions(ctx, testURN)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, versions)
+	require.True(t, original)
+
+	// files which are not encoded keys are skipped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "objects", "%zz"), []byte("{}"), 0o600))
+	keys, err := fs.Search(ctx, 0)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{testURN + "-1", testURN + "-original"}, keys)
+}
@@ -124,6 +124,21 @@ func TestStore_GetHeadObject(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "success - missing optional fields",
+			key:  "sparse-key",
+			setupMock: func(m *mockS3.MockS3Contract) {
+				m.EXPECT().
+					HeadObject(gomock.Any(), gomock.Any()).
+					Return(&s3.HeadObjectOutput{
+						Metadata: testMetadata,
+					}, nil)
+			},
+			wantHead: store.HeadObject{
+				Metadata: testMetadata,
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
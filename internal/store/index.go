@@ -0,0 +1,327 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrIndexLocked is returned by OpenIndex when the journal is used by another
+// process, e.g. by the running service when the `reindex` command is started.
+var ErrIndexLocked = errors.New("index journal is locked by another process")
+
+type IndexConfig struct {
+	// File is the path of the metadata index journal, indexing is disabled
+	// if empty.
+	File string `envconfig:"APP_INDEX_FILE"`
+	// SingleWriter confirms that a single service instance writes into the
+	// s3 bucket, the index is local to the instance and misses changes made
+	// by others. The index is refused with the s3 backend unless set.
+	SingleWriter bool `envconfig:"APP_INDEX_SINGLE_WRITER"`
+}
+
+// Index is a persistent metadata index of stored objects. For every object
+// it keeps the head information (size, content type, last modified time and
+// metadata such as version and crypto stats), so listing and searching does
+// not need to touch the backend storage at all.
+//
+// The index lives in memory and is persisted in a journal file with one json
+// record per line. Every change is appended to the journal and synced to
+// disk, the journal is compacted when the index is opened. An incomplete last
+// record, left behind by a crash while appending it, is dropped on open.
+//
+// The journal is replaced on compaction, so a single process may use it at a
+// time, this is enforced by an exclusive lock of the `<journal>.lock` file,
+// which is held until the index is closed.
+//
+// The index only sees changes made through the process using it. Objects
+// uploaded or removed by other processes, e.g. by other replicas sharing an
+// s3 bucket, are missing from search and version listing without any error
+// until the index is reconciled, see Indexed.Reindex.
+type Index struct {
+	mu      sync.RWMutex
+	lock    *os.File
+	file    *os.File
+	entries map[string]HeadObject
+}
+
+type indexRecord struct {
+	Key           string            `json:"key"`
+	ContentLength int64             `json:"contentLength"`
+	ContentType   string            `json:"contentType"`
+	LastModified  time.Time         `json:"lastModified"`
+	Metadata      map[string]string `json:"metadata"`
+	// Removed marks a record removing the object from the index.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// OpenIndex loads the index from the journal file at `path`, creating an empty
+// one if it does not exist, and compacts the journal. It returns
+// ErrIndexLocked if the journal is used by another process.
+func OpenIndex(path string) (*Index, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+		if errors.Is(err, ErrIndexLocked) {
+			return nil, fmt.Errorf("%w: %s", err, path)
+		}
+		return nil, fmt.Errorf("locking index journal %s failed: %w", path, err)
+	}
+
+	idx, err := openIndex(path)
+	if err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	idx.lock = lock
+	return idx, nil
+}
+
+func openIndex(path string) (*Index, error) {
+	entries := make(map[string]HeadObject)
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// brand new index
+
+	case err != nil:
+		return nil, err
+
+	default:
+		r := bufio.NewReader(f)
+		line := 0
+		for {
+			b, err := r.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				_ = f.Close()
+				return nil, fmt.Errorf("reading index journal %s failed: %w", path, err)
+			}
+			torn := errors.Is(err, io.EOF)
+			if torn && len(b) == 0 {
+				break
+			}
+			line++
+
+			var rec indexRecord
+			if err := json.Unmarshal(b, &rec); err != nil {
+				if torn {
+					// every record is appended along with its line break, the
+					// last record is incomplete if the service crashed while
+					// appending it, the change was never acknowledged, so it
+					// is dropped and the compaction below removes it
+					slog.Warn("Dropping incomplete last record of index journal.",
+						slog.String("path", path), slog.Int("line", line), slog.String("error", err.Error()))
+					break
+				}
+				_ = f.Close()
+				return nil, fmt.Errorf("index journal %s line %d is malformed: %w", path, line, err)
+			}
+			if rec.Removed {
+				delete(entries, rec.Key)
+			} else {
+				entries[rec.Key] = HeadObject{
+					ContentLength: rec.ContentLength,
+					ContentType:   rec.ContentType,
+					LastModified:  rec.LastModified,
+					Metadata:      rec.Metadata,
+				}
+			}
+			if torn {
+				break
+			}
+		}
+		_ = f.Close()
+	}
+
+	idx := &Index{entries: entries}
+	if err := idx.compact(path); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// compact rewrites the journal with a single record per entry and reopens it
+// for appending.
+func (i *Index) compact(path string) error {
+	var b []byte
+	for _, key := range slices.Sorted(maps.Keys(i.entries)) {
+		line, err := json.Marshal(newIndexRecord(key, i.entries[key]))
+		if err != nil {
+			return fmt.Errorf("`json.Marshal()` failed: %w", err)
+		}
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+	if err := writeFileAtomic(path, b); err != nil {
+		return fmt.Errorf("compacting index journal %s failed: %w", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	i.file = f
+	return nil
+}
+
+// Close closes the journal file and releases its lock, the index must not be
+// used afterwards.
+func (i *Index) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	err := i.file.Close()
+	if lockErr := i.lock.Close(); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// Len returns the number of indexed objects.
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.entries)
+}
+
+// Put adds or replaces the entry of an object, the change is persisted
+// before it becomes visible.
+func (i *Index) Put(key string, head HeadObject) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.appendRecord(newIndexRecord(key, head)); err != nil {
+		return err
+	}
+	head.Metadata = maps.Clone(head.Metadata)
+	i.entries[key] = head
+	return nil
+}
+
+// Remove removes the entry of an object, removing a key which is not indexed
+// is a no-op.
+func (i *Index) Remove(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.entries[key]; !ok {
+		return nil
+	}
+	if err := i.appendRecord(indexRecord{Key: key, Removed: true}); err != nil {
+		return err
+	}
+	delete(i.entries, key)
+	return nil
+}
+
+// appendRecord writes the record at the end of the journal, caller must hold the
+// write lock.
+func (i *Index) appendRecord(rec indexRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("`json.Marshal()` failed: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := i.file.Write(line); err != nil {
+		return fmt.Errorf("appending to index journal failed: %w", err)
+	}
+	if err := i.file.Sync(); err != nil {
+		return fmt.Errorf("syncing index journal failed: %w", err)
+	}
+	return nil
+}
+
+// Get returns the entry of an object and whether it is indexed.
+func (i *Index) Get(key string) (HeadObject, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	head, ok := i.entries[key]
+	if !ok {
+		return HeadObject{}, false
+	}
+	head.Metadata = maps.Clone(head.Metadata)
+	return head, true
+}
+
+// Keys returns sorted keys of all indexed objects.
+func (i *Index) Keys() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return slices.Sorted(maps.Keys(i.entries))
+}
+
+// Search returns sorted keys of all objects modified after the Unix timestamp.
+func (i *Index) Search(ts int64) []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	unixTimestamp := time.Unix(ts, 0)
+
+	res := []string{}
+	for key, head := range i.entries {
+		if unixTimestamp.Before(head.LastModified) {
+			res = append(res, key)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// List returns all objects modified after the Unix timestamp along with
+// their entries, sorted by key.
+func (i *Index) List(ts int64) []ObjectInfo {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	unixTimestamp := time.Unix(ts, 0)
+
+	res := []ObjectInfo{}
+	for _, key := range slices.Sorted(maps.Keys(i.entries)) {
+		head := i.entries[key]
+		if unixTimestamp.Before(head.LastModified) {
+			head.Metadata = maps.Clone(head.Metadata)
+			res = append(res, ObjectInfo{Key: key, LastModified: head.LastModified, Head: &head})
+		}
+	}
+	return res
+}
+
+// KeysWithPrefix returns sorted keys of all objects starting with `prefix`.
+func (i *Index) KeysWithPrefix(prefix string) []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var res []string
+	for key := range i.entries {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, key)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func newIndexRecord(key string, head HeadObject) indexRecord {
+	return indexRecord{
+		Key:           key,
+		ContentLength: head.ContentLength,
+		ContentType:   head.ContentType,
+		LastModified:  head.LastModified,
+		Metadata:      head.Metadata,
+	}
+}
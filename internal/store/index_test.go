@@ -0,0 +1,126 @@
+package store_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+	ts := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	idx, err := store.OpenIndex(path)
+	require.NoError(t, err)
+	require.Equal(t, 0, idx.Len())
+
+	head := store.HeadObject{
+		ContentLength: 42,
+		ContentType:   "application/json",
+		LastModified:  ts,
+		Metadata:      store.Metadata{Version: "1", CryptoStats: "{}"}.Map(),
+	}
+	require.NoError(t, idx.Put(testURN+"-1", head))
+	require.NoError(t, idx.Put(testURN+"-2", head))
+	// replacing an entry appends a new journal record
+	head.ContentLength = 43
+	require.NoError(t, idx.Put(testURN+"-2", head))
+	require.NoError(t, idx.Close())
+
+	idx, err = store.OpenIndex(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+	require.Equal(t, 2, idx.Len())
+
+	got, ok := idx.Get(testURN + "-2")
+	require.True(t, ok)
+	require.Equal(t, int64(43), got.ContentLength)
+	require.True(t, ts.Equal(got.LastModified))
+	require.Equal(t, head.Metadata, got.Metadata)
+
+	_, ok = idx.Get(testURN + "-3")
+	require.False(t, ok)
+
+	require.Equal(t, []string{testURN + "-1", testURN + "-2"}, idx.KeysWithPrefix(testURN))
+	require.Equal(t, []string{testURN + "-1", testURN + "-2"}, idx.Search(ts.Unix()-1))
+	require.Empty(t, idx.Search(ts.Unix()))
+
+	// the journal was compacted on open
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, bytes.Count(b, []byte("\n")))
+}
+
+func TestIndex_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"key\":\"a\"}\nnot json\n"), 0o600))
+
+	_, err := store.OpenIndex(path)
+	require.Error(t, err)
+}
+
+func TestIndex_TornLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+	// crash while appending the second record, its line break was never written
+	journal := "{\"key\":\"" + testURN + "-1\",\"metadata\":{\"version\":\"1\"}}\n{\"key\":\"" + testURN + "-2\",\"meta"
+	require.NoError(t, os.WriteFile(path, []byte(journal), 0o600))
+
+	idx, err := store.OpenIndex(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{testURN + "-1"}, idx.Keys())
+	require.NoError(t, idx.Put(testURN+"-3", store.HeadObject{}))
+	require.NoError(t, idx.Close())
+
+	// the torn record was compacted away, later records are readable
+	idx, err = store.OpenIndex(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+	require.Equal(t, []string{testURN + "-1", testURN + "-3"}, idx.Keys())
+}
+
+func TestIndex_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+
+	idx, err := store.OpenIndex(path)
+	require.NoError(t, err)
+
+	_, err = store.OpenIndex(path)
+	require.ErrorIs(t, err, store.ErrIndexLocked)
+
+	// the lock is released on close
+	require.NoError(t, idx.Close())
+	idx, err = store.OpenIndex(path)
+	require.NoError(t, err)
+	require.NoError(t, idx.Close())
+}
+
+func TestIndex_Remove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.jsonl")
+
+	idx, err := store.OpenIndex(path)
+	require.NoError(t, err)
+	require.NoError(t, idx.Put(testURN+"-1", store.HeadObject{}))
+	require.NoError(t, idx.Put(testURN+"-2", store.HeadObject{}))
+	require.NoError(t, idx.Remove(testURN+"-1"))
+	// removing a key which is not indexed is a no-op
+	require.NoError(t, idx.Remove(testURN+"-3"))
+	require.Equal(t, []string{testURN + "-2"}, idx.Keys())
+	require.NoError(t, idx.Close())
+
+	idx, err = store.OpenIndex(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+	require.Equal(t, []string{testURN + "-2"}, idx.Keys())
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+)
+
+// Indexed is a Backend decorator answering Search, GetObjectVersions and
+// GetHeadObject from the metadata Index instead of the wrapped backend. Every
+// Upload goes to the wrapped backend first and updates the index afterwards.
+//
+// Contents of objects is always read from the wrapped backend, as well as the
+// answer to KeyExists, so the index drifting from the backend never results
+// in an object being overwritten.
+type Indexed struct {
+	backend Backend
+	index   *Index
+}
+
+// Reindexer is implemented by backends maintaining a metadata index.
+type Reindexer interface {
+	Reindex(ctx context.Context, fix bool, complete CompleteHeadFunc) (ReindexReport, error)
+}
+
+var _ Reindexer = Indexed{}
+
+// CompleteHeadFunc is called by Reindex for every head read from the wrapped
+// backend before it is compared with the index, allowing the caller to
+// complete metadata which the backend does not have, e.g. for objects written
+// by other tools.
+type CompleteHeadFunc func(ctx context.Context, key string, head HeadObject) (HeadObject, error)
+
+// ReindexReport lists keys of objects whose index entry differs from the
+// wrapped backend.
+type ReindexReport struct {
+	// Scanned is the number of objects found in the wrapped backend.
+	Scanned int `json:"scanned"`
+	// Missing objects exist in the wrapped backend, but not in the index.
+	Missing []string `json:"missing"`
+	// Stale objects have index entry differing from the wrapped backend.
+	Stale []string `json:"stale"`
+	// Orphaned objects exist in the index, but not in the wrapped backend.
+	Orphaned []string `json:"orphaned"`
+	// Fixed is true if the differences were fixed in the index.
+	Fixed bool `json:"fixed"`
+}
+
+func NewIndexed(backend Backend, index *Index) Indexed {
+	return Indexed{
+		backend: backend,
+		index:   index,
+	}
+}
+
+// Build adds every object of the wrapped backend into the index, it is meant
+// to populate a brand new index.
+func (i Indexed) Build(ctx context.Context) error {
+	keys, err := i.backend.Search(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		head, err := i.backend.GetHeadObject(ctx, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			continue
+
+		case err != nil:
+			return err
+		}
+
+		if err := i.index.Put(key, head); err != nil {
+			return err
+		}
+	}
+	slog.InfoContext(ctx, "Metadata index built.", slog.Int("count", len(keys)))
+	return nil
+}
+
+// Reindex walks all objects of the wrapped backend and compares their heads
+// with the index, reporting missing, stale and orphaned index entries. If
+// `fix` is true, the index is updated to match the wrapped backend.
+func (i Indexed) Reindex(ctx context.Context, fix bool, complete CompleteHeadFunc) (ReindexReport, error) {
+	report := ReindexReport{
+		Missing:  []string{},
+		Stale:    []string{},
+		Orphaned: []string{},
+		Fixed:    fix,
+	}
+
+	keys, err := i.backend.Search(ctx, 0)
+	if err != nil {
+		return ReindexReport{}, err
+	}
+	report.Scanned = len(keys)
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+
+		head, err := i.backend.GetHeadObject(ctx, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// removed in the meantime
+			continue
+
+		case err != nil:
+			return ReindexReport{}, err
+		}
+
+		if complete != nil {
+			if head, err = complete(ctx, key, head); err != nil {
+				return ReindexReport{}, err
+			}
+		}
+
+		indexed, ok := i.index.Get(key)
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, key)
+
+		case !headEqual(indexed, head):
+			report.Stale = append(report.Stale, key)
+
+		default:
+			continue
+		}
+
+		if fix {
+			if err := i.index.Put(key, head); err != nil {
+				return ReindexReport{}, err
+			}
+		}
+	}
+
+	for _, key := range i.index.Keys() {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		// the object may have been uploaded after the backend was listed
+		exists, err := i.backend.KeyExists(ctx, key)
+		if err != nil {
+			return ReindexReport{}, err
+		}
+		if exists {
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, key)
+		if fix {
+			if err := i.index.Remove(key); err != nil {
+				return ReindexReport{}, err
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "Metadata index reconciled.",
+		slog.Int("scanned", report.Scanned),
+		slog.Int("missing", len(report.Missing)),
+		slog.Int("stale", len(report.Stale)),
+		slog.Int("orphaned", len(report.Orphaned)),
+		slog.Bool("fixed", report.Fixed),
+	)
+	return report, nil
+}
+
+func headEqual(a, b HeadObject) bool {
+	return a.ContentLength == b.ContentLength &&
+		a.ContentType == b.ContentType &&
+		a.LastModified.Equal(b.LastModified) &&
+		maps.Equal(a.Metadata, b.Metadata)
+}
+
+func (i Indexed) Search(_ context.Context, ts int64) ([]string, error) {
+	return i.index.Search(ts), nil
+}
+
+// List returns the indexed objects along with their heads, the wrapped
+// backend is not called.
+func (i Indexed) List(_ context.Context, ts int64) ([]ObjectInfo, error) {
+	return i.index.List(ts), nil
+}
+
+func (i Indexed) GetObjectVersions(ctx context.Context, urn string) ([]int, bool, error) {
+	keys := i.index.KeysWithPrefix(urn)
+	if len(keys) == 0 {
+		return nil, false, ErrNotFound
+	}
+	return parseVersions(ctx, urn, keys)
+}
+
+// GetHeadObject returns the indexed entry of the object, falling back to the
+// wrapped backend for objects missing in the index.
+func (i Indexed) GetHeadObject(ctx context.Context, key string) (HeadObject, error) {
+	if head, ok := i.index.Get(key); ok {
+		return head, nil
+	}
+	slog.DebugContext(ctx, "Object not indexed, calling wrapped backend.", slog.String("key", key))
+	return i.backend.GetHeadObject(ctx, key)
+}
+
+func (i Indexed) GetObject(ctx context.Context, key string) ([]byte, error) {
+	return i.backend.GetObject(ctx, key)
+}
+
+func (i Indexed) OpenObject(ctx context.Context, key string) (ObjectReader, error) {
+	return i.backend.OpenObject(ctx, key)
+}
+
+func (i Indexed) KeyExists(ctx context.Context, key string) (bool, error) {
+	return i.backend.KeyExists(ctx, key)
+}
+
+// Upload stores the object in the wrapped backend and indexes its head as
+// reported by the backend, so that the index carries the very same last
+// modified time. The index is left untouched if the wrapped backend rejects
+// the upload, e.g. with ErrAlreadyExists.
+func (i Indexed) Upload(ctx context.Context, key string, meta Metadata, contents []byte) error {
+	if err := i.backend.Upload(ctx, key, meta, contents); err != nil {
+		return err
+	}
+	return i.indexHead(ctx, key)
+}
+
+// UploadStream is like Upload, the body is streamed into the wrapped backend.
+func (i Indexed) UploadStream(ctx context.Context, key string, meta Metadata, body io.Reader) error {
+	if err := i.backend.UploadStream(ctx, key, meta, body); err != nil {
+		return err
+	}
+	return i.indexHead(ctx, key)
+}
+
+// indexHead puts the head of a freshly uploaded object into the index.
+func (i Indexed) indexHead(ctx context.Context, key string) error {
+	head, err := i.backend.GetHeadObject(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Object uploaded, but reading its head for the metadata index failed.",
+			slog.String("error", err.Error()), slog.String("key", key))
+		return err
+	}
+
+	if err := i.index.Put(key, head); err != nil {
+		slog.ErrorContext(ctx, "Object uploaded, but updating the metadata index failed.",
+			slog.String("error", err.Error()), slog.String("key", key))
+		return err
+	}
+	return nil
+}
+
+// UpdateMetadata replaces metadata of the object in the wrapped backend and
+// re-indexes its head.
+func (i Indexed) UpdateMetadata(ctx context.Context, key string, meta Metadata) error {
+	if err := i.backend.UpdateMetadata(ctx, key, meta); err != nil {
+		return err
+	}
+
+	head, err := i.backend.GetHeadObject(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Metadata updated, but reading the object head for the metadata index failed.",
+			slog.String("error", err.Error()), slog.String("key", key))
+		return err
+	}
+
+	if err := i.index.Put(key, head); err != nil {
+		slog.ErrorContext(ctx, "Metadata updated, but updating the metadata index failed.",
+			slog.String("error", err.Error()), slog.String("key", key))
+		return err
+	}
+	return nil
+}
+
+// Delete removes the object from the wrapped backend and from the index.
+func (i Indexed) Delete(ctx context.Context, key string) error {
+	if err := i.backend.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	if err := i.index.Remove(key); err != nil {
+		slog.ErrorContext(ctx, "Object deleted, but updating the metadata index failed.",
+			slog.String("error", err.Error()), slog.String("key", key))
+		return err
+	}
+	return nil
+}
+
+func (i Indexed) HealthCheck(ctx context.Context) error {
+	return i.backend.HealthCheck(ctx)
+}
@@ -0,0 +1,152 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+	mockS3 "github.com/CZERTAINLY/CBOM-Repository/internal/store/mock"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIndexed_AnswersFromIndex(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key := testURN + "-1"
+	now := time.Now().UTC()
+	meta := store.Metadata{Version: "1", CryptoStats: "{}"}
+
+	s3Mock := mockS3.NewMockS3Contract(ctrl)
+	s3Manager := mockS3.NewMockS3Manager(ctrl)
+	// the only calls reaching s3 are the upload itself and reading its head
+	s3Manager.EXPECT().UploadObject(gomock.Any(), gomock.Any()).Return(nil, nil).Times(1)
+	s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(2),
+		ContentType:   aws.String("application/json"),
+		LastModified:  aws.Time(now),
+		Metadata:      meta.Map(),
+	}, nil).Times(1)
+
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	indexed := store.NewIndexed(store.New(store.Config{Bucket: "bucket"}, s3Mock, s3Manager), idx)
+	require.NoError(t, indexed.Upload(ctx, key, meta, []byte("{}")))
+
+	keys, err := indexed.Search(ctx, now.Unix()-1)
+	require.NoError(t, err)
+	require.Equal(t, []string{key}, keys)
+
+	versions, hasOriginal, err := indexed.GetObjectVersions(ctx, testURN)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, versions)
+	require.False(t, hasOriginal)
+
+	head, err := indexed.GetHeadObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), head.ContentLength)
+	require.Equal(t, meta.Map(), head.Metadata)
+
+	_, _, err = indexed.GetObjectVersions(ctx, "urn:uuid:0b8a2a4e-0d45-4f5e-9c59-1d2b1d3c4e5f")
+	require.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestIndexed_Build(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+	for _, v := range []string{"1", "2", "original"} {
+		require.NoError(t, backend.Upload(ctx, testURN+"-"+v, store.Metadata{Version: v, CryptoStats: "{}"}, []byte("{}")))
+	}
+
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	indexed := store.NewIndexed(backend, idx)
+	require.NoError(t, indexed.Build(ctx))
+	require.Equal(t, 3, idx.Len())
+
+	versions, hasOriginal, err := indexed.GetObjectVersions(ctx, testURN)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, versions)
+	require.True(t, hasOriginal)
+}
+
+func TestIndexed_Reindex(t *testing.T) {
+	ctx := context.Background()
+	backend := store.NewMemory()
+
+	idx, err := store.OpenIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	require.NoError(t, err)
+	defer func() {
+		_ = idx.Close()
+	}()
+	indexed := store.NewIndexed(backend, idx)
+
+	// consistent
+	require.NoError(t, indexed.Upload(ctx, testURN+"-1", store.Metadata{Version: "1", CryptoStats: "{}"}, []byte("{}")))
+	// stale, index entry differs from the object
+	require.NoError(t, indexed.Upload(ctx, testURN+"-2", store.Metadata{Version: "2", CryptoStats: "{}"}, []byte("{}")))
+	require.NoError(t, idx.Put(testURN+"-2", store.HeadObject{ContentLength: 42, LastModified: time.Now()}))
+	// missing, object was written by another tool
+	require.NoError(t, backend.Upload(ctx, testURN+"-3", store.Metadata{Version: "3"}, []byte("{}")))
+	// orphaned, object does not exist in the backend
+	require.NoError(t, idx.Put(testURN+"-4", store.HeadObject{LastModified: time.Now()}))
+
+	completed := 0
+	complete := func(_ context.Context, key string, head store.HeadObject) (store.HeadObject, error) {
+		if head.Metadata[store.MetaCryptoStatsKey] == "" {
+			completed++
+			head.Metadata[store.MetaCryptoStatsKey] = "{}"
+		}
+		return head, nil
+	}
+
+	report, err := indexed.Reindex(ctx, false, complete)
+	require.NoError(t, err)
+	require.Equal(t, store.ReindexReport{
+		Scanned:  3,
+		Missing:  []string{testURN + "-3"},
+		Stale:    []string{testURN + "-2"},
+		Orphaned: []string{testURN + "-4"},
+		Fixed:    false,
+	}, report)
+	require.Equal(t, 1, completed)
+
+	// dry run does not touch the index
+	require.Equal(t, 3, idx.Len())
+	_, ok := idx.Get(testURN + "-3")
+	require.False(t, ok)
+
+	report, err = indexed.Reindex(ctx, true, complete)
+	require.NoError(t, err)
+	require.True(t, report.Fixed)
+	require.Len(t, report.Missing, 1)
+	require.Len(t, report.Stale, 1)
+	require.Len(t, report.Orphaned, 1)
+
+	head, ok := idx.Get(testURN + "-3")
+	require.True(t, ok)
+	require.Equal(t, "{}", head.Metadata[store.MetaCryptoStatsKey])
+	_, ok = idx.Get(testURN + "-4")
+	require.False(t, ok)
+
+	report, err = indexed.Reindex(ctx, true, complete)
+	require.NoError(t, err)
+	require.Empty(t, report.Missing)
+	require.Empty(t, report.Stale)
+	require.Empty(t, report.Orphaned)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// lockFile is a no-op on platforms without flock, the journal is not
+// protected from concurrent use there.
+func lockFile(_ *os.File) error {
+	return nil
+}
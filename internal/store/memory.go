@@ -0,0 +1,210 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a thread-safe Backend keeping all objects in memory. Contents is
+// lost once the process exits, it is meant for tests and ephemeral demo
+// deployments without any external dependency.
+//
+// Copies of Memory share the same underlying objects.
+type Memory struct {
+	mu      *sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	contents     []byte
+	contentType  string
+	lastModified time.Time
+	metadata     map[string]string
+}
+
+func NewMemory() Memory {
+	return Memory{
+		mu:      &sync.RWMutex{},
+		objects: make(map[string]memoryObject),
+	}
+}
+
+// Search returns a sorted list of all object keys that were modified after
+// the specified Unix timestamp.
+func (m Memory) Search(ctx context.Context, ts int64) ([]string, error) {
+	objects, err := m.List(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	return objectKeys(objects), nil
+}
+
+// List is like Search, the objects are returned along with their heads.
+func (m Memory) List(_ context.Context, ts int64) ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	unixTimestamp := time.Unix(ts, 0)
+
+	res := []ObjectInfo{}
+	for _, key := range slices.Sorted(maps.Keys(m.objects)) {
+		obj := m.objects[key]
+		if unixTimestamp.Before(obj.lastModified) {
+			head := obj.head()
+			res = append(res, ObjectInfo{Key: key, LastModified: obj.lastModified, Head: &head})
+		}
+	}
+	return res, nil
+}
+
+// GetObjectVersions retrieves all version numbers for a given object URN and
+// indicates whether an original version exists. See Store.GetObjectVersions
+// for the details of the key format invariant.
+func (m Memory) GetObjectVersions(ctx context.Context, urn string) ([]int, bool, error) {
+	m.mu.RLock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, urn) {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(keys) == 0 {
+		return nil, false, ErrNotFound
+	}
+	return parseVersions(ctx, urn, keys)
+}
+
+// GetHeadObject retrieves metadata of an object. Returns ErrNotFound if the
+// object does not exist.
+func (m Memory) GetHeadObject(_ context.Context, key string) (HeadObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return HeadObject{}, ErrNotFound
+	}
+	return obj.head(), nil
+}
+
+// head returns the head of the object, caller must hold the lock.
+func (obj memoryObject) head() HeadObject {
+	return HeadObject{
+		ContentLength: int64(len(obj.contents)),
+		ContentType:   obj.contentType,
+		LastModified:  obj.lastModified,
+		Metadata:      maps.Clone(obj.metadata),
+	}
+}
+
+// GetObject retrieves a copy of the contents of an object. Returns ErrNotFound
+// if the object does not exist or if it is soft deleted.
+func (m Memory) GetObject(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok || Deleted(obj.metadata) {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(obj.contents), nil
+}
+
+// OpenObject returns a reader of the stored contents of an object, the reader
+// copies the contents into the buffers of its caller, so the stored slice is
+// never handed out. The entity tag is the sha256 checksum of the contents.
+// Returns ErrNotFound if the object does not exist or if it is soft deleted.
+func (m Memory) OpenObject(ctx context.Context, key string) (ObjectReader, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+
+	if !ok || Deleted(obj.metadata) {
+		return ObjectReader{}, ErrNotFound
+	}
+
+	// stored contents is never modified, so it can be read without the lock
+	sum := sha256.Sum256(obj.contents)
+	return ObjectReader{
+		ReadCloser:    io.NopCloser(bytes.NewReader(obj.contents)),
+		ContentLength: int64(len(obj.contents)),
+		ContentType:   obj.contentType,
+		ETag:          fmt.Sprintf("%q", hex.EncodeToString(sum[:])),
+	}, nil
+}
+
+// KeyExists checks whether an object with the specified key exists.
+func (m Memory) KeyExists(_ context.Context, key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+// Upload stores a copy of the contents and metadata under the specified key.
+// Returns ErrAlreadyExists if the object already exists.
+func (m Memory) Upload(_ context.Context, key string, meta Metadata, contents []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[key]; ok {
+		return ErrAlreadyExists
+	}
+
+	m.objects[key] = memoryObject{
+		contents:     bytes.Clone(contents),
+		contentType:  meta.objectContentType(),
+		lastModified: time.Now().UTC(),
+		metadata:     meta.Map(),
+	}
+	return nil
+}
+
+// UploadStream reads the whole body and stores it like Upload does.
+func (m Memory) UploadStream(ctx context.Context, key string, meta Metadata, body io.Reader) error {
+	contents, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return m.Upload(ctx, key, meta, contents)
+}
+
+// UpdateMetadata replaces metadata of an existing object. Returns ErrNotFound
+// if the object does not exist.
+func (m Memory) UpdateMetadata(_ context.Context, key string, meta Metadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return ErrNotFound
+	}
+	obj.metadata = meta.Map()
+	m.objects[key] = obj
+	return nil
+}
+
+// Delete removes an object, removing a missing object is not an error.
+func (m Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
+
+func (m Memory) HealthCheck(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/CZERTAINLY/CBOM-Repository/internal/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_Isolation(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+	key := testURN + "-1"
+
+	contents := []byte(`{"a":"b"}`)
+	require.NoError(t, m.Upload(ctx, key, store.Metadata{Version: "1"}, contents))
+
+	// modifying the uploaded slice must not modify the stored object
+	contents[0] = '['
+	got, err := m.GetObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"a":"b"}`), got)
+
+	// neither must modifying the returned values
+	got[0] = '['
+	head, err := m.GetHeadObject(ctx, key)
+	require.NoError(t, err)
+	head.Metadata[store.MetaVersionKey] = "2"
+
+	got, err = m.GetObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"a":"b"}`), got)
+	head, err = m.GetHeadObject(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "1", head.Metadata[store.MetaVersionKey])
+
+	// copies share the same objects
+	cpy := m
+	exists, err := cpy.KeyExists(ctx, key)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(v int) {
+			defer wg.Done()
+			key := fmt.Sprintf("%s-%d", testURN, v)
+			require.NoError(t, m.Upload(ctx, key, store.Metadata{Version: fmt.Sprintf("%d", v)}, []byte("{}")))
+			_, err := m.Search(ctx, 0)
+			require.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	versions, _, err := m.GetObjectVersions(ctx, testURN)
+	require.NoError(t, err)
+	require.Len(t, versions, 50)
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -20,15 +22,30 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
 )
 
 const (
 	MetaVersionKey     = "version"
 	MetaCryptoStatsKey = "crypto-stats"
+	// MetaDeletedAtKey holds the RFC 3339 time when the object was soft
+	// deleted, objects carrying it are tombstones.
+	MetaDeletedAtKey = "deleted-at"
+	// MetaContentTypeKey holds the media type the BOM was uploaded in.
+	MetaContentTypeKey = "content-type"
+	// MetaPolicyFindingsKey holds the findings of the policies evaluated on
+	// upload, encoded as JSON.
+	MetaPolicyFindingsKey = "policy-findings"
 )
 
+// defaultContentType is the content type of objects whose metadata do not
+// carry any.
+const defaultContentType = "application/json"
+
 type S3Contract interface {
+	CopyObject(context.Context, *s3.CopyObjectInput, ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	HeadBucket(context.Context, *s3.HeadBucketInput, ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
 	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
 	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
@@ -41,11 +58,11 @@ type S3Manager interface {
 }
 
 type Config struct {
-	Region       string `envconfig:"APP_S3_REGION" required:"true"`
+	Region       string `envconfig:"APP_S3_REGION"`
 	Endpoint     string `envconfig:"APP_S3_ENDPOINT"`
-	Bucket       string `envconfig:"APP_S3_BUCKET" required:"true"`
-	AccessKey    string `envconfig:"APP_S3_ACCESS_KEY" required:"true"`
-	SecretKey    string `envconfig:"APP_S3_SECRET_KEY" required:"true"`
+	Bucket       string `envconfig:"APP_S3_BUCKET"`
+	AccessKey    string `envconfig:"APP_S3_ACCESS_KEY"`
+	SecretKey    string `envconfig:"APP_S3_SECRET_KEY"`
 	UsePathStyle bool   `envconfig:"APP_S3_USE_PATH_STYLE" default:"true"`
 }
 
@@ -58,13 +75,71 @@ type Store struct {
 type Metadata struct {
 	Version     string
 	CryptoStats string
+	// DeletedAt is set on soft deleted objects only.
+	DeletedAt string
+	// ContentType is the media type the BOM was uploaded in, it is used as
+	// the content type of the object as well. Objects stored without it are
+	// JSON documents.
+	ContentType string
+	// PolicyFindings is set on objects violating a policy only.
+	PolicyFindings string
 }
 
 func (m Metadata) Map() map[string]string {
-	return map[string]string{
+	res := map[string]string{
 		MetaVersionKey:     m.Version,
 		MetaCryptoStatsKey: m.CryptoStats,
 	}
+	if m.DeletedAt != "" {
+		res[MetaDeletedAtKey] = m.DeletedAt
+	}
+	if m.ContentType != "" {
+		res[MetaContentTypeKey] = m.ContentType
+	}
+	if m.PolicyFindings != "" {
+		res[MetaPolicyFindingsKey] = m.PolicyFindings
+	}
+	return res
+}
+
+// MaxMetadataSize is the limit S3 puts on the user metadata of an object, it
+// is the sum of the lengths of all the keys and values.
+const MaxMetadataSize = 2048
+
+// Size returns the size of the metadata as counted against MaxMetadataSize.
+// Values out of the ASCII range are encoded by the S3 clients, which makes
+// them longer than counted here.
+func (m Metadata) Size() int {
+	size := 0
+	for k, v := range m.Map() {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// objectContentType returns the content type the object is stored with.
+func (m Metadata) objectContentType() string {
+	if m.ContentType != "" {
+		return m.ContentType
+	}
+	return defaultContentType
+}
+
+// ParseMetadata is the inverse of Metadata.Map, it reads metadata returned
+// along with the head of an object.
+func ParseMetadata(m map[string]string) Metadata {
+	return Metadata{
+		Version:        m[MetaVersionKey],
+		CryptoStats:    m[MetaCryptoStatsKey],
+		DeletedAt:      m[MetaDeletedAtKey],
+		ContentType:    m[MetaContentTypeKey],
+		PolicyFindings: m[MetaPolicyFindingsKey],
+	}
+}
+
+// Deleted reports whether the metadata belongs to a soft deleted object.
+func Deleted(m map[string]string) bool {
+	return m[MetaDeletedAtKey] != ""
 }
 
 func New(cfg Config, s3Client S3Contract, s3Manager S3Manager) Store {
@@ -90,6 +165,16 @@ func New(cfg Config, s3Client S3Contract, s3Manager S3Manager) Store {
 // An empty slice is returned if no objects match the criteria or if the bucket
 // is empty.
 func (s Store) Search(ctx context.Context, ts int64) ([]string, error) {
+	objects, err := s.List(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	return objectKeys(objects), nil
+}
+
+// List is like Search, the keys are returned along with the last modified
+// time of the objects as listed by the bucket, the heads are not read.
+func (s Store) List(ctx context.Context, ts int64) ([]ObjectInfo, error) {
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.cfg.Bucket),
 	}
@@ -98,7 +183,7 @@ func (s Store) Search(ctx context.Context, ts int64) ([]string, error) {
 
 	var err error
 	var output *s3.ListObjectsV2Output
-	res := []string{}
+	res := []ObjectInfo{}
 
 	objectPaginator := s3.NewListObjectsV2Paginator(s.s3Client, input)
 	for objectPaginator.HasMorePages() {
@@ -107,8 +192,9 @@ func (s Store) Search(ctx context.Context, ts int64) ([]string, error) {
 			return nil, errors.New("obtaining next page failed")
 		}
 		for _, cpy := range output.Contents {
-			if unixTimestamp.Before(*cpy.LastModified) {
-				res = append(res, *cpy.Key)
+			lastModified := aws.ToTime(cpy.LastModified)
+			if unixTimestamp.Before(lastModified) {
+				res = append(res, ObjectInfo{Key: aws.ToString(cpy.Key), LastModified: lastModified})
 			}
 		}
 	}
@@ -157,17 +243,27 @@ func (s Store) GetObjectVersions(ctx context.Context, urn string) ([]int, bool,
 		return nil, false, ErrNotFound
 	}
 
-	// post process just the versions
+	keys := make([]string, 0, len(objects))
+	for _, cpy := range objects {
+		keys = append(keys, *cpy.Key)
+	}
+	return parseVersions(ctx, urn, keys)
+}
+
+// parseVersions post processes object keys sharing the `urn` prefix into
+// a sorted slice of numeric versions and a flag whether an "original" version
+// is present. Returns error if any key breaks the key format invariant.
+func parseVersions(ctx context.Context, urn string, keys []string) ([]int, bool, error) {
 	var res []int
 	var hasOriginal bool
-	for _, cpy := range objects {
-		after, found := strings.CutPrefix(*cpy.Key, fmt.Sprintf("%s-", urn))
+	for _, key := range keys {
+		after, found := strings.CutPrefix(key, fmt.Sprintf("%s-", urn))
 		if !found {
-			slog.ErrorContext(ctx, "Unexpected suffix in s3 key.",
-				slog.String("key", *cpy.Key),
+			slog.ErrorContext(ctx, "Unexpected suffix in object key.",
+				slog.String("key", key),
 				slog.String("key format invariant", "urn:uuid:<uuid>-<version>"),
 			)
-			return nil, false, fmt.Errorf("unexpected key %s", *cpy.Key)
+			return nil, false, fmt.Errorf("unexpected key %s", key)
 		}
 		if after == "original" {
 			hasOriginal = true
@@ -175,8 +271,8 @@ func (s Store) GetObjectVersions(ctx context.Context, urn string) ([]int, bool,
 		}
 		ver, err := strconv.Atoi(after)
 		if err != nil {
-			slog.ErrorContext(ctx, "Unexpected suffix in s3 key, suffix should be a number",
-				slog.String("key", *cpy.Key),
+			slog.ErrorContext(ctx, "Unexpected suffix in object key, suffix should be a number",
+				slog.String("key", key),
 				slog.String("suffix", after),
 				slog.String("key format invariant", "urn:uuid:<uuid>-<version>"),
 			)
@@ -195,6 +291,18 @@ type HeadObject struct {
 	Metadata      map[string]string
 }
 
+// ObjectReader is the contents of an object opened for reading along with
+// the information needed to serve it without reading it first.
+type ObjectReader struct {
+	io.ReadCloser
+	// ContentLength is the size of the contents in bytes, zero if unknown.
+	ContentLength int64
+	ContentType   string
+	// ETag is an opaque quoted entity tag identifying the contents, e.g. its
+	// checksum, empty if unknown.
+	ETag string
+}
+
 // GetHeadObject retrieves metadata for an object in S3 without downloading the
 // object's content. This is useful for checking object existence and obtaining
 // metadata such as size, content type, last modified time, and custom metadata.
@@ -227,17 +335,18 @@ func (s Store) GetHeadObject(ctx context.Context, key string) (HeadObject, error
 		return HeadObject{}, errors.New("`s3.HeadObject()` returned nil result without error")
 	}
 
+	// the fields are optional in the S3 API, some S3-compatible stores omit them
 	return HeadObject{
-		ContentLength: *head.ContentLength,
-		ContentType:   *head.ContentType,
-		LastModified:  *head.LastModified,
+		ContentLength: aws.ToInt64(head.ContentLength),
+		ContentType:   aws.ToString(head.ContentType),
+		LastModified:  aws.ToTime(head.LastModified),
 		Metadata:      head.Metadata,
 	}, nil
 }
 
 // GetObject retrieves the complete contents of an object from S3 and returns
 // it as a byte slice. Returns ErrNotFound if the object does not exist
-// in the bucket.
+// in the bucket or if it is soft deleted.
 //
 // Parameters:
 //   - ctx: Context for cancellation, deadlines and additional slog fields.
@@ -246,6 +355,35 @@ func (s Store) GetHeadObject(ctx context.Context, key string) (HeadObject, error
 // Returns the object's contents as a byte slice and an error if the operation
 // fails.
 func (s Store) GetObject(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.OpenObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = obj.Close()
+	}()
+
+	b, err := io.ReadAll(obj)
+	if err != nil {
+		slog.ErrorContext(ctx, "`io.ReadAll()` failed.", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// OpenObject opens the contents of an object in S3 for reading, the contents
+// is streamed from S3 as it is read. Returns ErrNotFound if the object does
+// not exist in the bucket or if it is soft deleted.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines and additional slog fields.
+//   - key: The S3 object key to retrieve
+//
+// Returns the object reader, which the caller must close, and an error if the
+// operation fails.
+func (s Store) OpenObject(ctx context.Context, key string) (ObjectReader, error) {
 	result, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.cfg.Bucket),
 		Key:    aws.String(key),
@@ -256,24 +394,24 @@ func (s Store) GetObject(ctx context.Context, key string) ([]byte, error) {
 
 	switch {
 	case errors.As(err, &nsk) || errors.As(err, &nf):
-		return nil, ErrNotFound
+		return ObjectReader{}, ErrNotFound
 
 	case err != nil:
 		slog.ErrorContext(ctx, "`s3.GetObject()` failed.", slog.String("error", err.Error()))
-		return nil, err
+		return ObjectReader{}, err
 	}
 
-	defer func() {
+	if Deleted(result.Metadata) {
 		_ = result.Body.Close()
-	}()
-
-	b, err := io.ReadAll(result.Body)
-	if err != nil {
-		slog.ErrorContext(ctx, "`io.ReadAll()` failed.", slog.String("error", err.Error()))
-		return nil, err
+		return ObjectReader{}, ErrNotFound
 	}
 
-	return b, nil
+	return ObjectReader{
+		ReadCloser:    result.Body,
+		ContentLength: aws.ToInt64(result.ContentLength),
+		ContentType:   aws.ToString(result.ContentType),
+		ETag:          aws.ToString(result.ETag),
+	}, nil
 }
 
 // KeyExists checks whether an object with the specified key exists in the S3
@@ -306,26 +444,47 @@ func (s Store) KeyExists(ctx context.Context, key string) (bool, error) {
 }
 
 // Upload stores an object in S3 with the specified key, metadata, and contents.
-// The object is uploaded with a SHA256 checksum for data integrity verification.
+// It is a shorthand for UploadStream of a byte slice.
+func (s Store) Upload(ctx context.Context, key string, meta Metadata, contents []byte) error {
+	return s.UploadStream(ctx, key, meta, bytes.NewReader(contents))
+}
+
+// UploadStream stores an object in S3 with the specified key and metadata,
+// the contents are read from `body`. The object is uploaded with a SHA256
+// checksum for data integrity verification.
+//
+// The body is handed over to the transfer manager, which switches to a
+// multipart upload for bodies larger than a single part. Parts are read and
+// sent one by one, so the memory used does not grow with the object size.
+//
+// The upload is create-only, it is sent as a conditional write with
+// `If-None-Match: *`, so an existing object is never overwritten even by
+// concurrent uploads. Returns ErrAlreadyExists if the object already exists.
 //
 // Parameters:
 //   - ctx: Context for cancellation, deadlines and additional slog fields.
 //   - key: The S3 object key under which to store the content
 //   - meta: Metadata to attach to the object (version and crypto stats)
-//   - contents: The byte slice containing the object's data to upload
+//   - body: The reader providing the object's data to upload
 //
 // Returns an error if the upload operation fails.
-func (s Store) Upload(ctx context.Context, key string, meta Metadata, contents []byte) error {
+func (s Store) UploadStream(ctx context.Context, key string, meta Metadata, body io.Reader) error {
 	input := &manager.UploadObjectInput{
 		Bucket:            aws.String(s.cfg.Bucket),
 		Key:               aws.String(key),
-		Body:              bytes.NewReader(contents),
+		Body:              body,
 		Metadata:          meta.Map(),
 		ChecksumAlgorithm: managerTypes.ChecksumAlgorithmSha256,
-		ContentType:       aws.String("application/json"),
+		ContentType:       aws.String(meta.objectContentType()),
+		IfNoneMatch:       aws.String("*"),
 	}
 	_, err := s.s3Manager.UploadObject(ctx, input)
-	if err != nil {
+	switch {
+	case preconditionFailed(err):
+		slog.DebugContext(ctx, "Conditional upload rejected, object already exists.", slog.String("key", key))
+		return ErrAlreadyExists
+
+	case err != nil:
 		slog.ErrorContext(ctx, "`s3.manager.UploadObject()` failed.", slog.String("error", err.Error()))
 		return err
 	}
@@ -333,6 +492,73 @@ func (s Store) Upload(ctx context.Context, key string, meta Metadata, contents [
 	return nil
 }
 
+// preconditionFailed reports whether the error is an http 412 response, which
+// S3 returns when the `If-None-Match: *` condition of a write fails.
+func preconditionFailed(err error) bool {
+	var re interface{ HTTPStatusCode() int }
+	return errors.As(err, &re) && re.HTTPStatusCode() == http.StatusPreconditionFailed
+}
+
+// UpdateMetadata replaces metadata of an existing object, S3 does not allow
+// modifying metadata in place, so the object is copied onto itself. Please
+// note the copy gets a new LastModified time. Returns ErrNotFound if the
+// object does not exist.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines and additional slog fields.
+//   - key: The S3 object key whose metadata is replaced
+//   - meta: The new metadata of the object
+//
+// Returns an error if the operation fails.
+func (s Store) UpdateMetadata(ctx context.Context, key string, meta Metadata) error {
+	head, err := s.GetHeadObject(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.cfg.Bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(s.cfg.Bucket + "/" + url.PathEscape(key)),
+		Metadata:          meta.Map(),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		ContentType:       aws.String(head.ContentType),
+	})
+
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+
+	switch {
+	case errors.As(err, &nsk) || errors.As(err, &nf):
+		return ErrNotFound
+
+	case err != nil:
+		slog.ErrorContext(ctx, "`s3.CopyObject()` failed.", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// Delete permanently removes an object from S3. Deleting an object which does
+// not exist is not an error.
+//
+// Parameters:
+//   - ctx: Context for cancellation, deadlines and additional slog fields.
+//   - key: The S3 object key to remove
+//
+// Returns an error if the operation fails.
+func (s Store) Delete(ctx context.Context, key string) error {
+	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "`s3.DeleteObject()` failed.", slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
 func (s Store) HealthCheck(ctx context.Context) error {
 	_, err := s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(s.cfg.Bucket),
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
@@ -34,9 +36,10 @@ func TestStoreUpload(t *testing.T) {
 	}
 
 	tests := map[string]struct {
-		key     string
-		setup   func(mockCtrl *gomock.Controller, key string) store.Store
-		wantErr bool
+		key       string
+		setup     func(mockCtrl *gomock.Controller, key string) store.Store
+		wantErr   bool
+		wantErrIs error
 	}{
 		"success": {
 			key: "urn:uuid:5bd5a7c5-f5f0-40db-a216-d242abba1185-1",
@@ -50,6 +53,7 @@ func TestStoreUpload(t *testing.T) {
 				).DoAndReturn(func(_ context.Context, in *manager.UploadObjectInput, _ ...func(*manager.Options)) (*manager.UploadObjectOutput, error) {
 					require.Equal(t, bucketName, *in.Bucket)
 					require.Equal(t, key, *in.Key)
+					require.Equal(t, "*", *in.IfNoneMatch)
 					return &manager.UploadObjectOutput{}, nil
 				})
 
@@ -57,6 +61,22 @@ func TestStoreUpload(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		"object already exists": {
+			key: "urn:uuid:5bd5a7c5-f5f0-40db-a216-d242abba1185-2",
+			setup: func(mockCtrl *gomock.Controller, key string) store.Store {
+				s3Mock := mockS3.NewMockS3Contract(mockCtrl)
+				s3Manager := mockS3.NewMockS3Manager(mockCtrl)
+
+				s3Manager.EXPECT().UploadObject(
+					gomock.Any(),
+					gomock.AssignableToTypeOf(&manager.UploadObjectInput{}),
+				).Return(nil, fmt.Errorf("operation error S3: PutObject: %w", statusError(http.StatusPreconditionFailed)))
+
+				return store.New(store.Config{Bucket: bucketName}, s3Mock, s3Manager)
+			},
+			wantErr:   true,
+			wantErrIs: store.ErrAlreadyExists,
+		},
 		"put object returns error": {
 			key: "urn:uuid:5bd5a7c5-f5f0-40db-a216-d242abba1185-5",
 			setup: func(mockCtrl *gomock.Controller, key string) store.Store {
@@ -91,6 +111,9 @@ func TestStoreUpload(t *testing.T) {
 			err := s.Upload(context.Background(), tc.key, meta, []byte("some bytes"))
 			if tc.wantErr {
 				require.Error(t, err)
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs)
+				}
 			} else {
 				require.NoError(t, err)
 			}
@@ -98,3 +121,9 @@ func TestStoreUpload(t *testing.T) {
 	}
 
 }
+
+// statusError mimics http response errors returned by the aws sdk.
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("http response error StatusCode: %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }